@@ -0,0 +1,158 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSectionSize is the largest upstream response accepted for a single section
+const maxSectionSize = 4 << 20
+
+// Section is one part of a composite view. Exactly one of Data and Error is set.
+type Section struct {
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// OrderView is an order merged with its product, payments and notifications
+type OrderView struct {
+	Order         json.RawMessage `json:"order"`
+	Product       Section         `json:"product"`
+	Payments      Section         `json:"payments"`
+	Notifications Section         `json:"notifications"`
+}
+
+// Aggregator serves composite views built from several upstream services
+type Aggregator struct {
+	Orders        *proxy.Upstream
+	Products      *proxy.Upstream
+	Payments      *proxy.Upstream
+	Notifications *proxy.Upstream
+	Timeout       time.Duration
+}
+
+// upstreamError is returned when an upstream answers with a non-2xx status
+type upstreamError struct {
+	Upstream string
+	Status   int
+}
+
+func (e *upstreamError) Error() string {
+	return fmt.Sprintf("%s service returned status: %d", e.Upstream, e.Status)
+}
+
+// GetOrderView returns an order together with its product, payments and notifications.
+// The order is required; the other sections are fetched concurrently and report their
+// own error instead of failing the whole view.
+func (a *Aggregator) GetOrderView(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Timeout)
+	defer cancel()
+
+	orderData, err := fetch(ctx, a.Orders, fmt.Sprintf("/orders/%d", id))
+	if err != nil {
+		var upErr *upstreamError
+		switch {
+		case errors.As(err, &upErr) && upErr.Status == http.StatusNotFound:
+			problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
+		case ctx.Err() != nil:
+			problem.Write(c, problem.New(problem.CodeUpstreamTimeout, "Timed out loading order").With("upstream", a.Orders.Name))
+		default:
+			problem.Write(c, problem.New(problem.CodeUpstreamError, "Failed to load order").With("upstream", a.Orders.Name))
+		}
+		return
+	}
+
+	var order struct {
+		ProductID int `json:"product_id"`
+	}
+	if err := json.Unmarshal(orderData, &order); err != nil {
+		problem.Write(c, problem.New(problem.CodeUpstreamError, "Invalid order response").With("upstream", a.Orders.Name))
+		return
+	}
+
+	view := OrderView{Order: orderData}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		view.Product = section(ctx, a.Products, fmt.Sprintf("/products/%d", order.ProductID))
+	}()
+	go func() {
+		defer wg.Done()
+		view.Payments = section(ctx, a.Payments, fmt.Sprintf("/payments/order/%d?limit=%d", id, listing.MaxLimit))
+	}()
+	go func() {
+		defer wg.Done()
+		view.Notifications = section(ctx, a.Notifications, fmt.Sprintf("/notifications?order_id=%d&limit=%d", id, listing.MaxLimit))
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, view)
+}
+
+// section fetches a path from an upstream and wraps the result or error into a Section
+func section(ctx context.Context, upstream *proxy.Upstream, path string) Section {
+	data, err := fetch(ctx, upstream, path)
+	if err != nil {
+		return errorSection(ctx, upstream, err)
+	}
+	return Section{Data: data}
+}
+
+// errorSection describes a failed section without exposing upstream internals
+func errorSection(ctx context.Context, upstream *proxy.Upstream, err error) Section {
+	var upErr *upstreamError
+	switch {
+	case errors.As(err, &upErr):
+		return Section{Error: upErr.Error()}
+	case ctx.Err() != nil:
+		return Section{Error: upstream.Name + " service timed out"}
+	default:
+		return Section{Error: upstream.Name + " service unavailable"}
+	}
+}
+
+// fetch performs a GET against the upstream, going through its circuit breaker and retries
+func fetch(ctx context.Context, upstream *proxy.Upstream, path string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.Targets[0].String()+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &upstreamError{Upstream: upstream.Name, Status: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSectionSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s service response: %w", upstream.Name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-microservices/api-gateway/gql"
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/config"
+)
+
+// Config holds the gateway settings
+type Config struct {
+	HTTP           config.HTTP
+	Health         config.Health
+	Shutdown       config.Shutdown
+	ClientDistPath string `env:"CLIENT_DIST_PATH"`
+	Services       ServicesConfig
+	ViewTimeout    time.Duration `env:"GATEWAY_VIEW_TIMEOUT"`
+	GraphQL        gql.Limits
+	Proxy          proxy.Config
+}
+
+// ServicesConfig holds the base URLs of the upstream services
+type ServicesConfig struct {
+	ProductURL      string `env:"PRODUCT_SERVICE_URL"`
+	OrderURL        string `env:"ORDER_SERVICE_URL"`
+	InventoryURL    string `env:"INVENTORY_SERVICE_URL"`
+	NotificationURL string `env:"NOTIFICATION_SERVICE_URL"`
+	PaymentURL      string `env:"PAYMENT_SERVICE_URL"`
+}
+
+// Validate checks that every upstream URL is absolute; each setting may list several, comma-separated
+func (s *ServicesConfig) Validate() error {
+	var problems []error
+	for _, service := range s.List() {
+		for _, raw := range strings.Split(service.URL, ",") {
+			if err := config.ValidateURL(service.Key, strings.TrimSpace(raw)); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Service is an upstream service and the setting its URL came from
+type Service struct {
+	Name string
+	Key  string
+	URL  string
+}
+
+// List returns the upstream services in a stable order
+func (s ServicesConfig) List() []Service {
+	return []Service{
+		{Name: "product", Key: "PRODUCT_SERVICE_URL", URL: s.ProductURL},
+		{Name: "order", Key: "ORDER_SERVICE_URL", URL: s.OrderURL},
+		{Name: "inventory", Key: "INVENTORY_SERVICE_URL", URL: s.InventoryURL},
+		{Name: "notification", Key: "NOTIFICATION_SERVICE_URL", URL: s.NotificationURL},
+		{Name: "payment", Key: "PAYMENT_SERVICE_URL", URL: s.PaymentURL},
+	}
+}
+
+// Validate checks the timeouts, retry and limit settings
+func (c *Config) Validate() error {
+	var problems []error
+	for key, value := range map[string]time.Duration{
+		"GATEWAY_VIEW_TIMEOUT":     c.ViewTimeout,
+		"GATEWAY_CONNECT_TIMEOUT":  c.Proxy.ConnectTimeout,
+		"GATEWAY_RESPONSE_TIMEOUT": c.Proxy.ResponseTimeout,
+		"GATEWAY_BREAKER_TIMEOUT":  c.Proxy.BreakerTimeout,
+	} {
+		if value <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive, got %s", key, value))
+		}
+	}
+	if c.Proxy.MaxRetries < 0 {
+		problems = append(problems, fmt.Errorf("GATEWAY_MAX_RETRIES must not be negative, got %d", c.Proxy.MaxRetries))
+	}
+	if c.GraphQL.MaxDepth <= 0 || c.GraphQL.MaxComplexity <= 0 {
+		problems = append(problems, fmt.Errorf("GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY must be positive"))
+	}
+	return errors.Join(problems...)
+}
+
+// loadConfig reads the gateway settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:           config.HTTP{Port: 8000},
+		Health:         config.DefaultHealth(),
+		Shutdown:       config.DefaultShutdown(),
+		ClientDistPath: "./client/dist",
+		Services: ServicesConfig{
+			ProductURL:      "http://product-service:8080",
+			OrderURL:        "http://order-service:8081",
+			InventoryURL:    "http://inventory-service:8082",
+			NotificationURL: "http://notification-service:8083",
+			PaymentURL:      "http://payment-service:8084",
+		},
+		ViewTimeout: 3 * time.Second,
+		GraphQL:     gql.DefaultLimits(),
+		Proxy:       proxy.DefaultConfig(),
+	}
+	config.MustLoad("api-gateway", &cfg)
+	return cfg
+}
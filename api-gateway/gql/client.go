@@ -0,0 +1,117 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/problem"
+)
+
+// errNotFound is returned when a service responds with 404
+var errNotFound = errors.New("not found")
+
+// maxConcurrentFetches bounds the parallel calls made by a single batch without a bulk endpoint
+const maxConcurrentFetches = 8
+
+// Services are the upstreams the GraphQL resolvers read from and write to
+type Services struct {
+	Products      *proxy.Upstream
+	Orders        *proxy.Upstream
+	Inventory     *proxy.Upstream
+	Payments      *proxy.Upstream
+	Notifications *proxy.Upstream
+}
+
+// object is a JSON object returned by one of the services
+type object = map[string]interface{}
+
+// call sends a JSON request to a service through its circuit breaker and decodes the response into out
+func call(ctx context.Context, upstream *proxy.Upstream, method, path string, body interface{}, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, upstream.Targets[0].String()+path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := upstream.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("%s service unavailable", upstream.Name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Server errors are sanitized by the service; only their code is worth repeating
+		p := problem.Decode(resp)
+		if p.Status >= 500 || p.Detail == "" {
+			return fmt.Errorf("%s service failed: %s", upstream.Name, p.Code)
+		}
+		return fmt.Errorf("%s service: %s", upstream.Name, p.Detail)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s service: %w", upstream.Name, err)
+	}
+	return nil
+}
+
+// fetchEach calls fetch for every key concurrently, for services that have no bulk endpoint.
+// Keys that are not found are left out of the result, and keys that failed are returned
+// as KeyErrors so that only their fields resolve to an error.
+func fetchEach[K comparable, V any](ctx context.Context, keys []K, fetch func(context.Context, K) (V, error)) (map[K]V, error) {
+	results := make(map[K]V, len(keys))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(KeyErrors[K])
+	sem := make(chan struct{}, maxConcurrentFetches)
+
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key K) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			value, err := fetch(ctx, key)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				results[key] = value
+			case errors.Is(err, errNotFound):
+			default:
+				errs[key] = err
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
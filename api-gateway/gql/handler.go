@@ -0,0 +1,110 @@
+package gql
+
+import (
+	"errors"
+	"net/http"
+
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+var errMutationOverGet = errors.New("mutations must be sent with POST")
+
+// Request is a GraphQL request body
+type Request struct {
+	Query         string                 `json:"query" form:"query" binding:"required"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves GraphQL requests over the microservices
+type Handler struct {
+	Schema   graphql.Schema
+	Services *Services
+	Limits   Limits
+}
+
+// NewHandler creates a GraphQL handler for the given services
+func NewHandler(services *Services, limits Limits) (*Handler, error) {
+	schema, err := NewSchema(services)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		Schema:   schema,
+		Services: services,
+		Limits:   limits,
+	}, nil
+}
+
+// ServeGraphQL executes a query sent as JSON (POST) or as query parameters (GET).
+// Mutations are only accepted over POST.
+func (h *Handler) ServeGraphQL(c *gin.Context) {
+	var req Request
+	if c.Request.Method == http.MethodGet {
+		if !problem.BindQuery(c, &req) {
+			return
+		}
+	} else if !problem.BindJSON(c, &req) {
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	validation := graphql.ValidateDocument(&h.Schema, doc, nil)
+	if !validation.IsValid {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	if err := checkLimits(h.Schema, doc, req.OperationName, h.Limits); err != nil {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if c.Request.Method == http.MethodGet && hasMutation(doc, req.OperationName) {
+		c.JSON(http.StatusMethodNotAllowed, &graphql.Result{Errors: gqlerrors.FormatErrors(errMutationOverGet)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = withLoaders(ctx, newLoaders(ctx, h.Services))
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.Schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// hasMutation reports whether the operation to execute is a mutation
+func hasMutation(doc *ast.Document, operationName string) bool {
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (operation.Name != nil && operation.Name.Value == operationName) {
+			if operation.Operation == ast.OperationTypeMutation {
+				return true
+			}
+		}
+	}
+	return false
+}
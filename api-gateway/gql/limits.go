@@ -0,0 +1,166 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listCostMultiplier is the assumed size of a list when estimating query complexity
+const listCostMultiplier = 10
+
+// Limits bounds how expensive a single GraphQL operation may be
+type Limits struct {
+	MaxDepth      int `env:"GRAPHQL_MAX_DEPTH"`
+	MaxComplexity int `env:"GRAPHQL_MAX_COMPLEXITY"`
+}
+
+// DefaultLimits returns default query limits
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth:      8,
+		MaxComplexity: 1000,
+	}
+}
+
+// limitChecker walks a validated document to compute depth and complexity
+type limitChecker struct {
+	schema    graphql.Schema
+	limits    Limits
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// checkLimits returns an error if the operation exceeds the depth or complexity limits.
+// Every field costs 1 and the cost of a list field's selection is multiplied by
+// listCostMultiplier. Introspection fields are not counted.
+func checkLimits(schema graphql.Schema, doc *ast.Document, operationName string, limits Limits) error {
+	checker := &limitChecker{
+		schema:    schema,
+		limits:    limits,
+		fragments: make(map[string]*ast.FragmentDefinition),
+	}
+
+	var operations []*ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch def := definition.(type) {
+		case *ast.FragmentDefinition:
+			checker.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operations = append(operations, def)
+			}
+		}
+	}
+
+	for _, operation := range operations {
+		var root *graphql.Object
+		switch operation.Operation {
+		case ast.OperationTypeMutation:
+			root = schema.MutationType()
+		case ast.OperationTypeSubscription:
+			root = schema.SubscriptionType()
+		default:
+			root = schema.QueryType()
+		}
+
+		cost, err := checker.selectionCost(operation.SelectionSet, root, 1)
+		if err != nil {
+			return err
+		}
+		if cost > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds maximum of %d", cost, limits.MaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+// selectionCost returns the cost of a selection set on the given parent type at the given depth
+func (lc *limitChecker) selectionCost(set *ast.SelectionSet, parent graphql.Type, depth int) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+
+	cost := 0
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			name := sel.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue
+			}
+			if depth > lc.limits.MaxDepth {
+				return 0, fmt.Errorf("query depth exceeds maximum of %d", lc.limits.MaxDepth)
+			}
+
+			fieldType, isList := lc.fieldType(parent, name)
+			childCost, err := lc.selectionCost(sel.SelectionSet, fieldType, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			if isList {
+				childCost *= listCostMultiplier
+			}
+			cost += 1 + childCost
+
+		case *ast.InlineFragment:
+			fragmentType := parent
+			if sel.TypeCondition != nil {
+				if t := lc.schema.Type(sel.TypeCondition.Name.Value); t != nil {
+					fragmentType = t
+				}
+			}
+			fragmentCost, err := lc.selectionCost(sel.SelectionSet, fragmentType, depth)
+			if err != nil {
+				return 0, err
+			}
+			cost += fragmentCost
+
+		case *ast.FragmentSpread:
+			fragment, ok := lc.fragments[sel.Name.Value]
+			if !ok {
+				continue
+			}
+			fragmentType := parent
+			if t := lc.schema.Type(fragment.TypeCondition.Name.Value); t != nil {
+				fragmentType = t
+			}
+			fragmentCost, err := lc.selectionCost(fragment.SelectionSet, fragmentType, depth)
+			if err != nil {
+				return 0, err
+			}
+			cost += fragmentCost
+		}
+	}
+
+	return cost, nil
+}
+
+// fieldType returns the named type of a field on parent and whether the field is a list
+func (lc *limitChecker) fieldType(parent graphql.Type, name string) (graphql.Type, bool) {
+	object, ok := parent.(*graphql.Object)
+	if !ok {
+		return nil, false
+	}
+	field, ok := object.Fields()[name]
+	if !ok {
+		return nil, false
+	}
+
+	isList := false
+	fieldType := field.Type
+	for {
+		switch t := fieldType.(type) {
+		case *graphql.NonNull:
+			fieldType = t.OfType
+			continue
+		case *graphql.List:
+			isList = true
+			fieldType = t.OfType
+			continue
+		}
+		return fieldType, isList
+	}
+}
@@ -0,0 +1,89 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BatchFunc fetches values for a set of keys. Keys missing from the result resolve to the zero value.
+// A KeyErrors error fails only the keys it holds; any other error fails the whole batch.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// KeyErrors holds the errors of the keys a batch failed to fetch
+type KeyErrors[K comparable] map[K]error
+
+// Error describes one of the failures
+func (e KeyErrors[K]) Error() string {
+	for key, err := range e {
+		if len(e) > 1 {
+			return fmt.Sprintf("key %v: %v (and %d more)", key, err, len(e)-1)
+		}
+		return fmt.Sprintf("key %v: %v", key, err)
+	}
+	return "no keys failed"
+}
+
+// Loader batches and caches key lookups for the lifetime of a single GraphQL request.
+// Load only records the key and returns a thunk; the first thunk invoked fetches every
+// key collected so far in one batch, which lets the executor resolve a whole list level
+// with a single upstream call instead of one call per item.
+type Loader[K comparable, V any] struct {
+	ctx   context.Context
+	fetch BatchFunc[K, V]
+
+	mu      sync.Mutex
+	current *batch[K, V]
+	cache   map[K]*batch[K, V]
+}
+
+// batch is a group of keys fetched together
+type batch[K comparable, V any] struct {
+	keys    []K
+	once    sync.Once
+	results map[K]V
+	err     error
+}
+
+// NewLoader creates a loader that fetches keys with fetch
+func NewLoader[K comparable, V any](ctx context.Context, fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		ctx:   ctx,
+		fetch: fetch,
+		cache: make(map[K]*batch[K, V]),
+	}
+}
+
+// Load schedules key to be fetched and returns a thunk resolving to its value
+func (l *Loader[K, V]) Load(key K) func() (V, error) {
+	l.mu.Lock()
+	b, ok := l.cache[key]
+	if !ok {
+		if l.current == nil {
+			l.current = &batch[K, V]{}
+		}
+		b = l.current
+		b.keys = append(b.keys, key)
+		l.cache[key] = b
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		b.once.Do(func() {
+			// Close the batch so keys loaded from now on start a new one
+			l.mu.Lock()
+			if l.current == b {
+				l.current = nil
+			}
+			l.mu.Unlock()
+
+			b.results, b.err = l.fetch(l.ctx, b.keys)
+		})
+		var keyErrs KeyErrors[K]
+		if errors.As(b.err, &keyErrs) {
+			return b.results[key], keyErrs[key]
+		}
+		return b.results[key], b.err
+	}
+}
@@ -0,0 +1,92 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-microservices/pkg/listing"
+)
+
+// Loaders holds the request-scoped loaders used by the resolvers
+type Loaders struct {
+	Products             *Loader[int, object]
+	Orders               *Loader[int, object]
+	PaymentsByOrder      *Loader[int, []object]
+	NotificationsByOrder *Loader[int, []object]
+}
+
+type loadersKey struct{}
+
+// newLoaders creates a fresh set of loaders for one GraphQL request
+func newLoaders(ctx context.Context, services *Services) *Loaders {
+	return &Loaders{
+		Products: NewLoader(ctx, func(ctx context.Context, ids []int) (map[int]object, error) {
+			// product-service accepts a list of IDs, so each page-sized chunk of the batch is a single call
+			results := make(map[int]object, len(ids))
+			for start := 0; start < len(ids); start += listing.MaxLimit {
+				chunk := ids[start:min(start+listing.MaxLimit, len(ids))]
+				params := make([]string, len(chunk))
+				for i, id := range chunk {
+					params[i] = strconv.Itoa(id)
+				}
+
+				var products []object
+				path := fmt.Sprintf("/products?ids=%s&limit=%d", url.QueryEscape(strings.Join(params, ",")), len(chunk))
+				if err := call(ctx, services.Products, "GET", path, nil, &products); err != nil {
+					return nil, err
+				}
+				for _, product := range products {
+					if id, ok := intValue(product["id"]); ok {
+						results[id] = product
+					}
+				}
+			}
+			return results, nil
+		}),
+		Orders: NewLoader(ctx, func(ctx context.Context, ids []int) (map[int]object, error) {
+			return fetchEach(ctx, ids, func(ctx context.Context, id int) (object, error) {
+				var order object
+				err := call(ctx, services.Orders, "GET", fmt.Sprintf("/orders/%d", id), nil, &order)
+				return order, err
+			})
+		}),
+		PaymentsByOrder: NewLoader(ctx, func(ctx context.Context, orderIDs []int) (map[int][]object, error) {
+			return fetchEach(ctx, orderIDs, func(ctx context.Context, orderID int) ([]object, error) {
+				var payments []object
+				err := call(ctx, services.Payments, "GET", fmt.Sprintf("/payments/order/%d?limit=%d", orderID, listing.MaxLimit), nil, &payments)
+				return payments, err
+			})
+		}),
+		NotificationsByOrder: NewLoader(ctx, func(ctx context.Context, orderIDs []int) (map[int][]object, error) {
+			return fetchEach(ctx, orderIDs, func(ctx context.Context, orderID int) ([]object, error) {
+				var notifications []object
+				err := call(ctx, services.Notifications, "GET", fmt.Sprintf("/notifications?order_id=%d&limit=%d", orderID, listing.MaxLimit), nil, &notifications)
+				return notifications, err
+			})
+		}),
+	}
+}
+
+// withLoaders attaches loaders to a request context
+func withLoaders(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+// loadersFrom returns the loaders attached to a request context
+func loadersFrom(ctx context.Context) *Loaders {
+	return ctx.Value(loadersKey{}).(*Loaders)
+}
+
+// intValue converts a JSON number to an int
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,348 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+)
+
+// key resolves a field from a snake_case key of the JSON object returned by a service
+func key(name string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if source, ok := p.Source.(object); ok {
+			return source[name], nil
+		}
+		return nil, nil
+	}
+}
+
+// loadThunk adapts a loader thunk to the signature the GraphQL executor expects.
+// Keys the loader did not find resolve to null.
+func loadThunk[V any](thunk func() (V, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		if obj, ok := any(value).(object); ok && obj == nil {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+// nilIfNotFound turns a not-found error into a null result
+func nilIfNotFound(value interface{}, err error) (interface{}, error) {
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// NewSchema builds the GraphQL schema whose resolvers call the given services
+func NewSchema(services *Services) (graphql.Schema, error) {
+	orderStatus := graphql.NewEnum(graphql.EnumConfig{
+		Name: "OrderStatus",
+		Values: graphql.EnumValueConfigMap{
+			"PENDING":    {Value: "pending"},
+			"PROCESSING": {Value: "processing"},
+			"SHIPPED":    {Value: "shipped"},
+			"DELIVERED":  {Value: "delivered"},
+			"COMPLETED":  {Value: "completed"},
+			"CANCELLED":  {Value: "cancelled"},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":          {Type: graphql.NewNonNull(graphql.Int), Resolve: key("id")},
+			"name":        {Type: graphql.String, Resolve: key("name")},
+			"description": {Type: graphql.String, Resolve: key("description")},
+			"price":       {Type: graphql.Float, Resolve: key("price")},
+		},
+	})
+
+	paymentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Payment",
+		Fields: graphql.Fields{
+			"id":              {Type: graphql.NewNonNull(graphql.Int), Resolve: key("id")},
+			"orderId":         {Type: graphql.Int, Resolve: key("order_id")},
+			"customerId":      {Type: graphql.Int, Resolve: key("customer_id")},
+			"amount":          {Type: graphql.Float, Resolve: key("amount")},
+			"currency":        {Type: graphql.String, Resolve: key("currency")},
+			"status":          {Type: graphql.String, Resolve: key("status")},
+			"stripePaymentId": {Type: graphql.String, Resolve: key("stripe_payment_id")},
+			"paymentMethod":   {Type: graphql.String, Resolve: key("payment_method")},
+			"createdAt":       {Type: graphql.String, Resolve: key("created_at")},
+			"updatedAt":       {Type: graphql.String, Resolve: key("updated_at")},
+		},
+	})
+
+	notificationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Notification",
+		Fields: graphql.Fields{
+			"id":          {Type: graphql.NewNonNull(graphql.Int), Resolve: key("id")},
+			"orderId":     {Type: graphql.Int, Resolve: key("order_id")},
+			"customerId":  {Type: graphql.Int, Resolve: key("customer_id")},
+			"message":     {Type: graphql.String, Resolve: key("message")},
+			"status":      {Type: graphql.String, Resolve: key("status")},
+			"createdAt":   {Type: graphql.String, Resolve: key("created_at")},
+			"deliveredAt": {Type: graphql.String, Resolve: key("delivered_at")},
+		},
+	})
+
+	inventoryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "InventoryItem",
+		Fields: graphql.Fields{
+			"id":        {Type: graphql.NewNonNull(graphql.Int), Resolve: key("id")},
+			"productId": {Type: graphql.Int, Resolve: key("product_id")},
+			"quantity":  {Type: graphql.Int, Resolve: key("quantity")},
+			"sku":       {Type: graphql.String, Resolve: key("sku")},
+			"location":  {Type: graphql.String, Resolve: key("location")},
+			"product": {
+				Type: productType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					productID, ok := intValue(p.Source.(object)["product_id"])
+					if !ok {
+						return nil, nil
+					}
+					return loadThunk(loadersFrom(p.Context).Products.Load(productID)), nil
+				},
+			},
+		},
+	})
+
+	orderType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Order",
+		Fields: graphql.Fields{
+			"id":         {Type: graphql.NewNonNull(graphql.Int), Resolve: key("id")},
+			"customerId": {Type: graphql.Int, Resolve: key("customer_id")},
+			"productId":  {Type: graphql.Int, Resolve: key("product_id")},
+			"quantity":   {Type: graphql.Int, Resolve: key("quantity")},
+			"totalPrice": {Type: graphql.Float, Resolve: key("total_price")},
+			"status":     {Type: orderStatus, Resolve: key("status")},
+			"createdAt":  {Type: graphql.String, Resolve: key("created_at")},
+			"product": {
+				Type: productType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					productID, ok := intValue(p.Source.(object)["product_id"])
+					if !ok {
+						return nil, nil
+					}
+					return loadThunk(loadersFrom(p.Context).Products.Load(productID)), nil
+				},
+			},
+			"payments": {
+				Type: graphql.NewList(paymentType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					orderID, _ := intValue(p.Source.(object)["id"])
+					return loadThunk(loadersFrom(p.Context).PaymentsByOrder.Load(orderID)), nil
+				},
+			},
+			"notifications": {
+				Type: graphql.NewList(notificationType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					orderID, _ := intValue(p.Source.(object)["id"])
+					return loadThunk(loadersFrom(p.Context).NotificationsByOrder.Load(orderID)), nil
+				},
+			},
+		},
+	})
+
+	// A payment or notification can link back to its order
+	orderField := &graphql.Field{
+		Type: orderType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			orderID, ok := intValue(p.Source.(object)["order_id"])
+			if !ok {
+				return nil, nil
+			}
+			return loadThunk(loadersFrom(p.Context).Orders.Load(orderID)), nil
+		},
+	}
+	paymentType.AddFieldConfig("order", orderField)
+	notificationType.AddFieldConfig("order", orderField)
+
+	// Top-level lists return one page; limit sets its size within the services' bounds
+	listArgs := graphql.FieldConfigArgument{
+		"limit": {Type: graphql.Int},
+	}
+
+	idArgs := graphql.FieldConfigArgument{
+		"id": {Type: graphql.NewNonNull(graphql.Int)},
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": {
+				Type: productType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadThunk(loadersFrom(p.Context).Products.Load(p.Args["id"].(int))), nil
+				},
+			},
+			"products": {
+				Type: graphql.NewList(productType),
+				Args: listArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var products []object
+					return products, call(p.Context, services.Products, "GET", listPath("/products", p, nil), nil, &products)
+				},
+			},
+			"order": {
+				Type: orderType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadThunk(loadersFrom(p.Context).Orders.Load(p.Args["id"].(int))), nil
+				},
+			},
+			"orders": {
+				Type: graphql.NewList(orderType),
+				Args: listArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var orders []object
+					return orders, call(p.Context, services.Orders, "GET", listPath("/orders", p, nil), nil, &orders)
+				},
+			},
+			"inventoryItem": {
+				Type: inventoryType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var item object
+					return nilIfNotFound(item, call(p.Context, services.Inventory, "GET", fmt.Sprintf("/inventory/%d", p.Args["id"].(int)), nil, &item))
+				},
+			},
+			"inventory": {
+				Type: graphql.NewList(inventoryType),
+				Args: listArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var items []object
+					return items, call(p.Context, services.Inventory, "GET", listPath("/inventory", p, nil), nil, &items)
+				},
+			},
+			"payment": {
+				Type: paymentType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var payment object
+					return nilIfNotFound(payment, call(p.Context, services.Payments, "GET", fmt.Sprintf("/payments/%d", p.Args["id"].(int)), nil, &payment))
+				},
+			},
+			"paymentsByOrder": {
+				Type: graphql.NewList(paymentType),
+				Args: graphql.FieldConfigArgument{
+					"orderId": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadThunk(loadersFrom(p.Context).PaymentsByOrder.Load(p.Args["orderId"].(int))), nil
+				},
+			},
+			"notification": {
+				Type: notificationType,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var notification object
+					return nilIfNotFound(notification, call(p.Context, services.Notifications, "GET", fmt.Sprintf("/notifications/%d", p.Args["id"].(int)), nil, &notification))
+				},
+			},
+			"notifications": {
+				Type: graphql.NewList(notificationType),
+				Args: graphql.FieldConfigArgument{
+					"customerId": {Type: graphql.Int},
+					"limit":      {Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					query := url.Values{}
+					if customerID, ok := p.Args["customerId"].(int); ok {
+						query.Set("customer_id", strconv.Itoa(customerID))
+					}
+					var notifications []object
+					return notifications, call(p.Context, services.Notifications, "GET", listPath("/notifications", p, query), nil, &notifications)
+				},
+			},
+		},
+	})
+
+	createOrderInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateOrderInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"customerId": {Type: graphql.NewNonNull(graphql.Int)},
+			"productId":  {Type: graphql.NewNonNull(graphql.Int)},
+			"quantity":   {Type: graphql.NewNonNull(graphql.Int)},
+			"totalPrice": {Type: graphql.Float},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createOrder": {
+				Type: orderType,
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(createOrderInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input := p.Args["input"].(map[string]interface{})
+					body := object{
+						"customer_id": input["customerId"],
+						"product_id":  input["productId"],
+						"quantity":    input["quantity"],
+					}
+					if totalPrice, ok := input["totalPrice"]; ok {
+						body["total_price"] = totalPrice
+					}
+
+					var order object
+					return order, call(p.Context, services.Orders, "POST", "/orders", body, &order)
+				},
+			},
+			"updateOrderStatus": {
+				Type: orderType,
+				Args: graphql.FieldConfigArgument{
+					"id":     {Type: graphql.NewNonNull(graphql.Int)},
+					"status": {Type: graphql.NewNonNull(orderStatus)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Args["id"].(int)
+					status := p.Args["status"].(string)
+					path := fmt.Sprintf("/orders/%d/status", id)
+					if err := call(p.Context, services.Orders, "PATCH", path, object{"status": status}, nil); err != nil {
+						return nil, err
+					}
+
+					// The status endpoint only echoes the status, so read the order back
+					var order object
+					if err := call(p.Context, services.Orders, "GET", fmt.Sprintf("/orders/%d", id), nil, &order); err != nil {
+						return nil, err
+					}
+					order["status"] = status
+					return order, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// listPath adds the limit argument of a list field and the filters in query to a service path
+func listPath(path string, p graphql.ResolveParams, query url.Values) string {
+	if limit, ok := p.Args["limit"].(int); ok {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
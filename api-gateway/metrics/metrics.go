@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "The total number of requests handled by the gateway by route, method and status class",
+	}, []string{"route", "method", "status_class"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Time taken to handle gateway requests by route and method",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	RequestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_requests_in_flight",
+		Help: "The current number of requests being handled by route",
+	}, []string{"route"})
+
+	UpstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "The total number of attempts sent to upstream services by upstream and status class",
+	}, []string{"upstream", "status_class"})
+
+	UpstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Time taken by upstream services to return response headers",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream"})
+
+	UpstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "The total number of failed upstream attempts by upstream and reason",
+	}, []string{"upstream", "reason"})
+)
+
+// StatusClass returns the class of an HTTP status code, such as "2xx"
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
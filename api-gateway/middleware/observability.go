@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"go-microservices/api-gateway/metrics"
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeLabel returns the matched route pattern, keeping metric cardinality bounded
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// Metrics records request count, latency and in-flight requests per route
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := routeLabel(c)
+		inFlight := metrics.RequestsInFlight.WithLabelValues(route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		c.Next()
+
+		method := c.Request.Method
+		metrics.RequestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+		metrics.RequestsTotal.WithLabelValues(route, method, metrics.StatusClass(c.Writer.Status())).Inc()
+	}
+}
+
+// AccessLog writes one structured log line per request, including the upstream
+// that served it and how long the upstream took. Request ID, route and user ID
+// are added from the request context by the logger's handler.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, info := proxy.WithRequestInfo(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+		duration := time.Since(start)
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+		}
+
+		if upstream, target, attempts, upstreamDuration := info.Upstream(); attempts > 0 {
+			attrs = append(attrs,
+				slog.String("upstream", upstream),
+				slog.String("upstream_target", target),
+				slog.Int("upstream_attempts", attempts),
+				slog.Float64("upstream_duration_ms", float64(upstreamDuration.Microseconds())/1000),
+			)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		logger.LogAttrs(c.Request.Context(), logging.StatusLevel(status), "request", attrs...)
+	}
+}
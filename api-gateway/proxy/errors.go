@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrorHandler writes a problem document for failures raised while proxying to the upstream.
+// It is meant to be used as httputil.ReverseProxy.ErrorHandler.
+func (u *Upstream) ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem.New(problem.CodeUpstreamError, "Failed to reach "+u.Name+" service")
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		p = problem.New(problem.CodeUnavailable, u.Name+" service is temporarily unavailable")
+		w.Header().Set("Retry-After", strconv.Itoa(int(u.config.BreakerTimeout.Seconds())))
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		p = problem.New(problem.CodeUpstreamTimeout, u.Name+" service timed out")
+	}
+
+	slog.WarnContext(r.Context(), "Proxy error", "upstream", u.Name, "method", r.Method, "path", r.URL.Path, "status", p.Status, "error", err)
+	problem.Render(w, r, p.With("upstream", u.Name))
+}
+
+// normalizeError rewrites an upstream error response that is not already a
+// problem document, so clients see one error format whichever service failed.
+// Details of upstream server errors are dropped as they may expose internals.
+func (u *Upstream) normalizeError(resp *http.Response) error {
+	if resp.StatusCode < 400 || problem.IsProblem(resp.Header) {
+		return nil
+	}
+
+	var p *problem.Problem
+	if resp.Header.Get("Content-Encoding") != "" {
+		// A compressed body can't be inspected; fall back to the status alone
+		p = problem.New(problem.CodeForStatus(resp.StatusCode), "")
+		p.Status = resp.StatusCode
+	} else {
+		p = problem.Decode(resp)
+	}
+	resp.Body.Close()
+
+	if p.Status >= 500 {
+		p.Detail = u.Name + " service failed"
+	}
+	if resp.Request != nil {
+		p.Instance = resp.Request.URL.Path
+		p.RequestID = resp.Request.Header.Get(requestid.Header)
+	}
+	p.With("upstream", u.Name)
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Type", problem.ContentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.ContentLength = int64(len(data))
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return nil
+}
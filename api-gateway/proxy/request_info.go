@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RequestInfo collects what happened upstream while serving a gateway request,
+// so access logs can report it. It is safe for concurrent use by fan-out handlers.
+type RequestInfo struct {
+	mu               sync.Mutex
+	upstream         string
+	target           string
+	attempts         int
+	upstreamDuration time.Duration
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a context that records upstream activity into a new RequestInfo
+func WithRequestInfo(ctx context.Context) (context.Context, *RequestInfo) {
+	info := &RequestInfo{}
+	return context.WithValue(ctx, requestInfoKey{}, info), info
+}
+
+// requestInfoFrom returns the RequestInfo attached to ctx, or nil
+func requestInfoFrom(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
+// record adds one upstream attempt
+func (i *RequestInfo) record(upstream, target string, duration time.Duration) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.upstream = upstream
+	i.target = target
+	i.attempts++
+	i.upstreamDuration += duration
+}
+
+// Upstream returns the last upstream and target called, the number of attempts
+// and the total time spent waiting on upstreams
+func (i *RequestInfo) Upstream() (upstream, target string, attempts int, duration time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.upstream, i.target, i.attempts, i.upstreamDuration
+}
@@ -0,0 +1,301 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-microservices/api-gateway/metrics"
+	"go-microservices/pkg/requestid"
+
+	"github.com/sony/gobreaker"
+)
+
+// maxRetryBodySize is the largest request body buffered so it can be replayed on retry
+const maxRetryBodySize = 1 << 20
+
+// Config holds timeout, retry, circuit breaker and connection pool settings for upstreams
+type Config struct {
+	ConnectTimeout      time.Duration `env:"GATEWAY_CONNECT_TIMEOUT"`
+	ResponseTimeout     time.Duration `env:"GATEWAY_RESPONSE_TIMEOUT"`
+	MaxRetries          int           `env:"GATEWAY_MAX_RETRIES"`
+	BreakerFailures     uint32        `env:"GATEWAY_BREAKER_FAILURES"`
+	BreakerTimeout      time.Duration `env:"GATEWAY_BREAKER_TIMEOUT"`
+	MaxIdleConnsPerHost int           `env:"GATEWAY_MAX_IDLE_CONNS_PER_HOST"`
+	IdleConnTimeout     time.Duration `env:"GATEWAY_IDLE_CONN_TIMEOUT"`
+	FlushInterval       time.Duration `env:"GATEWAY_FLUSH_INTERVAL"`
+}
+
+// DefaultConfig returns default upstream configuration
+func DefaultConfig() Config {
+	return Config{
+		ConnectTimeout:      2 * time.Second,
+		ResponseTimeout:     15 * time.Second,
+		MaxRetries:          2,
+		BreakerFailures:     5,
+		BreakerTimeout:      30 * time.Second,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+		FlushInterval:       100 * time.Millisecond,
+	}
+}
+
+// NewTransport creates a keep-alive transport tuned for proxying, meant to be shared by all upstreams
+func NewTransport(config Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          config.MaxIdleConnsPerHost * 8,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.ConnectTimeout,
+		ResponseHeaderTimeout: config.ResponseTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// bufferPool recycles the copy buffers used by the reverse proxies
+type bufferPool struct {
+	pool sync.Pool
+}
+
+// Get returns a buffer from the pool, allocating one if the pool is empty
+func (p *bufferPool) Get() []byte {
+	if buf, ok := p.pool.Get().(*[]byte); ok {
+		return *buf
+	}
+	return make([]byte, 32*1024)
+}
+
+// Put returns a buffer to the pool
+func (p *bufferPool) Put(buf []byte) {
+	p.pool.Put(&buf)
+}
+
+var sharedBufferPool = &bufferPool{}
+
+// Upstream is a backend service reachable through one or more targets.
+// It serves requests through a reverse proxy built once, and implements http.RoundTripper
+// as that proxy's transport to apply the circuit breaker and retries.
+type Upstream struct {
+	Name    string
+	Targets []*url.URL
+
+	config    Config
+	transport http.RoundTripper
+	cb        *gobreaker.TwoStepCircuitBreaker
+	proxy     *httputil.ReverseProxy
+	next      uint32
+}
+
+// NewUpstream creates an upstream from a comma-separated list of target URLs.
+// Requests are sent through transport, which should be shared between upstreams.
+func NewUpstream(name, rawURLs string, config Config, transport http.RoundTripper) (*Upstream, error) {
+	var targets []*url.URL
+	for _, raw := range strings.Split(rawURLs, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		target, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s service URL %q: %w", name, raw, err)
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("invalid %s service URL %q: scheme and host are required", name, raw)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no URL configured for %s service", name)
+	}
+
+	u := &Upstream{
+		Name:      name,
+		Targets:   targets,
+		config:    config,
+		transport: transport,
+		cb: gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+			Name:        name,
+			MaxRequests: 1,
+			Interval:    10 * time.Second,
+			Timeout:     config.BreakerTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= config.BreakerFailures
+			},
+			OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+				slog.Warn("Circuit breaker state changed", "upstream", name, "from", from.String(), "to", to.String())
+			},
+		}),
+	}
+
+	u.proxy = httputil.NewSingleHostReverseProxy(targets[0])
+	u.proxy.Transport = u
+	u.proxy.ErrorHandler = u.ErrorHandler
+	u.proxy.FlushInterval = config.FlushInterval
+	u.proxy.BufferPool = sharedBufferPool
+	u.proxy.ModifyResponse = func(resp *http.Response) error {
+		// The gateway sets X-Request-ID on its own response
+		resp.Header.Del(requestid.Header)
+		return u.normalizeError(resp)
+	}
+
+	return u, nil
+}
+
+// ServeHTTP proxies the request to the upstream, keeping the request path as is
+func (u *Upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.proxy.ServeHTTP(w, r)
+}
+
+// RoundTrip sends the request to one of the upstream targets through the circuit breaker.
+// Idempotent requests that fail with a connection error or a 502/503/504 response are
+// retried on the next target.
+func (u *Upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	var body []byte
+	if isIdempotent(req.Method) && u.config.MaxRetries > 0 {
+		var err error
+		body, err = bufferBody(req)
+		if err == nil {
+			attempts += u.config.MaxRetries
+		} else if !errors.Is(err, errBodyTooLarge) {
+			return nil, err
+		}
+	}
+
+	start := int(atomic.AddUint32(&u.next, 1))
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+
+		target := u.Targets[(start+i)%len(u.Targets)]
+		outreq := req.Clone(req.Context())
+		outreq.URL.Scheme = target.Scheme
+		outreq.URL.Host = target.Host
+		if body != nil {
+			outreq.Body = io.NopCloser(bytes.NewReader(body))
+			outreq.ContentLength = int64(len(body))
+		}
+
+		resp, err := u.send(outreq)
+		if err != nil {
+			if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+				metrics.UpstreamErrorsTotal.WithLabelValues(u.Name, "circuit_open").Inc()
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+
+		if isRetryableStatus(resp.StatusCode) && i < attempts-1 {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s service returned status: %d", u.Name, resp.StatusCode)
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// send performs a single attempt and records its outcome in the circuit breaker and metrics
+func (u *Upstream) send(req *http.Request) (*http.Response, error) {
+	done, err := u.cb.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := u.transport.RoundTrip(req)
+	duration := time.Since(start)
+
+	requestInfoFrom(req.Context()).record(u.Name, req.URL.Host, duration)
+	metrics.UpstreamRequestDuration.WithLabelValues(u.Name).Observe(duration.Seconds())
+
+	if err != nil {
+		// A client that went away says nothing about the health of the upstream
+		done(errors.Is(err, context.Canceled))
+		metrics.UpstreamRequestsTotal.WithLabelValues(u.Name, "error").Inc()
+		metrics.UpstreamErrorsTotal.WithLabelValues(u.Name, errorReason(err)).Inc()
+		return nil, err
+	}
+
+	done(resp.StatusCode < http.StatusInternalServerError)
+	metrics.UpstreamRequestsTotal.WithLabelValues(u.Name, metrics.StatusClass(resp.StatusCode)).Inc()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		metrics.UpstreamErrorsTotal.WithLabelValues(u.Name, "server_error").Inc()
+	}
+	return resp, nil
+}
+
+// errorReason classifies a transport error for the upstream error metric
+func errorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "connection"
+	}
+}
+
+var errBodyTooLarge = errors.New("request body too large to retry")
+
+// bufferBody reads the request body into memory so it can be replayed.
+// It returns errBodyTooLarge, leaving the body readable, when the body exceeds maxRetryBodySize.
+func bufferBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxRetryBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(data) > maxRetryBodySize {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+		return nil, errBodyTooLarge
+	}
+
+	req.Body.Close()
+	return data, nil
+}
+
+// isIdempotent reports whether a request with the given method is safe to retry
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isRetryableStatus reports whether a response status indicates a transient upstream failure
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
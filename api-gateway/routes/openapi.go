@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-microservices/api-gateway/aggregator"
+	"go-microservices/api-gateway/gql"
+	inventoryroutes "go-microservices/inventory-service/routes"
+	notificationroutes "go-microservices/notification-service/routes"
+	orderroutes "go-microservices/order-service/routes"
+	paymentroutes "go-microservices/payment-service/routes"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/openapi"
+	productroutes "go-microservices/product-service/routes"
+)
+
+// APIPrefix is where the gateway serves the services' APIs
+const APIPrefix = "/api/v1"
+
+// ProxiedService is a service whose API the gateway forwards under APIPrefix
+type ProxiedService struct {
+	// Name is the upstream's name, which also namespaces its schemas in the
+	// gateway's document
+	Name string
+	// Path is the prefix of the service's routes, e.g. "/products"
+	Path string
+	// Spec documents the service
+	Spec func() *openapi.Document
+}
+
+// Proxied lists the services the gateway forwards requests to
+var Proxied = []ProxiedService{
+	{Name: "product", Path: "/products", Spec: productroutes.Spec},
+	{Name: "order", Path: "/orders", Spec: orderroutes.Spec},
+	{Name: "inventory", Path: "/inventory", Spec: inventoryroutes.Spec},
+	{Name: "notification", Path: "/notifications", Spec: notificationroutes.Spec},
+	{Name: "payment", Path: "/payments", Spec: paymentroutes.Spec},
+}
+
+// graphqlResponse is the body of a GraphQL response
+type graphqlResponse struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+// Spec documents the gateway's own routes and, under APIPrefix, the routes
+// of each service it forwards requests to
+func Spec() (*openapi.Document, error) {
+	doc := openapi.New("Go Microservices API Gateway", "1.0", "Single entry point to the products, orders, inventory, notifications and payments services, with composite views and GraphQL over them.").
+		Tag("views", "Composite views aggregated from several services").
+		Tag("graphql", "GraphQL over the services").
+		Tag("client", "The web frontend").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "GET", Path: APIPrefix + "/views/orders/:id", ID: "getOrderView", Summary: "Get an order with its product, payments and notifications", Tag: "views",
+				Response: aggregator.OrderView{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway, http.StatusGatewayTimeout}},
+			openapi.Route{Method: "GET", Path: APIPrefix + "/graphql", ID: "queryGraphQL", Summary: "Execute a GraphQL query", Tag: "graphql",
+				Params: []openapi.Parameter{
+					{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+					openapi.Query("operationName", "string", "Operation to execute when the query has several"),
+				},
+				Response: graphqlResponse{}, Responses: map[int]interface{}{
+					http.StatusBadRequest:       graphqlResponse{},
+					http.StatusMethodNotAllowed: graphqlResponse{},
+				}},
+			openapi.Route{Method: "POST", Path: APIPrefix + "/graphql", ID: "executeGraphQL", Summary: "Execute a GraphQL query or mutation", Tag: "graphql",
+				Body: gql.Request{}, Response: graphqlResponse{}, Responses: map[int]interface{}{http.StatusBadRequest: graphqlResponse{}}},
+			openapi.Route{Method: "GET", Path: "/", ID: "getClient", Summary: "The web frontend", Tag: "client",
+				Response: "", ContentType: openapi.HTML, Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "GET", Path: "/favicon.ico", ID: "getFavicon", Summary: "The frontend's icon", Tag: "client",
+				Response: "", ContentType: "image/x-icon", Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "GET", Path: "/assets/*filepath", ID: "getAsset", Summary: "A script, stylesheet or image of the frontend", Tag: "client",
+				Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "GET", Path: "/health", ID: "getHealth", Summary: "Basic health check", Tag: "operations",
+				Response: map[string]string{}},
+			openapi.Metrics,
+		).
+		Add(health.Routes()...)
+
+	for _, service := range Proxied {
+		if err := doc.Mount(service.Spec(), APIPrefix, service.Name, service.Path); err != nil {
+			return nil, fmt.Errorf("failed to mount the %s service's document: %w", service.Name, err)
+		}
+	}
+	return doc, nil
+}
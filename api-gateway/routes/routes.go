@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/aggregator"
+	"go-microservices/api-gateway/gql"
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handlers serve the gateway's routes
+type Handlers struct {
+	// Upstreams are the services, keyed by name, e.g. "product"
+	Upstreams map[string]*proxy.Upstream
+	Views     *aggregator.Aggregator
+	GraphQL   *gql.Handler
+	Checker   *health.Checker
+	// ClientDistPath is the directory of the frontend's build output
+	ClientDistPath string
+}
+
+// SetupRoutes configures the gateway's routes
+func SetupRoutes(r *gin.Engine, h Handlers) {
+	// Serve static files from the client/dist directory (Vite build output)
+	r.Static("/assets", h.ClientDistPath+"/assets")
+	r.StaticFile("/", h.ClientDistPath+"/index.html")
+	r.StaticFile("/favicon.ico", h.ClientDistPath+"/favicon.ico")
+
+	// CORS middleware
+	r.Use(func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, If-Match, If-None-Match, If-Modified-Since")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Link, X-Total-Count, ETag, Last-Modified")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Add prometheus metrics endpoint
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+
+	// Liveness and readiness probes
+	h.Checker.RegisterRoutes(r)
+
+	// API routes - Gateway to microservices
+	// V1 API group
+	apiV1 := r.Group("/api/v1")
+
+	// Handle requests to specific microservices
+	for _, service := range Proxied {
+		apiV1.Any(service.Path+"/*path", createReverseProxy(h.Upstreams[service.Name], service.Path))
+	}
+
+	// Composite views for the frontend, aggregated from several services
+	apiV1.GET("/views/orders/:id", h.Views.GetOrderView)
+
+	// GraphQL API over the microservices
+	apiV1.GET("/graphql", h.GraphQL.ServeGraphQL)
+	apiV1.POST("/graphql", h.GraphQL.ServeGraphQL)
+}
+
+// createReverseProxy creates a gin handler function that forwards requests to the specified upstream
+func createReverseProxy(upstream *proxy.Upstream, stripPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Remove the prefix from the path (e.g., /api/v1/products -> /products)
+		path := c.Param("path")
+		if path == "/" {
+			path = ""
+		}
+		c.Request.URL.Path = stripPrefix + path
+
+		// Serve the request using the upstream's pooled proxy
+		upstream.ServeHTTP(c.Writer, c.Request)
+	}
+}
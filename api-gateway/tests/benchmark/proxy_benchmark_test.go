@@ -0,0 +1,128 @@
+package benchmark
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go-microservices/api-gateway/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyReverseProxy reproduces the gateway handler that built a new proxy for every request
+func legacyReverseProxy(serviceURL, stripPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		remote, err := url.Parse(serviceURL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not connect to service"})
+			return
+		}
+
+		proxy := httputil.NewSingleHostReverseProxy(remote)
+		c.Request.URL.Host = remote.Host
+		c.Request.URL.Scheme = remote.Scheme
+
+		path := c.Param("path")
+		if path == "/" {
+			path = ""
+		}
+		c.Request.URL.Path = stripPrefix + path
+
+		log.Printf("Proxying request: %s %s -> %s\n", c.Request.Method, c.Request.URL.String(), serviceURL+path)
+		proxy.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// pooledReverseProxy mirrors the gateway handler that reuses one proxy per upstream
+func pooledReverseProxy(upstream *proxy.Upstream, stripPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Param("path")
+		if path == "/" {
+			path = ""
+		}
+		c.Request.URL.Path = stripPrefix + path
+		upstream.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// newBackend starts a fake product service
+func newBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"Widget","description":"A widget","price":9.99}`))
+	}))
+}
+
+// runGatewayBenchmark drives parallel requests through a gateway and reports latency percentiles
+func runGatewayBenchmark(b *testing.B, handler gin.HandlerFunc) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Any("/api/v1/products/*path", handler)
+
+	gateway := httptest.NewServer(router)
+	defer gateway.Close()
+
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 256}}
+
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, b.N)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		local := make([]time.Duration, 0, 1024)
+		for pb.Next() {
+			start := time.Now()
+			resp, err := client.Get(gateway.URL + "/api/v1/products/1")
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)/2].Microseconds()), "p50-µs")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+	b.ReportMetric(float64(len(latencies))/b.Elapsed().Seconds(), "req/s")
+}
+
+// BenchmarkGatewayProxy compares per-request proxy construction with pooled proxies.
+// Run with: go test ./api-gateway/tests/benchmark -bench . -benchtime 5s
+func BenchmarkGatewayProxy(b *testing.B) {
+	backend := newBackend()
+	defer backend.Close()
+
+	b.Run("PerRequest", func(b *testing.B) {
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(io.Discard)
+		runGatewayBenchmark(b, legacyReverseProxy(backend.URL, "/products"))
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		config := proxy.DefaultConfig()
+		upstream, err := proxy.NewUpstream("product", backend.URL, config, proxy.NewTransport(config))
+		if err != nil {
+			b.Fatal(err)
+		}
+		runGatewayBenchmark(b, pooledReverseProxy(upstream, "/products"))
+	})
+}
@@ -0,0 +1,176 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go-microservices/api-gateway/gql"
+	"go-microservices/pkg/listing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphqlResponse is the decoded body of a GraphQL response
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// setupGraphQL creates a router serving GraphQL over the given services
+func setupGraphQL(t *testing.T, services *gql.Services, limits gql.Limits) *gin.Engine {
+	handler, err := gql.NewHandler(services, limits)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/graphql", handler.ServeGraphQL)
+	router.POST("/api/v1/graphql", handler.ServeGraphQL)
+	return router
+}
+
+// postGraphQL sends a GraphQL query and decodes the response
+func postGraphQL(t *testing.T, router *gin.Engine, query string) (int, graphqlResponse) {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest("POST", "/api/v1/graphql", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp graphqlResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestGraphQL_OrdersWithProductsAreBatched(t *testing.T) {
+	var productCalls int32
+	services := &gql.Services{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[
+				{"id":1,"customer_id":3,"product_id":5,"quantity":1,"status":"pending"},
+				{"id":2,"customer_id":3,"product_id":6,"quantity":2,"status":"shipped"},
+				{"id":3,"customer_id":4,"product_id":5,"quantity":1,"status":"pending"}
+			]`))
+		}),
+		Products: newUpstream(t, "product", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&productCalls, 1)
+			assert.ElementsMatch(t, []string{"5", "6"}, strings.Split(r.URL.Query().Get("ids"), ","))
+			w.Write([]byte(`[{"id":5,"name":"Widget","price":9.99},{"id":6,"name":"Gadget","price":19.99}]`))
+		}),
+	}
+	router := setupGraphQL(t, services, gql.DefaultLimits())
+
+	code, resp := postGraphQL(t, router, `{ orders { id status product { name } } }`)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&productCalls))
+
+	orders := resp.Data["orders"].([]interface{})
+	require.Len(t, orders, 3)
+	assert.Equal(t, "SHIPPED", orders[1].(map[string]interface{})["status"])
+	assert.Equal(t, "Gadget", orders[1].(map[string]interface{})["product"].(map[string]interface{})["name"])
+}
+
+func TestGraphQL_NotificationsAreListedByOrderAndCustomer(t *testing.T) {
+	services := &gql.Services{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id":1,"customer_id":3},{"id":2,"customer_id":3}]`))
+		}),
+		Notifications: newUpstream(t, "notification", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/notifications", r.URL.Path)
+			query := r.URL.Query()
+			if orderID := query.Get("order_id"); orderID != "" {
+				assert.Equal(t, strconv.Itoa(listing.MaxLimit), query.Get("limit"))
+				w.Write([]byte(`[{"id":` + orderID + `,"order_id":` + orderID + `}]`))
+				return
+			}
+			assert.Equal(t, "3", query.Get("customer_id"))
+			assert.Equal(t, "5", query.Get("limit"))
+			w.Write([]byte(`[{"id":1,"order_id":1}]`))
+		}),
+	}
+	router := setupGraphQL(t, services, gql.DefaultLimits())
+
+	code, resp := postGraphQL(t, router, `{ orders { id notifications { id } } notifications(customerId: 3, limit: 5) { id } }`)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp.Errors)
+	orders := resp.Data["orders"].([]interface{})
+	require.Len(t, orders, 2)
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(2)}}, orders[1].(map[string]interface{})["notifications"])
+	assert.Len(t, resp.Data["notifications"], 1)
+}
+
+func TestGraphQL_FailedKeyResolvesToError(t *testing.T) {
+	services := &gql.Services{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id":1,"customer_id":3},{"id":2,"customer_id":3}]`))
+		}),
+		Payments: newUpstream(t, "payment", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/payments/order/2" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`[{"id":5,"order_id":1}]`))
+		}),
+	}
+	router := setupGraphQL(t, services, gql.DefaultLimits())
+
+	code, resp := postGraphQL(t, router, `{ orders { id payments { id } } }`)
+
+	assert.Equal(t, http.StatusOK, code)
+	orders := resp.Data["orders"].([]interface{})
+	require.Len(t, orders, 2)
+	assert.Len(t, orders[0].(map[string]interface{})["payments"], 1)
+	// The failed order's payments are an error rather than an empty list
+	assert.Nil(t, orders[1].(map[string]interface{})["payments"])
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "payment service failed")
+}
+
+func TestGraphQL_CreateOrderMutation(t *testing.T) {
+	services := &gql.Services{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "POST", r.Method)
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"customer_id":3,"product_id":5,"quantity":2}`, string(body))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":10,"customer_id":3,"product_id":5,"quantity":2,"status":"pending"}`))
+		}),
+	}
+	router := setupGraphQL(t, services, gql.DefaultLimits())
+
+	code, resp := postGraphQL(t, router, `mutation { createOrder(input: {customerId: 3, productId: 5, quantity: 2}) { id status } }`)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, float64(10), resp.Data["createOrder"].(map[string]interface{})["id"])
+}
+
+func TestGraphQL_RejectsQueriesOverLimits(t *testing.T) {
+	router := setupGraphQL(t, &gql.Services{}, gql.Limits{MaxDepth: 3, MaxComplexity: 50})
+
+	// payments -> order -> payments -> order is four levels deep
+	code, resp := postGraphQL(t, router, `{ paymentsByOrder(orderId: 1) { order { payments { order { id } } } } }`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.NotEmpty(t, resp.Errors)
+	assert.Contains(t, resp.Errors[0].Message, "depth")
+
+	// Each list multiplies the cost of its selection
+	code, resp = postGraphQL(t, router, `{ orders { id product { id name } payments { id amount } } }`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.NotEmpty(t, resp.Errors)
+	assert.Contains(t, resp.Errors[0].Message, "complexity")
+}
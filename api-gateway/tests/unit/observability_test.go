@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/api-gateway/metrics"
+	"go-microservices/api-gateway/middleware"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog_IncludesUpstream(t *testing.T) {
+	upstream := newUpstream(t, "product", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1}`))
+	})
+
+	var buf bytes.Buffer
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestid.Middleware(), logging.Middleware(), middleware.Metrics(), middleware.AccessLog(slog.New(logging.NewHandler(&buf, logging.Options{}))))
+	router.GET("/api/v1/products/*path", func(c *gin.Context) {
+		c.Request.URL.Path = c.Param("path")
+		upstream.ServeHTTP(c.Writer, c.Request)
+	})
+
+	before := testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues("/api/v1/products/*path", "GET", "2xx"))
+
+	// The reverse proxy needs a real connection, not a response recorder
+	gateway := httptest.NewServer(router)
+
+	req, _ := http.NewRequest("GET", gateway.URL+"/api/v1/products/1", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	req.Header.Set("X-User-ID", "42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Close waits for the handler, and so the access log line, to finish
+	gateway.Close()
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "abc-123", entry["request_id"])
+	assert.Equal(t, "42", entry["user_id"])
+	assert.Equal(t, "/api/v1/products/*path", entry["route"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "product", entry["upstream"])
+	assert.Equal(t, float64(1), entry["upstream_attempts"])
+	assert.Contains(t, entry, "upstream_duration_ms")
+
+	after := testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues("/api/v1/products/*path", "GET", "2xx"))
+	assert.Equal(t, before+1, after)
+}
@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"go-microservices/api-gateway/gql"
+	"go-microservices/api-gateway/routes"
+	inventorycontroller "go-microservices/inventory-service/controller"
+	inventoryroutes "go-microservices/inventory-service/routes"
+	notificationcontroller "go-microservices/notification-service/controller"
+	notificationroutes "go-microservices/notification-service/routes"
+	ordercontroller "go-microservices/order-service/controller"
+	"go-microservices/order-service/resilience"
+	orderroutes "go-microservices/order-service/routes"
+	paymentcontroller "go-microservices/payment-service/controller"
+	paymentroutes "go-microservices/payment-service/routes"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+	productcontroller "go-microservices/product-service/controller"
+	productroutes "go-microservices/product-service/routes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGateway creates a router serving the gateway's routes and document
+func setupGateway(t *testing.T) (*gin.Engine, *openapi.Document) {
+	gin.SetMode(gin.TestMode)
+	graphql, err := gql.NewHandler(&gql.Services{}, gql.DefaultLimits())
+	require.NoError(t, err)
+	spec, err := routes.Spec()
+	require.NoError(t, err)
+
+	router := gin.New()
+	routes.SetupRoutes(router, routes.Handlers{
+		GraphQL: graphql,
+		Checker: health.NewChecker("api-gateway", time.Second, 0),
+	})
+	openapi.Register(router, spec)
+	return router, spec
+}
+
+// setupService creates a router with the routes a service's main registers
+func setupService(spec *openapi.Document, register func(*gin.Engine)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	logging.RegisterAdminRoutes(router, "")
+	health.NewChecker("test", time.Second, 0).RegisterRoutes(router)
+	register(router)
+	openapi.Register(router, spec)
+	return router
+}
+
+func TestSpec_DocumentsEveryGatewayRoute(t *testing.T) {
+	router, spec := setupGateway(t)
+
+	assert.Empty(t, spec.Missing(router.Routes()), "routes missing from the document")
+	assert.Empty(t, spec.Unrouted(router.Routes()), "documented operations without a route")
+}
+
+func TestSpec_DocumentsEveryServiceRoute(t *testing.T) {
+	metrics := gin.WrapH(promhttp.Handler())
+	services := map[string]struct {
+		spec     *openapi.Document
+		register func(*gin.Engine)
+	}{
+		"product": {productroutes.Spec(), func(r *gin.Engine) {
+			productroutes.SetupRoutes(r, productcontroller.NewProductController(nil, nil))
+		}},
+		"order": {orderroutes.Spec(), func(r *gin.Engine) {
+			r.GET("/metrics", metrics)
+			r.GET("/debug/resilience", resilience.Handler)
+			orderroutes.SetupRoutes(r, ordercontroller.NewOrderController(nil, nil, nil, nil), "")
+		}},
+		"inventory": {inventoryroutes.Spec(), func(r *gin.Engine) {
+			inventoryroutes.SetupRoutes(r, inventorycontroller.NewInventoryController(nil), "")
+		}},
+		"notification": {notificationroutes.Spec(), func(r *gin.Engine) {
+			notificationroutes.SetupRoutes(r, notificationcontroller.NewNotificationController(nil))
+		}},
+		"payment": {paymentroutes.Spec(), func(r *gin.Engine) {
+			r.GET("/metrics", metrics)
+			paymentroutes.SetupRoutes(r, paymentcontroller.NewPaymentController(nil, ""), "")
+		}},
+	}
+	require.Len(t, services, len(routes.Proxied))
+
+	for name, service := range services {
+		t.Run(name, func(t *testing.T) {
+			router := setupService(service.spec, service.register)
+
+			assert.Empty(t, service.spec.Missing(router.Routes()), "routes missing from the document")
+			assert.Empty(t, service.spec.Unrouted(router.Routes()), "documented operations without a route")
+		})
+	}
+}
+
+func TestSpec_ServesServicesUnderAPIPrefix(t *testing.T) {
+	router, _ := setupGateway(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	for _, path := range []string{"/api/v1/products/{id}", "/api/v1/orders/{id}/status", "/api/v1/inventory/check",
+		"/api/v1/notifications/customer/{customerId}", "/api/v1/payments/order/{orderId}", "/api/v1/views/orders/{id}"} {
+		assert.Contains(t, doc.Paths, path)
+	}
+	// The services' own operational routes stay internal
+	assert.NotContains(t, doc.Paths, "/api/v1/livez")
+	assert.NotContains(t, doc.Paths, "/api/v1/admin/log-level")
+
+	// Every reference resolves, including those into the services' schemas
+	assert.Contains(t, doc.Components.Schemas, "product.Product")
+	assert.Contains(t, doc.Components.Schemas, "order.Order")
+	for _, match := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		assert.Contains(t, doc.Components.Schemas, match[1])
+	}
+}
+
+func TestSpec_ServesReferencePage(t *testing.T) {
+	router, _ := setupGateway(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `spec-url="openapi.json"`)
+}
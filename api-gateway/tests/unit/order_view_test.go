@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-microservices/api-gateway/aggregator"
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/listing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUpstream creates an upstream for a fake service without retries
+func newUpstream(t *testing.T, name string, handler http.HandlerFunc) *proxy.Upstream {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := proxy.DefaultConfig()
+	config.MaxRetries = 0
+	upstream, err := proxy.NewUpstream(name, server.URL, config, http.DefaultTransport)
+	require.NoError(t, err)
+	return upstream
+}
+
+// setupOrderView creates a router serving the order view from the given upstreams
+func setupOrderView(views *aggregator.Aggregator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/views/orders/:id", views.GetOrderView)
+	return router
+}
+
+func TestGetOrderView_MergesSectionsAndToleratesFailures(t *testing.T) {
+	views := &aggregator.Aggregator{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/orders/7", r.URL.Path)
+			w.Write([]byte(`{"id":7,"customer_id":3,"product_id":5,"quantity":1,"status":"pending"}`))
+		}),
+		Products: newUpstream(t, "product", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/products/5", r.URL.Path)
+			w.Write([]byte(`{"id":5,"name":"Widget","price":9.99}`))
+		}),
+		Payments: newUpstream(t, "payment", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+		Notifications: newUpstream(t, "notification", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/notifications", r.URL.Path)
+			assert.Equal(t, "7", r.URL.Query().Get("order_id"))
+			assert.Equal(t, strconv.Itoa(listing.MaxLimit), r.URL.Query().Get("limit"))
+			w.Write([]byte(`[{"id":1,"order_id":7}]`))
+		}),
+		Timeout: time.Second,
+	}
+
+	w := httptest.NewRecorder()
+	setupOrderView(views).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/views/orders/7", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var view aggregator.OrderView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.JSONEq(t, `{"id":5,"name":"Widget","price":9.99}`, string(view.Product.Data))
+	assert.Empty(t, view.Product.Error)
+	assert.Nil(t, view.Payments.Data)
+	assert.Equal(t, "payment service returned status: 500", view.Payments.Error)
+	assert.JSONEq(t, `[{"id":1,"order_id":7}]`, string(view.Notifications.Data))
+}
+
+func TestGetOrderView_SlowSectionRespectsDeadline(t *testing.T) {
+	views := &aggregator.Aggregator{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":7,"customer_id":3,"product_id":5}`))
+		}),
+		Products: newUpstream(t, "product", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}),
+		Payments: newUpstream(t, "payment", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		}),
+		Notifications: newUpstream(t, "notification", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		}),
+		Timeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	setupOrderView(views).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/views/orders/7", nil))
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var view aggregator.OrderView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, "product service timed out", view.Product.Error)
+	assert.JSONEq(t, `[]`, string(view.Payments.Data))
+}
+
+func TestGetOrderView_OrderNotFound(t *testing.T) {
+	notCalled := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected call to %s", r.URL.Path)
+	}
+	views := &aggregator.Aggregator{
+		Orders: newUpstream(t, "order", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+		Products:      newUpstream(t, "product", notCalled),
+		Payments:      newUpstream(t, "payment", notCalled),
+		Notifications: newUpstream(t, "notification", notCalled),
+		Timeout:       time.Second,
+	}
+
+	w := httptest.NewRecorder()
+	setupOrderView(views).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/views/orders/7", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
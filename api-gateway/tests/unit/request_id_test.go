@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRequestIDGateway proxies /api/v1/products/* to an upstream that records
+// the request ID it receives and echoes it back like the services do
+func setupRequestIDGateway(t *testing.T, received *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = r.Header.Get(requestid.Header)
+		w.Header().Set(requestid.Header, *received)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	upstream, err := proxy.NewUpstream("product", server.URL, proxy.DefaultConfig(), requestid.Transport(http.DefaultTransport))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestid.Middleware())
+	router.GET("/api/v1/products/*path", func(c *gin.Context) {
+		c.Request.URL.Path = c.Param("path")
+		upstream.ServeHTTP(c.Writer, c.Request)
+	})
+
+	gateway := httptest.NewServer(router)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestRequestID_AssignedAndForwarded(t *testing.T) {
+	var received string
+	gateway := setupRequestIDGateway(t, &received)
+
+	resp, err := http.Get(gateway.URL + "/api/v1/products/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	id := resp.Header.Get(requestid.Header)
+	assert.True(t, requestid.Valid(id))
+	assert.Equal(t, id, received)
+	assert.Len(t, resp.Header.Values(requestid.Header), 1)
+}
+
+func TestRequestID_AcceptsCallerIDAndReplacesInvalidOnes(t *testing.T) {
+	var received string
+	gateway := setupRequestIDGateway(t, &received)
+
+	req, _ := http.NewRequest("GET", gateway.URL+"/api/v1/products/1", nil)
+	req.Header.Set(requestid.Header, "client-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "client-42", resp.Header.Get(requestid.Header))
+	assert.Equal(t, "client-42", received)
+
+	req, _ = http.NewRequest("GET", gateway.URL+"/api/v1/products/1", nil)
+	req.Header.Set(requestid.Header, "bad id\twith spaces")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, "bad id\twith spaces", received)
+	assert.True(t, requestid.Valid(received))
+}
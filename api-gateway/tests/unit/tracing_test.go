@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_ProxyPropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var upstreamTraceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	upstream, err := proxy.NewUpstream("product", server.URL, proxy.DefaultConfig(), tracing.Transport(http.DefaultTransport))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware("api-gateway"))
+	router.GET("/api/v1/products/*path", func(c *gin.Context) {
+		c.Request.URL.Path = c.Param("path")
+		upstream.ServeHTTP(c.Writer, c.Request)
+	})
+	gateway := httptest.NewServer(router)
+
+	req, _ := http.NewRequest("GET", gateway.URL+"/api/v1/products/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	gateway.Close()
+
+	// The upstream continues the caller's trace under the gateway's client span
+	require.NotEmpty(t, upstreamTraceparent)
+	assert.Contains(t, upstreamTraceparent, traceID)
+	assert.NotContains(t, upstreamTraceparent, "00f067aa0ba902b7")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+	}
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/api-gateway/proxy"
+	"go-microservices/pkg/problem"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstream_RetriesIdempotentRequestOnAnotherTarget(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer healthy.Close()
+
+	upstream, err := proxy.NewUpstream("product", failing.URL+","+healthy.URL, proxy.DefaultConfig(), http.DefaultTransport)
+	require.NoError(t, err)
+
+	// Both targets are tried for GET, so each request succeeds regardless of the starting target
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		upstream.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestUpstream_DoesNotRetryNonIdempotentRequest(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	upstream, err := proxy.NewUpstream("order", server.URL, proxy.DefaultConfig(), http.DefaultTransport)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	upstream.ServeHTTP(w, httptest.NewRequest("POST", "/orders", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestUpstream_OpenCircuitReturnsServiceUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := proxy.DefaultConfig()
+	config.MaxRetries = 0
+	config.BreakerFailures = 2
+	config.BreakerTimeout = 30 * time.Second
+
+	upstream, err := proxy.NewUpstream("inventory", server.URL, config, http.DefaultTransport)
+	require.NoError(t, err)
+
+	// Trip the breaker
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		upstream.ServeHTTP(w, httptest.NewRequest("GET", "/inventory", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	upstream.ServeHTTP(w, httptest.NewRequest("GET", "/inventory", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	var body problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, problem.CodeUnavailable, body.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, body.Status)
+	assert.Equal(t, "inventory", body.Extensions["upstream"])
+	assert.NotEmpty(t, body.Detail)
+}
+
+func TestUpstream_UnreachableTargetReturnsProblem(t *testing.T) {
+	config := proxy.DefaultConfig()
+	config.MaxRetries = 0
+
+	// Nothing listens on this port
+	upstream, err := proxy.NewUpstream("payment", "http://127.0.0.1:1", config, http.DefaultTransport)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	upstream.ServeHTTP(w, httptest.NewRequest("GET", "/payments/1", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+}
+
+func TestUpstream_NormalizesLegacyErrorResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orders/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Order not found"}`))
+		case "/orders/broken":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"pq: relation \"orders\" does not exist"}`))
+		default:
+			w.Header().Set("Content-Type", problem.ContentType)
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"type":"urn:go-microservices:problem:INSUFFICIENT_STOCK","title":"Insufficient stock","status":409,"code":"INSUFFICIENT_STOCK","product_id":7}`))
+		}
+	}))
+	defer server.Close()
+
+	config := proxy.DefaultConfig()
+	config.MaxRetries = 0
+	upstream, err := proxy.NewUpstream("order", server.URL, config, http.DefaultTransport)
+	require.NoError(t, err)
+
+	serve := func(path string) (*httptest.ResponseRecorder, problem.Problem) {
+		w := httptest.NewRecorder()
+		upstream.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		var body problem.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	w, body := serve("/orders/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.CodeNotFound, body.Code)
+	assert.Equal(t, "Order not found", body.Detail)
+	assert.Equal(t, "order", body.Extensions["upstream"])
+
+	w, body = serve("/orders/broken")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, problem.CodeInternal, body.Code)
+	assert.NotContains(t, w.Body.String(), "pq:")
+
+	// Problem documents from services pass through unchanged
+	w, body = serve("/orders")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, problem.CodeInsufficientStock, body.Code)
+	assert.Equal(t, float64(7), body.Extensions["product_id"])
+	assert.Nil(t, body.Extensions["upstream"])
+}
@@ -1,72 +1,8 @@
--- Create Products Database
+-- Creates one database per service. Tables are owned by each service's
+-- versioned migrations (<service>/db/migrations) and applied on startup
+-- or with "<service> migrate up".
 CREATE DATABASE products_db;
-\c products_db;
-
-CREATE TABLE IF NOT EXISTS products (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(255) NOT NULL,
-    description TEXT,
-    price DECIMAL(10, 2) NOT NULL
-);
-
--- Create Orders Database
 CREATE DATABASE orders_db;
-\c orders_db;
-
-CREATE TABLE IF NOT EXISTS orders (
-    id SERIAL PRIMARY KEY,
-    customer_id INT NOT NULL,
-    product_id INT NOT NULL,
-    quantity INT NOT NULL,
-    total_price DECIMAL(10, 2) NOT NULL,
-    status VARCHAR(50) NOT NULL
-);
-
--- Create Inventory Database
 CREATE DATABASE inventory_db;
-\c inventory_db;
-
-CREATE TABLE IF NOT EXISTS inventory (
-    id SERIAL PRIMARY KEY,
-    product_id INT NOT NULL,
-    quantity INT NOT NULL,
-    sku VARCHAR(50) NOT NULL,
-    location VARCHAR(100)
-);
-
--- Create Notification Database
 CREATE DATABASE notification_db;
-\c notification_db;
-
-CREATE TABLE IF NOT EXISTS notifications (
-    id SERIAL PRIMARY KEY,
-    order_id INT NOT NULL,
-    customer_id INT NOT NULL,
-    message TEXT NOT NULL,
-    status VARCHAR(50) NOT NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    delivered_at TIMESTAMP
-);
-
--- Create Payment Database
 CREATE DATABASE payment_db;
-\c payment_db;
-
-CREATE TABLE IF NOT EXISTS payments (
-    id SERIAL PRIMARY KEY,
-    order_id INTEGER NOT NULL,
-    customer_id INTEGER NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL,
-    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
-    status VARCHAR(50) NOT NULL,
-    stripe_payment_id VARCHAR(255),
-    stripe_client_secret VARCHAR(255),
-    payment_method VARCHAR(50),
-    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
-CREATE INDEX IF NOT EXISTS idx_payments_customer_id ON payments(customer_id);
-CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
-CREATE INDEX IF NOT EXISTS idx_payments_stripe_payment_id ON payments(stripe_payment_id);
@@ -0,0 +1,79 @@
+// Package client is the typed Go client of the inventory service. Its request
+// and response types are the service's own, and the consumers' contract tests
+// run it against the service's handlers, so the two cannot drift apart.
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/apiclient"
+)
+
+// Client calls the inventory service. Failed calls return the service's
+// *problem.Problem when it answered with an error.
+type Client struct {
+	api *apiclient.Client
+}
+
+// New creates a client for the inventory service at baseURL. A nil httpClient
+// selects one that forwards request IDs and trace context.
+func New(baseURL string, httpClient *http.Client) *Client {
+	return &Client{api: apiclient.New(baseURL, httpClient)}
+}
+
+// Check reports whether check.Quantity units of check.ProductID are in stock.
+// A product the service does not stock is reported as unavailable, not as an
+// error.
+func (c *Client) Check(ctx context.Context, check model.InventoryCheck) (*model.InventoryResponse, error) {
+	var resp model.InventoryResponse
+	if err := c.api.Do(ctx, http.MethodPost, "/inventory/check", check, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Get returns the inventory item with the given ID
+func (c *Client) Get(ctx context.Context, id int) (*model.Inventory, error) {
+	var inventory model.Inventory
+	if err := c.api.Do(ctx, http.MethodGet, fmt.Sprintf("/inventory/%d", id), nil, &inventory, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &inventory, nil
+}
+
+// Reserve takes req.Quantity units of req.ProductID out of stock for
+// req.OrderID. It is idempotent: reserving again for the same order returns
+// the existing reservation. Not having the units in stock is an
+// INSUFFICIENT_STOCK problem.
+func (c *Client) Reserve(ctx context.Context, req model.ReservationRequest) (*model.Reservation, error) {
+	var reservation model.Reservation
+	if err := c.api.Do(ctx, http.MethodPost, "/inventory/reservations", req, &reservation, http.StatusCreated, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Release puts the stock reserved for an order back. Releasing again changes
+// nothing; an order without a reservation is a RESERVATION_NOT_FOUND problem.
+func (c *Client) Release(ctx context.Context, orderID int) (*model.Reservation, error) {
+	var reservation model.Reservation
+	path := fmt.Sprintf("/inventory/reservations/%d/release", orderID)
+	if err := c.api.Do(ctx, http.MethodPost, path, nil, &reservation, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Restock puts the units of an order return back into stock. It is
+// idempotent: restocking the same return again returns the existing restock.
+// A product without an inventory item is an INVENTORY_NOT_FOUND problem.
+func (c *Client) Restock(ctx context.Context, req model.RestockRequest) (*model.Restock, error) {
+	var restock model.Restock
+	if err := c.api.Do(ctx, http.MethodPost, "/inventory/restocks", req, &restock, http.StatusCreated, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &restock, nil
+}
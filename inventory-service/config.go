@@ -0,0 +1,25 @@
+package main
+
+import "go-microservices/pkg/config"
+
+// Config holds the inventory service settings
+type Config struct {
+	HTTP     config.HTTP
+	Database config.Database
+	Admin    config.Admin
+	Service  config.Service
+	Health   config.Health
+	Shutdown config.Shutdown
+}
+
+// loadConfig reads the inventory service settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:     config.HTTP{Port: 8082},
+		Database: config.DefaultDatabase("inventory_db"),
+		Health:   config.DefaultHealth(),
+		Shutdown: config.DefaultShutdown(),
+	}
+	config.MustLoad("inventory-service", &cfg)
+	return cfg
+}
@@ -2,10 +2,13 @@ package controller
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,18 +26,17 @@ func NewInventoryController(db *sql.DB) *InventoryController {
 // CreateInventory handles creation of a new inventory item
 func (ic *InventoryController) CreateInventory(c *gin.Context) {
 	var inventory model.Inventory
-	if err := c.BindJSON(&inventory); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &inventory) {
 		return
 	}
 
 	var id int
-	err := ic.DB.QueryRow(
+	err := ic.DB.QueryRowContext(c.Request.Context(),
 		"INSERT INTO inventory (product_id, quantity, sku, location) VALUES ($1, $2, $3, $4) RETURNING id",
 		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location).Scan(&id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to create inventory item", err)
 		return
 	}
 
@@ -42,26 +44,51 @@ func (ic *InventoryController) CreateInventory(c *gin.Context) {
 	c.JSON(http.StatusCreated, inventory)
 }
 
-// GetInventories returns all inventory items
+// inventoryListing describes how inventory items are filtered, sorted and paged
+var inventoryListing = &listing.Spec[model.Inventory]{
+	Table:   "inventory",
+	Columns: "id, product_id, quantity, sku, location",
+	Key:     "id",
+	KeyOf:   func(i model.Inventory) int64 { return int64(i.ID) },
+	Filters: []listing.Filter{
+		{Param: "location", Column: "location", Op: listing.Eq, Kind: listing.String},
+		{Param: "product_id", Column: "product_id", Op: listing.Eq, Kind: listing.Int},
+		{Param: "sku", Column: "sku", Op: listing.Eq, Kind: listing.String},
+	},
+	Sorts: map[string]listing.Field[model.Inventory]{
+		"id":       {Column: "id", Kind: listing.Int, Value: func(i model.Inventory) interface{} { return i.ID }},
+		"quantity": {Column: "quantity", Kind: listing.Int, Value: func(i model.Inventory) interface{} { return i.Quantity }},
+		"sku":      {Column: "sku", Kind: listing.String, Value: func(i model.Inventory) interface{} { return i.SKU }},
+	},
+	DefaultSort: "id",
+}
+
+// InventoryListParams describes the query parameters GetInventories accepts
+func InventoryListParams() []listing.Param {
+	return inventoryListing.Params()
+}
+
+// scanInventory reads an inventory row selected with inventoryListing's columns
+func scanInventory(rows *sql.Rows) (model.Inventory, error) {
+	var i model.Inventory
+	err := rows.Scan(&i.ID, &i.ProductID, &i.Quantity, &i.SKU, &i.Location)
+	return i, err
+}
+
+// GetInventories returns a page of inventory items
 func (ic *InventoryController) GetInventories(c *gin.Context) {
-	rows, err := ic.DB.Query("SELECT id, product_id, quantity, sku, location FROM inventory")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	req, p := inventoryListing.Parse(c.Request.URL.Query())
+	if p != nil {
+		problem.Write(c, p)
 		return
 	}
-	defer rows.Close()
 
-	var inventories []model.Inventory
-	for rows.Next() {
-		var i model.Inventory
-		if err := rows.Scan(&i.ID, &i.ProductID, &i.Quantity, &i.SKU, &i.Location); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		inventories = append(inventories, i)
+	page, err := listing.List(c.Request.Context(), ic.DB, req, scanInventory)
+	if err != nil {
+		problem.Internal(c, "Failed to list inventory", err)
+		return
 	}
-
-	c.JSON(http.StatusOK, inventories)
+	listing.Respond(c, page)
 }
 
 // GetInventory returns a specific inventory item by ID
@@ -69,15 +96,15 @@ func (ic *InventoryController) GetInventory(c *gin.Context) {
 	id := c.Param("id")
 	var inventory model.Inventory
 
-	err := ic.DB.QueryRow("SELECT id, product_id, quantity, sku, location FROM inventory WHERE id = $1", id).
+	err := ic.DB.QueryRowContext(c.Request.Context(), "SELECT id, product_id, quantity, sku, location FROM inventory WHERE id = $1", id).
 		Scan(&inventory.ID, &inventory.ProductID, &inventory.Quantity, &inventory.SKU, &inventory.Location)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+		problem.Abort(c, problem.CodeInventoryNotFound, fmt.Sprintf("Inventory item %s does not exist", id))
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to get inventory item", err)
 		return
 	}
 
@@ -88,27 +115,26 @@ func (ic *InventoryController) GetInventory(c *gin.Context) {
 func (ic *InventoryController) UpdateInventory(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
 		return
 	}
 
 	var inventory model.Inventory
-	if err := c.BindJSON(&inventory); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &inventory) {
 		return
 	}
 
-	result, err := ic.DB.Exec(
+	result, err := ic.DB.ExecContext(c.Request.Context(),
 		"UPDATE inventory SET product_id = $1, quantity = $2, sku = $3, location = $4 WHERE id = $5",
 		inventory.ProductID, inventory.Quantity, inventory.SKU, inventory.Location, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to update inventory item", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+		problem.Abort(c, problem.CodeInventoryNotFound, fmt.Sprintf("Inventory item %d does not exist", id))
 		return
 	}
 
@@ -120,15 +146,15 @@ func (ic *InventoryController) UpdateInventory(c *gin.Context) {
 func (ic *InventoryController) DeleteInventory(c *gin.Context) {
 	id := c.Param("id")
 
-	result, err := ic.DB.Exec("DELETE FROM inventory WHERE id = $1", id)
+	result, err := ic.DB.ExecContext(c.Request.Context(), "DELETE FROM inventory WHERE id = $1", id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to delete inventory item", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory item not found"})
+		problem.Abort(c, problem.CodeInventoryNotFound, fmt.Sprintf("Inventory item %s does not exist", id))
 		return
 	}
 
@@ -138,13 +164,12 @@ func (ic *InventoryController) DeleteInventory(c *gin.Context) {
 // CheckInventory checks if there's enough inventory for a product
 func (ic *InventoryController) CheckInventory(c *gin.Context) {
 	var check model.InventoryCheck
-	if err := c.BindJSON(&check); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &check) {
 		return
 	}
 
 	var quantity int
-	err := ic.DB.QueryRow("SELECT quantity FROM inventory WHERE product_id = $1", check.ProductID).Scan(&quantity)
+	err := ic.DB.QueryRowContext(c.Request.Context(), "SELECT quantity FROM inventory WHERE product_id = $1", check.ProductID).Scan(&quantity)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusOK, model.InventoryResponse{
@@ -154,7 +179,7 @@ func (ic *InventoryController) CheckInventory(c *gin.Context) {
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to check inventory", err)
 		return
 	}
 
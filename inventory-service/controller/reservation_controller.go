@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reservationColumns are the columns scanReservation reads
+const reservationColumns = "order_id, product_id, inventory_id, quantity, created_at, released_at"
+
+// scanReservation reads a reservation row selected with reservationColumns
+func scanReservation(row *sql.Row) (*model.Reservation, error) {
+	var r model.Reservation
+	if err := row.Scan(&r.OrderID, &r.ProductID, &r.InventoryID, &r.Quantity, &r.CreatedAt, &r.ReleasedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// reservation returns the reservation of an order
+func (ic *InventoryController) reservation(ctx context.Context, orderID int) (*model.Reservation, error) {
+	return scanReservation(ic.DB.QueryRowContext(ctx,
+		"SELECT "+reservationColumns+" FROM inventory_reservations WHERE order_id = $1", orderID))
+}
+
+// ReserveStock takes units of a product out of stock for an order. An order
+// reserves once: reserving again returns its reservation with 200 instead of
+// 201, so callers can retry.
+func (ic *InventoryController) ReserveStock(c *gin.Context) {
+	var req model.ReservationRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	existing, err := ic.reservation(ctx, req.OrderID)
+	if err == nil {
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	if err != sql.ErrNoRows {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+
+	tx, err := ic.DB.BeginTx(ctx, nil)
+	if err != nil {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Take the units from the item with the most stock, locked so that
+	// concurrent reservations can't sell the same units twice
+	var inventoryID int
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM inventory WHERE product_id = $1 AND quantity >= $2 ORDER BY quantity DESC, id LIMIT 1 FOR UPDATE",
+		req.ProductID, req.Quantity).Scan(&inventoryID)
+	if err == sql.ErrNoRows {
+		p := problem.New(problem.CodeInsufficientStock, fmt.Sprintf("Product %d does not have %d units in stock", req.ProductID, req.Quantity))
+		problem.Write(c, p.With("product_id", req.ProductID))
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE inventory SET quantity = quantity - $1 WHERE id = $2", req.Quantity, inventoryID); err != nil {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+
+	reservation, err := scanReservation(tx.QueryRowContext(ctx,
+		`INSERT INTO inventory_reservations (order_id, product_id, inventory_id, quantity, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (order_id) DO NOTHING
+		RETURNING `+reservationColumns,
+		req.OrderID, req.ProductID, inventoryID, req.Quantity))
+	if err == sql.ErrNoRows {
+		// A concurrent request reserved for the order first: undo this one
+		tx.Rollback()
+		if existing, err = ic.reservation(ctx, req.OrderID); err != nil {
+			problem.Internal(c, "Failed to reserve stock", err)
+			return
+		}
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		problem.Internal(c, "Failed to reserve stock", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// ReleaseStock puts the units reserved for an order back into the item they
+// were taken from. Releasing a released reservation changes nothing, so
+// callers can retry.
+func (ic *InventoryController) ReleaseStock(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("orderId"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "Order ID must be an integer")
+		return
+	}
+	ctx := c.Request.Context()
+
+	tx, err := ic.DB.BeginTx(ctx, nil)
+	if err != nil {
+		problem.Internal(c, "Failed to release stock", err)
+		return
+	}
+	defer tx.Rollback()
+
+	reservation, err := scanReservation(tx.QueryRowContext(ctx,
+		"UPDATE inventory_reservations SET released_at = NOW() WHERE order_id = $1 AND released_at IS NULL RETURNING "+reservationColumns,
+		orderID))
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		reservation, err = ic.reservation(ctx, orderID)
+		if err == sql.ErrNoRows {
+			problem.Abort(c, problem.CodeReservationNotFound, fmt.Sprintf("Order %d has no reserved stock", orderID))
+			return
+		}
+		if err != nil {
+			problem.Internal(c, "Failed to release stock", err)
+			return
+		}
+		c.JSON(http.StatusOK, reservation)
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to release stock", err)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE inventory SET quantity = quantity + $1 WHERE id = $2", reservation.Quantity, reservation.InventoryID); err != nil {
+		problem.Internal(c, "Failed to release stock", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		problem.Internal(c, "Failed to release stock", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restockColumns are the columns scanRestock reads
+const restockColumns = "return_id, order_id, product_id, inventory_id, quantity, created_at"
+
+// scanRestock reads a restock row selected with restockColumns
+func scanRestock(row *sql.Row) (*model.Restock, error) {
+	var r model.Restock
+	if err := row.Scan(&r.ReturnID, &r.OrderID, &r.ProductID, &r.InventoryID, &r.Quantity, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// restock returns the restock of an order return
+func (ic *InventoryController) restock(ctx context.Context, returnID int) (*model.Restock, error) {
+	return scanRestock(ic.DB.QueryRowContext(ctx,
+		"SELECT "+restockColumns+" FROM inventory_restocks WHERE return_id = $1", returnID))
+}
+
+// RestockReturn puts the units of an order return back into stock: into the
+// item the order's units were reserved from, or else the product's first
+// item. A return restocks once: restocking again returns its restock with 200
+// instead of 201, so callers can retry.
+func (ic *InventoryController) RestockReturn(c *gin.Context) {
+	var req model.RestockRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	existing, err := ic.restock(ctx, req.ReturnID)
+	if err == nil {
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	if err != sql.ErrNoRows {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+
+	tx, err := ic.DB.BeginTx(ctx, nil)
+	if err != nil {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var inventoryID int
+	err = tx.QueryRowContext(ctx,
+		`SELECT i.id FROM inventory i
+		LEFT JOIN inventory_reservations r ON r.inventory_id = i.id AND r.order_id = $2
+		WHERE i.product_id = $1
+		ORDER BY r.order_id IS NULL, i.id LIMIT 1 FOR UPDATE OF i`,
+		req.ProductID, req.OrderID).Scan(&inventoryID)
+	if err == sql.ErrNoRows {
+		p := problem.New(problem.CodeInventoryNotFound, fmt.Sprintf("Product %d has no inventory item to restock", req.ProductID))
+		problem.Write(c, p.With("product_id", req.ProductID))
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE inventory SET quantity = quantity + $1 WHERE id = $2", req.Quantity, inventoryID); err != nil {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+
+	restock, err := scanRestock(tx.QueryRowContext(ctx,
+		`INSERT INTO inventory_restocks (return_id, order_id, product_id, inventory_id, quantity, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (return_id) DO NOTHING
+		RETURNING `+restockColumns,
+		req.ReturnID, req.OrderID, req.ProductID, inventoryID, req.Quantity))
+	if err == sql.ErrNoRows {
+		// A concurrent request restocked the return first: undo this one
+		tx.Rollback()
+		if existing, err = ic.restock(ctx, req.ReturnID); err != nil {
+			problem.Internal(c, "Failed to restock return", err)
+			return
+		}
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		problem.Internal(c, "Failed to restock return", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, restock)
+}
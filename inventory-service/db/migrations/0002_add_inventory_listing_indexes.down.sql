@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_inventory_product_id;
+DROP INDEX IF EXISTS idx_inventory_location;
@@ -0,0 +1,3 @@
+-- Indexes for the list filters
+CREATE INDEX IF NOT EXISTS idx_inventory_location ON inventory(location);
+CREATE INDEX IF NOT EXISTS idx_inventory_product_id ON inventory(product_id);
@@ -0,0 +1,10 @@
+-- Stock held for orders. Reserving takes units out of an inventory row and
+-- releasing puts them back into the same row; an order reserves at most once.
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+    order_id INT PRIMARY KEY,
+    product_id INT NOT NULL,
+    inventory_id INT NOT NULL,
+    quantity INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP
+);
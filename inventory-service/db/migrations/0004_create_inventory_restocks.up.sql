@@ -0,0 +1,10 @@
+-- Returned units put back into stock. Each order return restocks once, into
+-- the item the order's units were reserved from when there is one.
+CREATE TABLE IF NOT EXISTS inventory_restocks (
+    return_id INT PRIMARY KEY,
+    order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    inventory_id INT NOT NULL,
+    quantity INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
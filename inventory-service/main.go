@@ -1,35 +1,84 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 
 	"go-microservices/inventory-service/controller"
 	"go-microservices/inventory-service/db"
 	"go-microservices/inventory-service/routes"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/lifecycle"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	logging.Init("inventory-service")
+	cfg := loadConfig()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "inventory-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
 	// Initialize database connection
-	database := db.GetDB()
-	defer database.Close()
+	database := db.GetDB(cfg.Database)
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// "migrate <command>" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := migrate.Main(context.Background(), db.NewMigrator(database), os.Args[2:])
+		database.Close()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		db.Migrate(database)
+	} else {
+		slog.Info("Skipping schema migrations", "reason", "DB_AUTO_MIGRATE=false")
+	}
 
 	// Create inventory controller
 	inventoryController := controller.NewInventoryController(database)
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware(), tracing.Middleware("inventory-service"), logging.Middleware(), logging.AccessLog())
+	router.NoRoute(problem.NoRoute)
+
+	// Add log level admin endpoint
+	logging.RegisterAdminRoutes(router, cfg.Admin.Token)
+
+	// Add liveness and readiness probes
+	checker := health.NewChecker("inventory-service", cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: health.SQL(database)})
+	checker.RegisterRoutes(router)
 
 	// Setup routes
-	routes.SetupRoutes(router, inventoryController)
+	routes.SetupRoutes(router, inventoryController, cfg.Service.Token)
+
+	// Serve the OpenAPI document and its reference page
+	openapi.Register(router, routes.Spec())
 
 	// Start server
-	log.Println("Inventory Service starting on port 8082...")
-	if err := router.Run(":8082"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	slog.Info("Inventory Service starting", "port", cfg.HTTP.Port)
+	server := lifecycle.NewServer(cfg.HTTP.Addr(), router, cfg.Shutdown)
+	server.OnDrain(func() { checker.SetDraining(true) })
+	server.OnShutdown("database", lifecycle.Closer(database.Close))
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(); err != nil {
+		logging.Fatal("Server shutdown failed", "error", err)
 	}
 }
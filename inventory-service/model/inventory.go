@@ -1,18 +1,20 @@
 package model
 
+import "time"
+
 // Inventory represents an inventory item for a product
 type Inventory struct {
 	ID        int    `json:"id"`
-	ProductID int    `json:"product_id"`
-	Quantity  int    `json:"quantity"`
-	SKU       string `json:"sku"`
-	Location  string `json:"location"`
+	ProductID int    `json:"product_id" binding:"required,gt=0"`
+	Quantity  int    `json:"quantity" binding:"gte=0"`
+	SKU       string `json:"sku" binding:"required,sku"`
+	Location  string `json:"location" binding:"max=100"`
 }
 
 // InventoryCheck is used for checking if an order can be fulfilled
 type InventoryCheck struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int `json:"product_id" binding:"required,gt=0"`
+	Quantity  int `json:"quantity" binding:"required,gt=0"`
 }
 
 // InventoryResponse is the response when checking inventory
@@ -20,3 +22,40 @@ type InventoryResponse struct {
 	Available bool   `json:"available"`
 	Message   string `json:"message,omitempty"`
 }
+
+// ReservationRequest asks to hold stock of a product for an order
+type ReservationRequest struct {
+	OrderID   int `json:"order_id" binding:"required,gt=0"`
+	ProductID int `json:"product_id" binding:"required,gt=0"`
+	Quantity  int `json:"quantity" binding:"required,gt=0"`
+}
+
+// Reservation is stock taken out of an inventory item for an order. Releasing
+// it puts the units back.
+type Reservation struct {
+	OrderID     int        `json:"order_id"`
+	ProductID   int        `json:"product_id"`
+	InventoryID int        `json:"inventory_id"`
+	Quantity    int        `json:"quantity"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+}
+
+// RestockRequest asks to put the units of an order return back into stock
+type RestockRequest struct {
+	ReturnID  int `json:"return_id" binding:"required,gt=0"`
+	OrderID   int `json:"order_id" binding:"required,gt=0"`
+	ProductID int `json:"product_id" binding:"required,gt=0"`
+	Quantity  int `json:"quantity" binding:"required,gt=0"`
+}
+
+// Restock is a stock movement that put returned units back into an
+// inventory item
+type Restock struct {
+	ReturnID    int       `json:"return_id"`
+	OrderID     int       `json:"order_id"`
+	ProductID   int       `json:"product_id"`
+	InventoryID int       `json:"inventory_id"`
+	Quantity    int       `json:"quantity"`
+	CreatedAt   time.Time `json:"created_at"`
+}
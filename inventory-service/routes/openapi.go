@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"net/http"
+
+	"go-microservices/inventory-service/controller"
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/servicetoken"
+)
+
+// Spec documents every route the inventory service serves
+func Spec() *openapi.Document {
+	service := []openapi.Parameter{servicetoken.Param()}
+
+	return openapi.New("Inventory Service API", "1.0", "Stock levels of products, and availability checks for new orders.").
+		Tag("inventory", "Inventory items and availability checks").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "POST", Path: "/inventory", ID: "createInventory", Summary: "Create an inventory item", Tag: "inventory",
+				Body: model.Inventory{}, Status: http.StatusCreated, Response: model.Inventory{},
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/inventory", ID: "listInventory", Summary: "List inventory items", Tag: "inventory",
+				Params: openapi.ListParams(controller.InventoryListParams()), Response: []model.Inventory{}, Headers: openapi.ListHeaders,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/inventory/:id", ID: "getInventory", Summary: "Get an inventory item", Tag: "inventory",
+				Response: model.Inventory{}, Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "PUT", Path: "/inventory/:id", ID: "updateInventory", Summary: "Replace an inventory item", Tag: "inventory",
+				Body: model.Inventory{}, Response: model.Inventory{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+			openapi.Route{Method: "DELETE", Path: "/inventory/:id", ID: "deleteInventory", Summary: "Delete an inventory item", Tag: "inventory",
+				Response: openapi.Message{}, Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "POST", Path: "/inventory/check", ID: "checkInventory", Summary: "Check whether a quantity of a product is in stock", Tag: "inventory",
+				Body: model.InventoryCheck{}, Response: model.InventoryResponse{}, Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "POST", Path: "/inventory/reservations", ID: "reserveStock", Summary: "Take stock out of inventory for an order", Tag: "inventory",
+				Params: service, Body: model.ReservationRequest{}, Status: http.StatusCreated, Response: model.Reservation{},
+				Responses: map[int]interface{}{http.StatusOK: model.Reservation{}},
+				Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict}},
+			openapi.Route{Method: "POST", Path: "/inventory/reservations/:orderId/release", ID: "releaseStock", Summary: "Put an order's reserved stock back", Tag: "inventory",
+				Params: service, Response: model.Reservation{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound}},
+			openapi.Route{Method: "POST", Path: "/inventory/restocks", ID: "restockReturn", Summary: "Put the units of an order return back into stock", Tag: "inventory",
+				Params: service, Body: model.RestockRequest{}, Status: http.StatusCreated, Response: model.Restock{},
+				Responses: map[int]interface{}{http.StatusOK: model.Restock{}},
+				Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound}},
+		).
+		Add(health.Routes()...).
+		Add(logging.AdminRoutes()...)
+}
@@ -2,12 +2,14 @@ package routes
 
 import (
 	"go-microservices/inventory-service/controller"
+	"go-microservices/pkg/servicetoken"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures the API routes for the inventory service
-func SetupRoutes(router *gin.Engine, inventoryController *controller.InventoryController) {
+// SetupRoutes configures the API routes for the inventory service. The routes
+// only the order service calls require serviceToken.
+func SetupRoutes(router *gin.Engine, inventoryController *controller.InventoryController, serviceToken string) {
 	// Inventory routes
 	router.POST("/inventory", inventoryController.CreateInventory)
 	router.GET("/inventory", inventoryController.GetInventories)
@@ -17,4 +19,12 @@ func SetupRoutes(router *gin.Engine, inventoryController *controller.InventoryCo
 
 	// Inventory check route for order service
 	router.POST("/inventory/check", inventoryController.CheckInventory)
+
+	// Stock reserved for orders, released when an order is cancelled
+	service := servicetoken.Authorize(serviceToken)
+	router.POST("/inventory/reservations", service, inventoryController.ReserveStock)
+	router.POST("/inventory/reservations/:orderId/release", service, inventoryController.ReleaseStock)
+
+	// Returned units put back into stock
+	router.POST("/inventory/restocks", service, inventoryController.RestockReturn)
 }
@@ -0,0 +1,44 @@
+// Package client is the typed Go client of the notification service. Its
+// request and response types are the service's own, and the consumers'
+// contract tests run it against the service's handlers, so the two cannot
+// drift apart.
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-microservices/notification-service/model"
+	"go-microservices/pkg/apiclient"
+)
+
+// Client calls the notification service. Failed calls return the service's
+// *problem.Problem when it answered with an error.
+type Client struct {
+	api *apiclient.Client
+}
+
+// New creates a client for the notification service at baseURL. A nil
+// httpClient selects one that forwards request IDs and trace context.
+func New(baseURL string, httpClient *http.Client) *Client {
+	return &Client{api: apiclient.New(baseURL, httpClient)}
+}
+
+// OrderStatus tells the customer that their order has reached update.Status
+func (c *Client) OrderStatus(ctx context.Context, update model.OrderStatusUpdate) (*model.OrderStatusResult, error) {
+	var result model.OrderStatusResult
+	if err := c.api.Do(ctx, http.MethodPost, "/notifications/order-status", update, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Get returns the notification with the given ID
+func (c *Client) Get(ctx context.Context, id int) (*model.Notification, error) {
+	var notification model.Notification
+	if err := c.api.Do(ctx, http.MethodGet, fmt.Sprintf("/notifications/%d", id), nil, &notification, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
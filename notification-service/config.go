@@ -0,0 +1,24 @@
+package main
+
+import "go-microservices/pkg/config"
+
+// Config holds the notification service settings
+type Config struct {
+	HTTP     config.HTTP
+	Database config.Database
+	Admin    config.Admin
+	Health   config.Health
+	Shutdown config.Shutdown
+}
+
+// loadConfig reads the notification service settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:     config.HTTP{Port: 8083},
+		Database: config.DefaultDatabase("notification_db"),
+		Health:   config.DefaultHealth(),
+		Shutdown: config.DefaultShutdown(),
+	}
+	config.MustLoad("notification-service", &cfg)
+	return cfg
+}
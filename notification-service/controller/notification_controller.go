@@ -8,10 +8,21 @@ import (
 	"time"
 
 	"go-microservices/notification-service/model"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 )
 
+// returnMessages are the messages for the steps of an order return
+var returnMessages = map[string]string{
+	"return_requested": "We have received your return request for order #%d",
+	"return_approved":  "Your return for order #%d has been approved; please send the items back",
+	"return_rejected":  "Your return for order #%d has been rejected",
+	"return_received":  "We have received the items you returned from order #%d",
+	"return_refunded":  "Your return for order #%d has been refunded",
+}
+
 // NotificationController handles notification-related requests
 type NotificationController struct {
 	DB *sql.DB
@@ -25,20 +36,19 @@ func NewNotificationController(db *sql.DB) *NotificationController {
 // CreateNotification handles creation of a new notification
 func (nc *NotificationController) CreateNotification(c *gin.Context) {
 	var notification model.Notification
-	if err := c.BindJSON(&notification); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &notification) {
 		return
 	}
 
 	notification.CreatedAt = time.Now()
 
 	var id int
-	err := nc.DB.QueryRow(
+	err := nc.DB.QueryRowContext(c.Request.Context(),
 		"INSERT INTO notifications (order_id, customer_id, message, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
 		notification.OrderID, notification.CustomerID, notification.Message, notification.Status, notification.CreatedAt).Scan(&id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to create notification", err)
 		return
 	}
 
@@ -46,30 +56,56 @@ func (nc *NotificationController) CreateNotification(c *gin.Context) {
 	c.JSON(http.StatusCreated, notification)
 }
 
-// GetNotifications returns all notifications
+// notificationListing describes how notifications are filtered, sorted and paged
+var notificationListing = &listing.Spec[model.Notification]{
+	Table:   "notifications",
+	Columns: "id, order_id, customer_id, message, status, created_at, delivered_at",
+	Key:     "id",
+	KeyOf:   func(n model.Notification) int64 { return int64(n.ID) },
+	Filters: []listing.Filter{
+		{Param: "status", Column: "status", Op: listing.Eq, Kind: listing.String},
+		{Param: "order_id", Column: "order_id", Op: listing.Eq, Kind: listing.Int},
+		{Param: "customer_id", Column: "customer_id", Op: listing.Eq, Kind: listing.Int},
+	},
+	Sorts: map[string]listing.Field[model.Notification]{
+		"id":         {Column: "id", Kind: listing.Int, Value: func(n model.Notification) interface{} { return n.ID }},
+		"created_at": {Column: "created_at", Kind: listing.Time, Value: func(n model.Notification) interface{} { return n.CreatedAt }},
+	},
+	DefaultSort: "-created_at",
+}
+
+// NotificationListParams describes the query parameters GetNotifications accepts
+func NotificationListParams() []listing.Param {
+	return notificationListing.Params()
+}
+
+// scanNotification reads a notification row selected with notificationListing's columns
+func scanNotification(rows *sql.Rows) (model.Notification, error) {
+	var n model.Notification
+	var deliveredAt sql.NullTime
+	if err := rows.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt); err != nil {
+		return n, err
+	}
+	if deliveredAt.Valid {
+		n.DeliveredAt = deliveredAt.Time
+	}
+	return n, nil
+}
+
+// GetNotifications returns a page of notifications, newest first unless sorted otherwise
 func (nc *NotificationController) GetNotifications(c *gin.Context) {
-	rows, err := nc.DB.Query("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	req, p := notificationListing.Parse(c.Request.URL.Query())
+	if p != nil {
+		problem.Write(c, p)
 		return
 	}
-	defer rows.Close()
 
-	var notifications []model.Notification
-	for rows.Next() {
-		var n model.Notification
-		var deliveredAt sql.NullTime
-		if err := rows.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if deliveredAt.Valid {
-			n.DeliveredAt = deliveredAt.Time
-		}
-		notifications = append(notifications, n)
+	page, err := listing.List(c.Request.Context(), nc.DB, req, scanNotification)
+	if err != nil {
+		problem.Internal(c, "Failed to list notifications", err)
+		return
 	}
-
-	c.JSON(http.StatusOK, notifications)
+	listing.Respond(c, page)
 }
 
 // GetNotification returns a specific notification by ID
@@ -78,15 +114,15 @@ func (nc *NotificationController) GetNotification(c *gin.Context) {
 	var notification model.Notification
 	var deliveredAt sql.NullTime
 
-	err := nc.DB.QueryRow("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications WHERE id = $1", id).
+	err := nc.DB.QueryRowContext(c.Request.Context(), "SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications WHERE id = $1", id).
 		Scan(&notification.ID, &notification.OrderID, &notification.CustomerID, &notification.Message, &notification.Status, &notification.CreatedAt, &deliveredAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		problem.Abort(c, problem.CodeNotificationNotFound, fmt.Sprintf("Notification %s does not exist", id))
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to get notification", err)
 		return
 	}
 
@@ -97,51 +133,47 @@ func (nc *NotificationController) GetNotification(c *gin.Context) {
 	c.JSON(http.StatusOK, notification)
 }
 
-// GetCustomerNotifications returns all notifications for a customer
+// GetCustomerNotifications returns a page of a customer's notifications, newest first unless sorted otherwise
 func (nc *NotificationController) GetCustomerNotifications(c *gin.Context) {
-	customerID := c.Param("customerId")
-	rows, err := nc.DB.Query("SELECT id, order_id, customer_id, message, status, created_at, delivered_at FROM notifications WHERE customer_id = $1", customerID)
+	customerID, err := strconv.Atoi(c.Param("customerId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Abort(c, problem.CodeInvalidID, "Customer ID must be an integer")
 		return
 	}
-	defer rows.Close()
 
-	var notifications []model.Notification
-	for rows.Next() {
-		var n model.Notification
-		var deliveredAt sql.NullTime
-		if err := rows.Scan(&n.ID, &n.OrderID, &n.CustomerID, &n.Message, &n.Status, &n.CreatedAt, &deliveredAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if deliveredAt.Valid {
-			n.DeliveredAt = deliveredAt.Time
-		}
-		notifications = append(notifications, n)
+	req, p := notificationListing.Parse(c.Request.URL.Query())
+	if p != nil {
+		problem.Write(c, p)
+		return
 	}
+	req.Where("customer_id", customerID)
 
-	c.JSON(http.StatusOK, notifications)
+	page, err := listing.List(c.Request.Context(), nc.DB, req, scanNotification)
+	if err != nil {
+		problem.Internal(c, "Failed to list customer notifications", err)
+		return
+	}
+	listing.Respond(c, page)
 }
 
 // MarkDelivered marks a notification as delivered
 func (nc *NotificationController) MarkDelivered(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
 		return
 	}
 
 	now := time.Now()
-	result, err := nc.DB.Exec("UPDATE notifications SET delivered_at = $1 WHERE id = $2", now, id)
+	result, err := nc.DB.ExecContext(c.Request.Context(), "UPDATE notifications SET delivered_at = $1 WHERE id = $2", now, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to mark notification delivered", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		problem.Abort(c, problem.CodeNotificationNotFound, fmt.Sprintf("Notification %d does not exist", id))
 		return
 	}
 
@@ -151,28 +183,30 @@ func (nc *NotificationController) MarkDelivered(c *gin.Context) {
 // ProcessOrderStatusUpdate processes an order status update and creates a notification
 func (nc *NotificationController) ProcessOrderStatusUpdate(c *gin.Context) {
 	var update model.OrderStatusUpdate
-	if err := c.BindJSON(&update); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &update) {
 		return
 	}
 
 	// Create a notification from the status update
 	message := fmt.Sprintf("Your order #%d status has changed to: %s", update.OrderID, update.Status)
+	if format, ok := returnMessages[update.Status]; ok {
+		message = fmt.Sprintf(format, update.OrderID)
+	}
 	now := time.Now()
 
 	var id int
-	err := nc.DB.QueryRow(
+	err := nc.DB.QueryRowContext(c.Request.Context(),
 		"INSERT INTO notifications (order_id, customer_id, message, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
 		update.OrderID, update.CustomerID, message, update.Status, now).Scan(&id)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to record order status notification", err)
 		return
 	}
 
 	// In a real application, you would send the notification through email, SMS, etc.
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "Order status notification created",
-		"notification_id": id,
+	c.JSON(http.StatusOK, model.OrderStatusResult{
+		Message:        "Order status notification created",
+		NotificationID: id,
 	})
 }
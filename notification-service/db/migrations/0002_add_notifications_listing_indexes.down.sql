@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_created_at_id;
+DROP INDEX IF EXISTS idx_notifications_customer_id;
+DROP INDEX IF EXISTS idx_notifications_status;
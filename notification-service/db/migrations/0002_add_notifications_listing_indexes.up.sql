@@ -0,0 +1,4 @@
+-- Indexes for the list filters and the default (created_at, id) keyset order
+CREATE INDEX IF NOT EXISTS idx_notifications_status ON notifications(status);
+CREATE INDEX IF NOT EXISTS idx_notifications_customer_id ON notifications(customer_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_created_at_id ON notifications(created_at, id);
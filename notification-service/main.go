@@ -1,35 +1,84 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 
 	"go-microservices/notification-service/controller"
 	"go-microservices/notification-service/db"
 	"go-microservices/notification-service/routes"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/lifecycle"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	logging.Init("notification-service")
+	cfg := loadConfig()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "notification-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
 	// Initialize database connection
-	database := db.GetDB()
-	defer database.Close()
+	database := db.GetDB(cfg.Database)
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// "migrate <command>" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := migrate.Main(context.Background(), db.NewMigrator(database), os.Args[2:])
+		database.Close()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		db.Migrate(database)
+	} else {
+		slog.Info("Skipping schema migrations", "reason", "DB_AUTO_MIGRATE=false")
+	}
 
 	// Create notification controller
 	notificationController := controller.NewNotificationController(database)
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware(), tracing.Middleware("notification-service"), logging.Middleware(), logging.AccessLog())
+	router.NoRoute(problem.NoRoute)
+
+	// Add log level admin endpoint
+	logging.RegisterAdminRoutes(router, cfg.Admin.Token)
+
+	// Add liveness and readiness probes
+	checker := health.NewChecker("notification-service", cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: health.SQL(database)})
+	checker.RegisterRoutes(router)
 
 	// Setup routes
 	routes.SetupRoutes(router, notificationController)
 
+	// Serve the OpenAPI document and its reference page
+	openapi.Register(router, routes.Spec())
+
 	// Start server
-	log.Println("Notification Service starting on port 8083...")
-	if err := router.Run(":8083"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	slog.Info("Notification Service starting", "port", cfg.HTTP.Port)
+	server := lifecycle.NewServer(cfg.HTTP.Addr(), router, cfg.Shutdown)
+	server.OnDrain(func() { checker.SetDraining(true) })
+	server.OnShutdown("database", lifecycle.Closer(database.Close))
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(); err != nil {
+		logging.Fatal("Server shutdown failed", "error", err)
 	}
 }
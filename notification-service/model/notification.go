@@ -5,17 +5,23 @@ import "time"
 // Notification represents a notification about an order
 type Notification struct {
 	ID          int       `json:"id"`
-	OrderID     int       `json:"order_id"`
-	CustomerID  int       `json:"customer_id"`
-	Message     string    `json:"message"`
-	Status      string    `json:"status"`
+	OrderID     int       `json:"order_id" binding:"required,gt=0"`
+	CustomerID  int       `json:"customer_id" binding:"required,gt=0"`
+	Message     string    `json:"message" binding:"required,notblank,max=1000"`
+	Status      string    `json:"status" binding:"required,notification_status"`
 	CreatedAt   time.Time `json:"created_at"`
 	DeliveredAt time.Time `json:"delivered_at,omitempty"`
 }
 
 // OrderStatusUpdate used to receive order status updates
 type OrderStatusUpdate struct {
-	OrderID    int    `json:"order_id"`
-	CustomerID int    `json:"customer_id"`
-	Status     string `json:"status"`
+	OrderID    int    `json:"order_id" binding:"required,gt=0"`
+	CustomerID int    `json:"customer_id" binding:"required,gt=0"`
+	Status     string `json:"status" binding:"required,notification_status"`
+}
+
+// OrderStatusResult is the response to an order status update
+type OrderStatusResult struct {
+	Message        string `json:"message"`
+	NotificationID int    `json:"notification_id"`
 }
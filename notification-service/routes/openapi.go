@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"go-microservices/notification-service/controller"
+	"go-microservices/notification-service/model"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+)
+
+// Spec documents every route the notification service serves
+func Spec() *openapi.Document {
+	delivered := struct {
+		Message     string    `json:"message"`
+		DeliveredAt time.Time `json:"delivered_at"`
+	}{}
+
+	return openapi.New("Notification Service API", "1.0", "Notifications sent to customers about their orders.").
+		Tag("notifications", "Customer notifications").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "POST", Path: "/notifications", ID: "createNotification", Summary: "Create a notification", Tag: "notifications",
+				Body: model.Notification{}, Status: http.StatusCreated, Response: model.Notification{},
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/notifications", ID: "listNotifications", Summary: "List notifications", Tag: "notifications",
+				Params: openapi.ListParams(controller.NotificationListParams()), Response: []model.Notification{}, Headers: openapi.ListHeaders,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/notifications/:id", ID: "getNotification", Summary: "Get a notification", Tag: "notifications",
+				Response: model.Notification{}, Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "GET", Path: "/notifications/customer/:customerId", ID: "listCustomerNotifications", Summary: "List a customer's notifications", Tag: "notifications",
+				Params: openapi.ListParams(controller.NotificationListParams()), Response: []model.Notification{}, Headers: openapi.ListHeaders,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "PUT", Path: "/notifications/:id/deliver", ID: "markNotificationDelivered", Summary: "Mark a notification as delivered", Tag: "notifications",
+				Response: delivered, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+			openapi.Route{Method: "POST", Path: "/notifications/order-status", ID: "notifyOrderStatus", Summary: "Notify a customer that their order changed status", Tag: "notifications",
+				Body: model.OrderStatusUpdate{}, Response: model.OrderStatusResult{}, Errors: []int{http.StatusBadRequest}},
+		).
+		Add(health.Routes()...).
+		Add(logging.AdminRoutes()...)
+}
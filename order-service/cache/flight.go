@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errLoadIncomplete is what waiters see if a load panics
+var errLoadIncomplete = errors.New("cache: load did not complete")
+
+// flight is a load shared by every caller asking for the same key at once
+type flight struct {
+	done  chan struct{}
+	entry entry
+	err   error
+	// stale is set when the key is invalidated while the load runs
+	stale bool
+}
+
+// flightGroup coalesces concurrent loads of a key, so a hot key expiring
+// sends one request to the database instead of one per caller
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+var loads = &flightGroup{flights: map[string]*flight{}}
+
+// do runs load once for all concurrent callers with the same key. The load
+// runs in its own goroutine, so it isn't cut short when the caller that
+// started it goes away, while each caller stops waiting when its own ctx is
+// done. load is passed a function reporting whether the key is still current:
+// false once forget was called for it. shared reports whether the result came
+// from another caller's load.
+func (g *flightGroup) do(ctx context.Context, key string, load func(current func() bool) (entry, error)) (e entry, err error, shared bool) {
+	g.mu.Lock()
+	f, shared := g.flights[key]
+	if !shared {
+		f = &flight{done: make(chan struct{}), err: errLoadIncomplete}
+		g.flights[key] = f
+		go g.run(key, f, load)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.entry, f.err, shared
+	case <-ctx.Done():
+		return entry{}, ctx.Err(), shared
+	}
+}
+
+// run performs f's load and hands the result to its waiters
+func (g *flightGroup) run(key string, f *flight, load func(current func() bool) (entry, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.entry, f.err = entry{}, fmt.Errorf("%w: %v", errLoadIncomplete, r)
+		}
+		g.mu.Lock()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.entry, f.err = load(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return !f.stale
+	})
+}
+
+// forget marks the loads in flight for keys as stale: their callers still
+// get the result, but it isn't cached, and later callers start a new load
+func (g *flightGroup) forget(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range keys {
+		if f, ok := g.flights[key]; ok {
+			f.stale = true
+			delete(g.flights, key)
+		}
+	}
+}
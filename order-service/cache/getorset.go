@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+// ErrNotFound is returned by a GetOrSet load function when the value does
+// not exist. The result is cached for the negative TTL, and GetOrSet returns
+// ErrNotFound without loading again until it expires.
+var ErrNotFound = errors.New("cache: not found")
+
+// GetOrSet retrieves value from cache or loads it with fn and caches it for
+// expiration. Concurrent callers missing the same key share a single load,
+// and entries close to expiry are occasionally reloaded early so a hot key
+// does not expire for every caller at once.
+//
+// fn runs with ctx's values but not its cancellation, bounded by the load
+// timeout instead: the callers sharing the load don't fail because the one
+// that started it went away. A caller whose own ctx is done stops waiting and
+// gets ctx's error. A value loaded while the key is invalidated is returned
+// but not cached.
+func GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func(ctx context.Context) (interface{}, error)) error {
+	e, ok := lookup(ctx, key)
+	if ok && refreshEarly(e) {
+		metrics.CacheEarlyRefreshes.Inc()
+		ok = false
+	}
+
+	if !ok {
+		var err error
+		var shared bool
+		e, err, shared = loads.do(ctx, key, func(current func() bool) (entry, error) {
+			loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), options.LoadTimeout)
+			defer cancel()
+			return load(loadCtx, key, expiration, fn, current)
+		})
+		if shared {
+			metrics.CacheLoads.WithLabelValues("shared").Inc()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.Missing {
+		return ErrNotFound
+	}
+	return json.Unmarshal(e.Value, value)
+}
+
+// load calls fn and caches what it returns, including "not found", unless
+// current reports that the key was invalidated in the meantime
+func load(ctx context.Context, key string, expiration time.Duration, fn func(ctx context.Context) (interface{}, error), current func() bool) (entry, error) {
+	start := time.Now()
+	result, err := fn(ctx)
+	delta := time.Since(start).Milliseconds()
+
+	var e entry
+	switch {
+	case errors.Is(err, ErrNotFound):
+		metrics.CacheLoads.WithLabelValues("not_found").Inc()
+		e = entry{Missing: true, Expires: time.Now().Add(options.NegativeTTL).UnixMilli(), Delta: delta}
+	case err != nil:
+		metrics.CacheLoads.WithLabelValues("error").Inc()
+		return entry{}, err
+	default:
+		metrics.CacheLoads.WithLabelValues("loaded").Inc()
+		data, err := json.Marshal(result)
+		if err != nil {
+			return entry{}, err
+		}
+		e = entry{Value: data, Expires: time.Now().Add(expiration).UnixMilli(), Delta: delta}
+	}
+
+	// The value may predate an invalidation that arrived during the load
+	if !current() {
+		metrics.CacheLoads.WithLabelValues("invalidated").Inc()
+		return e, nil
+	}
+	// The value is served even if it could not be written to Redis
+	_ = store(ctx, key, e)
+	if !current() {
+		// Invalidated while it was being stored, perhaps after the delete
+		metrics.CacheLoads.WithLabelValues("invalidated").Inc()
+		_ = Delete(ctx, key)
+	}
+	return e, nil
+}
+
+// refreshEarly decides whether this caller reloads e before it expires, using
+// probabilistic early expiration (XFetch): the chance rises as expiry nears
+// and for values that are slow to load.
+func refreshEarly(e entry) bool {
+	now := time.Now()
+	if !now.Before(e.expiry()) {
+		return true
+	}
+	if options.EarlyRefreshBeta <= 0 || e.Delta <= 0 {
+		return false
+	}
+	gap := float64(e.Delta) * options.EarlyRefreshBeta * -math.Log(1-rand.Float64())
+	return now.Add(time.Duration(gap * float64(time.Millisecond))).After(e.expiry())
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationChannel is the Redis pub/sub channel on which invalidated keys
+// are announced to every replica
+const InvalidationChannel = "cache:invalidations"
+
+// invalidation is the message published on InvalidationChannel
+type invalidation struct {
+	Keys []string `json:"keys"`
+}
+
+// Invalidate removes keys from Redis and tells every replica, this one
+// included, to drop its local copies. Loads of keys in flight are not cached.
+// Redis is tried even while reads bypass it, since a stale entry left there
+// would outlive the outage.
+func Invalidate(ctx context.Context, keys ...string) error {
+	local.drop(keys...)
+	loads.forget(keys...)
+	client := redisClient
+	if client == nil {
+		return errNotInitialized
+	}
+	if err := client.Del(ctx, keys...).Err(); err != nil {
+		redisFailed(ctx, "delete", err)
+		return err
+	}
+
+	payload, err := json.Marshal(invalidation{Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := client.Publish(ctx, InvalidationChannel, payload).Err(); err != nil {
+		redisFailed(ctx, "publish", err)
+		return err
+	}
+	return nil
+}
+
+// Bounds of the wait between attempts to subscribe to InvalidationChannel
+const (
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// ListenForInvalidations drops local copies of keys invalidated by any
+// replica, and stops this replica caching loads of them in flight, until ctx
+// is done. Subscribing is retried with backoff while Redis is unreachable,
+// and local copies are cleared whenever the subscription is (re)established,
+// since announcements may have been missed while it was down.
+func ListenForInvalidations(ctx context.Context) error {
+	if redisClient == nil {
+		return errNotInitialized
+	}
+	delay := minResubscribeDelay
+	for {
+		subscribed, err := listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if subscribed {
+			delay = minResubscribeDelay
+		}
+		slog.WarnContext(ctx, "Cache invalidation subscription lost, retrying", "retry_after", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
+
+// listen subscribes to InvalidationChannel and applies its announcements
+// until ctx is done or the subscription ends. subscribed reports whether the
+// subscription was established at all.
+func listen(ctx context.Context) (subscribed bool, err error) {
+	pubsub := redisClient.Subscribe(ctx, InvalidationChannel)
+	defer pubsub.Close()
+
+	// Wait for Redis to confirm the subscription, so a Redis that is down
+	// is noticed here rather than never delivering anything
+	if _, err := pubsub.ReceiveTimeout(ctx, options.RedisTimeout); err != nil {
+		return false, err
+	}
+	local.clear()
+
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return true, errors.New("subscription closed")
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				if m.Kind == "subscribe" {
+					local.clear()
+				}
+			case *redis.Message:
+				var inv invalidation
+				if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+					slog.Warn("Ignoring malformed cache invalidation", "error", err)
+					continue
+				}
+				local.drop(inv.Keys...)
+				loads.forget(inv.Keys...)
+			}
+		}
+	}
+}
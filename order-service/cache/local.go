@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+// localItem is an entry held in process, with the time this replica stops serving it
+type localItem struct {
+	key     string
+	entry   entry
+	expires time.Time
+}
+
+// localTier is a bounded LRU of recently used entries. Hot keys are served
+// without a round trip to Redis, and reads keep working while Redis is down.
+type localTier struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+var local = newLocalTier(options.LocalSize)
+
+func newLocalTier(size int) *localTier {
+	metrics.CacheLocalEntries.Set(0)
+	return &localTier{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *localTier) get(key string) (entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return entry{}, false
+	}
+	item := elem.Value.(*localItem)
+	if time.Now().After(item.expires) {
+		l.remove(elem)
+		return entry{}, false
+	}
+	l.order.MoveToFront(elem)
+	return item.entry, true
+}
+
+// set keeps e for up to the local TTL, and never past its own expiry
+func (l *localTier) set(key string, e entry) {
+	if l.size <= 0 {
+		return
+	}
+	expires := time.Now().Add(options.LocalTTL)
+	if e.expiry().Before(expires) {
+		expires = e.expiry()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		elem.Value = &localItem{key: key, entry: e, expires: expires}
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&localItem{key: key, entry: e, expires: expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	metrics.CacheLocalEntries.Set(float64(l.order.Len()))
+}
+
+func (l *localTier) drop(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := l.items[key]; ok {
+			l.remove(elem)
+		}
+	}
+	metrics.CacheLocalEntries.Set(float64(l.order.Len()))
+}
+
+func (l *localTier) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.order.Init()
+	l.items = map[string]*list.Element{}
+	metrics.CacheLocalEntries.Set(0)
+}
+
+// remove deletes elem; the caller holds l.mu
+func (l *localTier) remove(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.items, elem.Value.(*localItem).key)
+}
@@ -0,0 +1,47 @@
+package cache
+
+import "time"
+
+// Options tunes the cache tiers and loading behaviour
+type Options struct {
+	// LocalSize bounds the number of entries kept in process
+	LocalSize int
+	// LocalTTL bounds how long a replica serves its own copy of an entry.
+	// Copies are dropped sooner when any replica invalidates the key.
+	LocalTTL time.Duration
+	// NegativeTTL is how long a "not found" result is remembered
+	NegativeTTL time.Duration
+	// EarlyRefreshBeta scales probabilistic early refresh: entries are
+	// reloaded before they expire with a probability that grows as expiry
+	// nears and with how long the value took to load. Zero disables it.
+	EarlyRefreshBeta float64
+	// RedisTimeout bounds each Redis command, so a slow Redis costs little
+	RedisTimeout time.Duration
+	// RedisRetryAfter is how long Redis is bypassed after a failure
+	RedisRetryAfter time.Duration
+	// LoadTimeout bounds a load after a miss, which outlives the caller that
+	// started it so the callers sharing it aren't failed by its departure
+	LoadTimeout time.Duration
+}
+
+// DefaultOptions returns the default cache options
+func DefaultOptions() Options {
+	return Options{
+		LocalSize:        10000,
+		LocalTTL:         30 * time.Second,
+		NegativeTTL:      30 * time.Second,
+		EarlyRefreshBeta: 1,
+		RedisTimeout:     500 * time.Millisecond,
+		RedisRetryAfter:  5 * time.Second,
+		LoadTimeout:      5 * time.Second,
+	}
+}
+
+var options = DefaultOptions()
+
+// Configure sets the cache options and empties the in-process tier. Call it
+// before InitRedis.
+func Configure(opts Options) {
+	options = opts
+	local = newLocalTier(opts.LocalSize)
+}
@@ -3,33 +3,42 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"go-microservices/order-service/metrics"
+	"go-microservices/pkg/tracing"
+
 	"github.com/redis/go-redis/v9"
 )
 
-var (
-	redisClient *redis.Client
-	ctx         = context.Background()
-)
+var redisClient *redis.Client
 
-// InitRedis initializes Redis connection
-func InitRedis() error {
-	redisHost := os.Getenv("REDIS_HOST")
-	if redisHost == "" {
-		redisHost = "redis" // Docker default
-	}
+// redisDownUntil is when Redis is next tried after a failure, in Unix nanoseconds
+var redisDownUntil atomic.Int64
 
+// InitRedis initializes the Redis connection to addr (host:port). The cache
+// keeps working from its in-process tier if Redis cannot be reached.
+func InitRedis(addr, password string) error {
 	redisClient = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:6379", redisHost),
-		Password: "", // no password set
-		DB:       0,  // use default DB
+		Addr:         addr,
+		Password:     password,
+		DB:           0, // use default DB
+		DialTimeout:  options.RedisTimeout,
+		ReadTimeout:  options.RedisTimeout,
+		WriteTimeout: options.RedisTimeout,
 	})
 
+	// Record a span for every Redis command
+	if err := tracing.InstrumentRedis(redisClient); err != nil {
+		return fmt.Errorf("failed to instrument Redis: %w", err)
+	}
+
 	// Test connection
-	_, err := redisClient.Ping(ctx).Result()
+	_, err := redisClient.Ping(context.Background()).Result()
 	if err != nil {
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
@@ -37,62 +46,140 @@ func InitRedis() error {
 	return nil
 }
 
-// Get retrieves a value from cache
-func Get(key string, value interface{}) error {
-	data, err := redisClient.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return fmt.Errorf("key does not exist")
-	} else if err != nil {
-		return err
-	}
+// errNotInitialized is returned when Redis is used before InitRedis
+var errNotInitialized = fmt.Errorf("redis not initialized")
+
+// errUnavailable is returned while Redis is bypassed after a failure
+var errUnavailable = errors.New("redis unavailable")
 
-	return json.Unmarshal([]byte(data), value)
+// remote returns the Redis client, or nil while Redis is unavailable
+func remote() *redis.Client {
+	if redisClient == nil || time.Now().UnixNano() < redisDownUntil.Load() {
+		return nil
+	}
+	return redisClient
 }
 
-// Set stores a value in cache with expiration
-func Set(key string, value interface{}, expiration time.Duration) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
+// redisFailed records a failed Redis command and bypasses Redis for a while,
+// so requests don't each wait for a server that is down. Errors caused by the
+// caller's own context don't count against Redis.
+func redisFailed(ctx context.Context, operation string, err error) {
+	metrics.CacheErrors.WithLabelValues(operation).Inc()
+	if ctx.Err() != nil {
+		return
 	}
+	until := time.Now().Add(options.RedisRetryAfter).UnixNano()
+	if previous := redisDownUntil.Swap(until); previous < time.Now().UnixNano() {
+		slog.WarnContext(ctx, "Redis unavailable, serving from the in-process cache", "operation", operation, "retry_after", options.RedisRetryAfter, "error", err)
+	}
+}
 
-	return redisClient.Set(ctx, key, data, expiration).Err()
+// entry is a cached value together with what is needed to refresh it early
+type entry struct {
+	Value json.RawMessage `json:"v,omitempty"`
+	// Missing marks a cached "not found"
+	Missing bool `json:"m,omitempty"`
+	// Expires is when the value goes stale, in Unix milliseconds
+	Expires int64 `json:"e"`
+	// Delta is how long the value took to load, in milliseconds
+	Delta int64 `json:"d,omitempty"`
 }
 
-// Delete removes a key from cache
-func Delete(key string) error {
-	return redisClient.Del(ctx, key).Err()
+func (e entry) expiry() time.Time {
+	return time.UnixMilli(e.Expires)
 }
 
-// GetOrSet retrieves value from cache or sets it if not exists
-func GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	// Try to get from cache first
-	err := Get(key, value)
-	if err == nil {
-		return nil
+// lookup finds key in the in-process tier, then in Redis
+func lookup(ctx context.Context, key string) (entry, bool) {
+	if e, ok := local.get(key); ok {
+		metrics.CacheRequests.WithLabelValues("local", "hit").Inc()
+		return e, true
 	}
+	metrics.CacheRequests.WithLabelValues("local", "miss").Inc()
 
-	// If not in cache, call the function
-	result, err := fn()
+	client := remote()
+	if client == nil {
+		return entry{}, false
+	}
+	data, err := client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("redis", "miss").Inc()
+		return entry{}, false
+	}
 	if err != nil {
-		return err
+		redisFailed(ctx, "get", err)
+		return entry{}, false
 	}
 
-	// Store result in cache
-	if err := Set(key, result, expiration); err != nil {
-		return err
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Expires == 0 {
+		// Written in another format; treat as a miss and overwrite it
+		metrics.CacheRequests.WithLabelValues("redis", "miss").Inc()
+		return entry{}, false
 	}
+	metrics.CacheRequests.WithLabelValues("redis", "hit").Inc()
+	local.set(key, e)
+	return e, true
+}
+
+// store writes e to both tiers. Redis keeps it until it expires; failures
+// to reach Redis leave the in-process copy in place.
+func store(ctx context.Context, key string, e entry) error {
+	local.set(key, e)
 
-	// Update the value reference
-	data, err := json.Marshal(result)
+	client := remote()
+	if client == nil {
+		return errUnavailable
+	}
+	data, err := json.Marshal(e)
 	if err != nil {
 		return err
 	}
+	ttl := time.Until(e.expiry())
+	if ttl <= 0 {
+		return nil
+	}
+	if err := client.Set(ctx, key, data, ttl).Err(); err != nil {
+		redisFailed(ctx, "set", err)
+		return err
+	}
+	return nil
+}
 
-	return json.Unmarshal(data, value)
+// Get retrieves a value from the cache
+func Get(ctx context.Context, key string, value interface{}) error {
+	e, ok := lookup(ctx, key)
+	if !ok || e.Missing || time.Now().After(e.expiry()) {
+		return fmt.Errorf("key does not exist")
+	}
+	return json.Unmarshal(e.Value, value)
+}
+
+// Set stores a value in the cache with expiration
+func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return store(ctx, key, entry{Value: data, Expires: time.Now().Add(expiration).UnixMilli()})
 }
 
+// Delete removes a key from Redis and from this replica's local copies. Use
+// Invalidate when other replicas must drop their copies too.
+func Delete(ctx context.Context, key string) error {
+	local.drop(key)
+	client := remote()
+	if client == nil {
+		return errUnavailable
+	}
+	if err := client.Del(ctx, key).Err(); err != nil {
+		redisFailed(ctx, "delete", err)
+		return err
+	}
+	return nil
+}
 
+// Close closes the Redis connection
 func Close() error {
 	if redisClient != nil {
 		return redisClient.Close()
@@ -100,10 +187,24 @@ func Close() error {
 	return nil
 }
 
-// Flush clears all keys in the current DB (useful for testing)
-func Flush() error {
+// Flush clears all keys in the current DB and the local copies (useful for testing)
+func Flush(ctx context.Context) error {
+	local.clear()
 	if redisClient != nil {
 		return redisClient.FlushDB(ctx).Err()
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Ping checks that Redis is reachable. A successful ping ends any bypass
+// started by an earlier failure.
+func Ping(ctx context.Context) error {
+	if redisClient == nil {
+		return errNotInitialized
+	}
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		return err
+	}
+	redisDownUntil.Store(0)
+	return nil
+}
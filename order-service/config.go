@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/cache"
+	"go-microservices/order-service/expiry"
+	"go-microservices/order-service/resilience"
+	"go-microservices/pkg/config"
+)
+
+// Config holds the order service settings
+type Config struct {
+	HTTP       config.HTTP
+	Database   config.Database
+	Admin      config.Admin
+	Service    config.Service
+	Health     config.Health
+	Shutdown   config.Shutdown
+	Redis      RedisConfig
+	Cache      CacheConfig
+	RabbitMQ   RabbitMQConfig
+	Services   ServicesConfig
+	Resilience ResilienceConfig
+	Expiry     expiry.Config
+}
+
+// RedisConfig holds the order cache connection settings
+type RedisConfig struct {
+	Host     string `env:"REDIS_HOST" required:"true"`
+	Port     int    `env:"REDIS_PORT"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+}
+
+// Addr returns the Redis host:port
+func (r RedisConfig) Addr() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// CacheConfig tunes the order cache tiers
+type CacheConfig struct {
+	LocalSize        int           `env:"CACHE_LOCAL_SIZE"`
+	LocalTTL         time.Duration `env:"CACHE_LOCAL_TTL"`
+	NegativeTTL      time.Duration `env:"CACHE_NEGATIVE_TTL"`
+	EarlyRefreshBeta float64       `env:"CACHE_EARLY_REFRESH_BETA"`
+	RedisTimeout     time.Duration `env:"CACHE_REDIS_TIMEOUT"`
+	RedisRetryAfter  time.Duration `env:"CACHE_REDIS_RETRY_AFTER"`
+	LoadTimeout      time.Duration `env:"CACHE_LOAD_TIMEOUT"`
+}
+
+// Validate checks the cache sizes and durations
+func (c *CacheConfig) Validate() error {
+	var problems []error
+	if c.LocalSize < 0 {
+		problems = append(problems, fmt.Errorf("CACHE_LOCAL_SIZE must not be negative, got %d", c.LocalSize))
+	}
+	if c.EarlyRefreshBeta < 0 {
+		problems = append(problems, fmt.Errorf("CACHE_EARLY_REFRESH_BETA must not be negative, got %g", c.EarlyRefreshBeta))
+	}
+	for key, value := range map[string]time.Duration{
+		"CACHE_LOCAL_TTL":         c.LocalTTL,
+		"CACHE_NEGATIVE_TTL":      c.NegativeTTL,
+		"CACHE_REDIS_TIMEOUT":     c.RedisTimeout,
+		"CACHE_REDIS_RETRY_AFTER": c.RedisRetryAfter,
+		"CACHE_LOAD_TIMEOUT":      c.LoadTimeout,
+	} {
+		if value <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive, got %s", key, value))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Options returns the settings in the form the cache package takes
+func (c CacheConfig) Options() cache.Options {
+	return cache.Options(c)
+}
+
+// RabbitMQConfig holds the message broker connection settings
+type RabbitMQConfig struct {
+	Host     string `env:"RABBITMQ_HOST" required:"true"`
+	Port     int    `env:"RABBITMQ_PORT"`
+	User     string `env:"RABBITMQ_USER" required:"true"`
+	Password string `env:"RABBITMQ_PASSWORD" required:"true" secret:"true"`
+}
+
+// URL returns the AMQP connection URL
+func (r RabbitMQConfig) URL() string {
+	u := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(r.User, r.Password),
+		Host:   net.JoinHostPort(r.Host, strconv.Itoa(r.Port)),
+		Path:   "/",
+	}
+	return u.String()
+}
+
+// ServicesConfig holds the addresses of downstream services
+type ServicesConfig struct {
+	InventoryURL    string `env:"INVENTORY_SERVICE_URL"`
+	NotificationURL string `env:"NOTIFICATION_SERVICE_URL"`
+	PaymentURL      string `env:"PAYMENT_SERVICE_URL"`
+}
+
+// Validate checks the service URLs
+func (s *ServicesConfig) Validate() error {
+	var problems []error
+	for key, value := range map[string]string{
+		"INVENTORY_SERVICE_URL":    s.InventoryURL,
+		"NOTIFICATION_SERVICE_URL": s.NotificationURL,
+		"PAYMENT_SERVICE_URL":      s.PaymentURL,
+	} {
+		if err := config.ValidateURL(key, value); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// ResilienceConfig holds the policy guarding the calls to each downstream
+// service, e.g. PAYMENT_SERVICE_TIMEOUT or INVENTORY_SERVICE_MAX_RETRIES
+type ResilienceConfig struct {
+	Inventory    resilience.Config `prefix:"INVENTORY_SERVICE_"`
+	Notification resilience.Config `prefix:"NOTIFICATION_SERVICE_"`
+	Payment      resilience.Config `prefix:"PAYMENT_SERVICE_"`
+}
+
+// defaultResilience returns the policies' default settings
+func defaultResilience() ResilienceConfig {
+	notification := resilience.DefaultConfig()
+	notification.MaxRetries = 3
+
+	// Creating a payment is not idempotent, so it is never retried; the
+	// breaker opens quickly instead, since payments fail loudly
+	payment := resilience.DefaultConfig()
+	payment.Timeout = 5 * time.Second
+	payment.MaxRetries = 0
+	payment.BreakerConsecutiveFailures = 3
+
+	return ResilienceConfig{
+		Inventory:    resilience.DefaultConfig(),
+		Notification: notification,
+		Payment:      payment,
+	}
+}
+
+// loadConfig reads the order service settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:     config.HTTP{Port: 8081},
+		Database: config.DefaultDatabase("orders_db"),
+		Health:   config.DefaultHealth(),
+		Shutdown: config.DefaultShutdown(),
+		Redis:    RedisConfig{Host: "redis", Port: 6379},
+		Cache:    CacheConfig(cache.DefaultOptions()),
+		RabbitMQ: RabbitMQConfig{Host: "rabbitmq", Port: 5672, User: "guest"},
+		Services: ServicesConfig{
+			InventoryURL:    "http://inventory-service:8082",
+			NotificationURL: "http://notification-service:8083",
+			PaymentURL:      "http://payment-service:8084",
+		},
+		Resilience: defaultResilience(),
+		Expiry:     expiry.DefaultConfig(),
+	}
+	config.MustLoad("order-service", &cfg)
+	return cfg
+}
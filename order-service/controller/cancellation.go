@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/metrics"
+	"go-microservices/order-service/model"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cancellation is the outcome of cancelling an order. StockReleased and
+// PaymentsSettled are false when the inventory or payment service could not
+// be reached; cancelling the order again retries them.
+type Cancellation struct {
+	Order           model.Order            `json:"order"`
+	StockReleased   bool                   `json:"stock_released"`
+	PaymentsSettled bool                   `json:"payments_settled"`
+	Payments        []paymentmodel.Payment `json:"payments,omitempty"`
+}
+
+// stripeReasons maps cancellation reasons to the reasons Stripe records for
+// cancelled payment intents and refunds; the others are sent without one
+var stripeReasons = map[string]string{
+	model.CancelCustomerRequest: "requested_by_customer",
+	model.CancelFraudSuspected:  "fraudulent",
+	model.CancelDuplicateOrder:  "duplicate",
+	model.CancelExpired:         "abandoned",
+}
+
+// CancelOrder cancels an order that has not shipped, releases its reserved
+// stock and voids or refunds its payments. Cancelling a cancelled order
+// repeats the release and refund, which finishes a cancellation whose
+// compensation failed.
+func (oc *OrderController) CancelOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
+
+	var req model.CancelRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	oc.cancel(c, id, req.Reason)
+}
+
+// DeleteOrder cancels an order at the customer's request. The order is kept;
+// only PurgeOrder deletes it.
+func (oc *OrderController) DeleteOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
+	oc.cancel(c, id, model.CancelCustomerRequest)
+}
+
+// cancel cancels order id for reason and answers with the Cancellation
+func (oc *OrderController) cancel(c *gin.Context, id int, reason string) {
+	ctx := c.Request.Context()
+
+	order, err := oc.OrderRepo.CancelOrder(ctx, id, reason)
+	cancelled := err == nil
+	if err == sql.ErrNoRows {
+		// The order is missing, already cancelled or past cancelling
+		order, err = oc.OrderRepo.GetOrderFromDB(ctx, strconv.Itoa(id))
+		if err == sql.ErrNoRows {
+			problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
+			return
+		}
+		if err == nil && order.Status != model.StatusCancelled {
+			p := problem.New(problem.CodeOrderNotCancellable, fmt.Sprintf("Order %d is %s and can no longer be cancelled", id, order.Status))
+			problem.Write(c, p.With("status", order.Status))
+			return
+		}
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to cancel order", err)
+		return
+	}
+
+	if cancelled {
+		oc.cancelled(ctx, *order)
+	}
+	c.JSON(http.StatusOK, oc.compensate(ctx, *order))
+}
+
+// cancelled finishes the transition of an order that was just cancelled: the
+// cached copy is dropped, the metrics are updated and the customer is told
+func (oc *OrderController) cancelled(ctx context.Context, order model.Order) {
+	oc.invalidateOrder(ctx, order.ID)
+	metrics.OrdersCancelled.WithLabelValues(order.CancelReason).Inc()
+	metrics.OrderStatusUpdated.WithLabelValues(model.StatusCancelled).Inc()
+	metrics.ActiveOrders.Dec()
+
+	notifyCtx := context.WithoutCancel(ctx)
+	oc.goBackground(func() {
+		if err := oc.NotificationService.SendOrderStatusUpdate(notifyCtx, order.ID, order.CustomerID, model.StatusCancelled); err != nil {
+			slog.WarnContext(notifyCtx, "Failed to send cancellation notification", "order_id", order.ID, "error", err)
+		}
+	})
+}
+
+// compensate releases the stock a cancelled order reserved and settles its
+// payments. Both steps are idempotent, so they run on every cancellation.
+func (oc *OrderController) compensate(ctx context.Context, order model.Order) Cancellation {
+	result := Cancellation{Order: order}
+
+	if err := oc.InventoryService.ReleaseStock(ctx, order.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to release stock of cancelled order", "order_id", order.ID, "error", err)
+		metrics.OrderCompensations.WithLabelValues("release_stock", "failure").Inc()
+	} else {
+		result.StockReleased = true
+		metrics.OrderCompensations.WithLabelValues("release_stock", "success").Inc()
+	}
+
+	payments, err := oc.PaymentService.CancelOrderPayments(ctx, order.ID, stripeReasons[order.CancelReason])
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to settle payments of cancelled order", "order_id", order.ID, "error", err)
+		metrics.OrderCompensations.WithLabelValues("settle_payments", "failure").Inc()
+	} else {
+		result.PaymentsSettled = true
+		result.Payments = payments
+		metrics.OrderCompensations.WithLabelValues("settle_payments", "success").Inc()
+	}
+
+	return result
+}
+
+// PurgeOrder deletes a cancelled order for good, for erasure requests. Its
+// payments stay with the payment service, which keeps them for accounting.
+func (oc *OrderController) PurgeOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
+
+	err = oc.OrderRepo.PurgeOrder(c.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		_, err = oc.OrderRepo.GetOrderFromDB(c.Request.Context(), strconv.Itoa(id))
+		if err == sql.ErrNoRows {
+			problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
+			return
+		}
+		if err == nil {
+			problem.Abort(c, problem.CodeConflict, fmt.Sprintf("Order %d must be cancelled before it is purged", id))
+			return
+		}
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to purge order", err)
+		return
+	}
+	oc.invalidateOrder(c.Request.Context(), id)
+
+	slog.InfoContext(c.Request.Context(), "Order purged", "order_id", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Order purged successfully"})
+}
+
+// ExpireOrders cancels the orders that have been pending since before cutoff
+// with a payment attempt that never succeeded, as abandoned checkouts, limit
+// at a time. Payments are looked up before an order is touched and each order
+// is cancelled by its own statement, so no lock is held across the calls to
+// the payment service and every replica can run it at once. A payment can go
+// through between the lookup and the cancellation, so payments are checked
+// again once the order is cancelled and any that succeeded are refunded.
+func (oc *OrderController) ExpireOrders(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	expired, afterID := 0, 0
+	for {
+		due, err := oc.OrderRepo.PendingOrders(ctx, cutoff, afterID, limit)
+		if err != nil {
+			return expired, err
+		}
+		for _, order := range due {
+			if !oc.unpaid(ctx, order.ID) {
+				continue
+			}
+			order, err := oc.OrderRepo.ExpireOrder(ctx, order.ID, cutoff)
+			if err == sql.ErrNoRows {
+				// Paid, cancelled or expired by another replica since it was listed
+				continue
+			}
+			if err != nil {
+				return expired, err
+			}
+			oc.cancelled(ctx, *order)
+			result := oc.compensate(ctx, *order)
+			oc.refundLatePayments(ctx, &result)
+			slog.InfoContext(ctx, "Order expired", "order_id", order.ID,
+				"stock_released", result.StockReleased, "payments_settled", result.PaymentsSettled)
+			expired++
+		}
+		if len(due) < limit {
+			return expired, nil
+		}
+		afterID = due[len(due)-1].ID
+	}
+}
+
+// refundLatePayments checks the payments of an order that just expired and
+// refunds any that succeeded after unpaid looked at them, which settling the
+// order's payments may have missed
+func (oc *OrderController) refundLatePayments(ctx context.Context, result *Cancellation) {
+	orderID := result.Order.ID
+	payments, err := oc.PaymentService.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check payments of expired order", "order_id", orderID, "error", err)
+		return
+	}
+	if !slices.ContainsFunc(payments, func(p paymentmodel.Payment) bool { return p.Status == paymentmodel.PaymentStatusSucceeded }) {
+		return
+	}
+
+	slog.WarnContext(ctx, "Expired order was paid while expiring, refunding it", "order_id", orderID)
+	settled, err := oc.PaymentService.CancelOrderPayments(ctx, orderID, stripeReasons[result.Order.CancelReason])
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to refund payment of expired order", "order_id", orderID, "error", err)
+		metrics.OrderCompensations.WithLabelValues("refund_late_payment", "failure").Inc()
+		result.PaymentsSettled = false
+		return
+	}
+	result.PaymentsSettled = true
+	result.Payments = settled
+	metrics.OrderCompensations.WithLabelValues("refund_late_payment", "success").Inc()
+}
+
+// unpaid reports whether an order went through checkout without paying: it
+// has a payment attempt and none of its payments has succeeded. Orders
+// without any payment were placed with POST /orders, which doesn't take
+// payment, and never expire. An order whose payments can't be looked up is
+// not expired either; the next run retries it.
+func (oc *OrderController) unpaid(ctx context.Context, orderID int) bool {
+	payments, err := oc.PaymentService.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check payments of pending order", "order_id", orderID, "error", err)
+		return false
+	}
+	if len(payments) == 0 {
+		return false
+	}
+	for _, payment := range payments {
+		if payment.Status == paymentmodel.PaymentStatusSucceeded {
+			return false
+		}
+	}
+	return true
+}
@@ -1,108 +1,171 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"go-microservices/order-service/cache"
 	"go-microservices/order-service/metrics"
 	"go-microservices/order-service/model"
 	"go-microservices/order-service/queue"
-	"go-microservices/order-service/service"
 	"go-microservices/order-service/worker"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/keyspace"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 )
 
 // InventoryServiceInterface defines the interface for inventory service
 type InventoryServiceInterface interface {
-	CheckAvailability(productID int, quantity int) (bool, error)
+	CheckAvailability(ctx context.Context, productID int, quantity int) (bool, error)
+	// ReserveStock and ReleaseStock are idempotent per order; ReleaseStock
+	// succeeds when the order reserved nothing
+	ReserveStock(ctx context.Context, orderID int, productID int, quantity int) error
+	ReleaseStock(ctx context.Context, orderID int) error
+	// RestockReturn puts returned units back into stock; it is idempotent per return
+	RestockReturn(ctx context.Context, returnID int, orderID int, productID int, quantity int) error
 }
 
 // NotificationServiceInterface defines the interface for notification service
 type NotificationServiceInterface interface {
-	SendOrderNotification(orderID int) error
-	SendOrderStatusUpdate(orderID int, customerID int, status string) error
+	SendOrderNotification(ctx context.Context, orderID int, customerID int) error
+	SendOrderStatusUpdate(ctx context.Context, orderID int, customerID int, status string) error
 }
 
 // PaymentServiceInterface defines the interface for payment service
 type PaymentServiceInterface interface {
-	CreatePayment(orderID int, customerID int, amount float64, currency string) (*service.PaymentResponse, error)
+	CreatePayment(ctx context.Context, orderID int, customerID int, amount float64, currency string) (*paymentmodel.PaymentResponse, error)
+	// CancelOrderPayments voids or refunds an order's payments; it is idempotent
+	CancelOrderPayments(ctx context.Context, orderID int, reason string) ([]paymentmodel.Payment, error)
+	GetPaymentsByOrder(ctx context.Context, orderID int) ([]paymentmodel.Payment, error)
+	// RefundOrder refunds part of an order's payments; it is idempotent per reference
+	RefundOrder(ctx context.Context, orderID int, amount float64, reference string) (*paymentmodel.Refund, error)
 }
 
-// OrderRepository defines the interface for order database operations
+// OrderRepository defines the interface for order database operations.
+// Updates return sql.ErrNoRows when the order does not exist or is not in a
+// state they apply to: cancelled orders can't be updated, and CancelOrder,
+// PurgeOrder and ExpireOrder each apply to their own statuses.
 type OrderRepository interface {
-	InsertOrder(order *model.Order) error
-	GetOrderFromDB(orderID string) (*model.Order, error)
+	InsertOrder(ctx context.Context, order *model.Order) error
+	GetOrderFromDB(ctx context.Context, orderID string) (*model.Order, error)
+	UpdateOrder(ctx context.Context, order *model.Order) error
+	UpdateOrderStatus(ctx context.Context, orderID int, status string) error
+	CancelOrder(ctx context.Context, orderID int, reason string) (*model.Order, error)
+	PurgeOrder(ctx context.Context, orderID int) error
+	PendingOrders(ctx context.Context, cutoff time.Time, afterID, limit int) ([]model.Order, error)
+	ExpireOrder(ctx context.Context, orderID int, cutoff time.Time) (*model.Order, error)
 }
 
 // Cache defines the interface for cache operations
 type Cache interface {
-	Get(key string, value interface{}) error
-	Set(key string, value interface{}, expiration time.Duration) error
-	GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error
+	Get(ctx context.Context, key string, value interface{}) error
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func(ctx context.Context) (interface{}, error)) error
+	Invalidate(ctx context.Context, keys ...string) error
+}
+
+// orderCacheTTL is how long an order stays cached after it is read or created
+const orderCacheTTL = 30 * time.Minute
+
+// orderKeys names cached orders; the keys change whenever model.Order does
+var orderKeys = keyspace.New("order", model.Order{})
+
+// OrderCacheKey returns the cache key of an order
+func OrderCacheKey(orderID string) string {
+	return orderKeys.Key(orderID)
 }
 
 // MessageQueue defines the interface for message queue operations
 type MessageQueue interface {
-	PublishMessage(config queue.Config, message interface{}) error
+	PublishMessage(ctx context.Context, config queue.Config, message interface{}) error
 }
 
 // OrderController handles order-related requests
 type OrderController struct {
 	DB                  *sql.DB
 	OrderRepo           OrderRepository
+	ReturnRepo          ReturnRepository
 	Cache               Cache
 	Queue               MessageQueue
 	InventoryService    InventoryServiceInterface
 	NotificationService NotificationServiceInterface
 	PaymentService      PaymentServiceInterface
+
+	// background tracks work that outlives a request, such as notifications
+	background sync.WaitGroup
 }
 
 // DBOrderRepository implements OrderRepository interface using SQL database
 type DBOrderRepository struct {
 	DB *sql.DB
+	// Timeout bounds each query within the caller's deadline; zero means no extra bound
+	Timeout time.Duration
+}
+
+// withTimeout bounds ctx by a repository's query timeout; zero adds no bound
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // InsertOrder inserts a new order into the database
-func (r *DBOrderRepository) InsertOrder(order *model.Order) error {
+func (r *DBOrderRepository) InsertOrder(ctx context.Context, order *model.Order) error {
 	query := `
-		INSERT INTO orders (product_id, quantity, status, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO orders (customer_id, product_id, quantity, total_price, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id`
 
 	order.Status = "pending"
 	order.CreatedAt = time.Now()
 
-	return r.DB.QueryRow(
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	return r.DB.QueryRowContext(
+		ctx,
 		query,
+		order.CustomerID,
 		order.ProductID,
 		order.Quantity,
+		order.TotalPrice,
 		order.Status,
 		order.CreatedAt,
 	).Scan(&order.ID)
 }
 
+// orderColumns are the columns an order is read from, in orderFields' order
+const orderColumns = "id, customer_id, product_id, quantity, total_price, status, created_at, cancelled_at, COALESCE(cancel_reason, '')"
+
+// orderFields returns the scan destinations for orderColumns
+func orderFields(o *model.Order) []interface{} {
+	return []interface{}{&o.ID, &o.CustomerID, &o.ProductID, &o.Quantity, &o.TotalPrice, &o.Status, &o.CreatedAt, &o.CancelledAt, &o.CancelReason}
+}
+
 // GetOrderFromDB retrieves an order from the database by ID
-func (r *DBOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
+func (r *DBOrderRepository) GetOrderFromDB(ctx context.Context, orderID string) (*model.Order, error) {
 	var order model.Order
 	query := `
-		SELECT id, product_id, quantity, status, created_at
+		SELECT ` + orderColumns + `
 		FROM orders
 		WHERE id = $1`
 
-	err := r.DB.QueryRow(query, orderID).Scan(
-		&order.ID,
-		&order.ProductID,
-		&order.Quantity,
-		&order.Status,
-		&order.CreatedAt,
-	)
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, query, orderID).Scan(orderFields(&order)...)
 
 	if err != nil {
 		return nil, err
@@ -111,69 +174,351 @@ func (r *DBOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error)
 	return &order, nil
 }
 
+// UpdateOrder replaces the changeable fields of an order that isn't
+// cancelled. The product and quantity are kept: the stock reserved for them
+// would no longer match.
+func (r *DBOrderRepository) UpdateOrder(ctx context.Context, order *model.Order) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx,
+		"UPDATE orders SET customer_id = $1, total_price = $2, status = $3 WHERE id = $4 AND status <> $5 RETURNING created_at",
+		order.CustomerID, order.TotalPrice, order.Status, order.ID, model.StatusCancelled).Scan(&order.CreatedAt)
+}
+
+// UpdateOrderStatus sets the status of an order that isn't cancelled
+func (r *DBOrderRepository) UpdateOrderStatus(ctx context.Context, orderID int, status string) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, "UPDATE orders SET status = $1 WHERE id = $2 AND status <> $3", status, orderID, model.StatusCancelled)
+	return requireRow(result, err)
+}
+
+// CancelOrder cancels an order that has not shipped yet, recording when and
+// why. The status check and the update are one statement, so an order that
+// ships concurrently is never cancelled.
+func (r *DBOrderRepository) CancelOrder(ctx context.Context, orderID int, reason string) (*model.Order, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	return cancelOrder(ctx, r.DB, orderID, reason)
+}
+
+// rowQuerier is satisfied by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// cancelOrder runs the cancellation statement of CancelOrder on db
+func cancelOrder(ctx context.Context, db rowQuerier, orderID int, reason string) (*model.Order, error) {
+	var order model.Order
+	err := db.QueryRowContext(ctx, `
+		UPDATE orders SET status = $1, cancelled_at = $2, cancel_reason = $3
+		WHERE id = $4 AND status IN ($5, $6)
+		RETURNING `+orderColumns,
+		model.StatusCancelled, time.Now(), reason, orderID, model.StatusPending, model.StatusProcessing,
+	).Scan(orderFields(&order)...)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// PendingOrders returns up to limit orders above afterID, in ID order, that
+// have been pending since before cutoff. Nothing is locked: ExpireOrder
+// checks the status again when it cancels one.
+func (r *DBOrderRepository) PendingOrders(ctx context.Context, cutoff time.Time, afterID, limit int) ([]model.Order, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE status = $1 AND created_at < $2 AND id > $3
+		ORDER BY id
+		LIMIT $4`,
+		model.StatusPending, cutoff, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		var order model.Order
+		if err := rows.Scan(orderFields(&order)...); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// ExpireOrder cancels an order as expired if it is still pending since before
+// cutoff. The check and the update are one statement, so an order paid or
+// cancelled meanwhile, or expired by another replica, is left alone.
+func (r *DBOrderRepository) ExpireOrder(ctx context.Context, orderID int, cutoff time.Time) (*model.Order, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var order model.Order
+	err := r.DB.QueryRowContext(ctx, `
+		UPDATE orders SET status = $1, cancelled_at = $2, cancel_reason = $3
+		WHERE id = $4 AND status = $5 AND created_at < $6
+		RETURNING `+orderColumns,
+		model.StatusCancelled, time.Now(), model.CancelExpired, orderID, model.StatusPending, cutoff,
+	).Scan(orderFields(&order)...)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// PurgeOrder deletes a cancelled order for good
+func (r *DBOrderRepository) PurgeOrder(ctx context.Context, orderID int) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, "DELETE FROM orders WHERE id = $1 AND status = $2", orderID, model.StatusCancelled)
+	return requireRow(result, err)
+}
+
+// requireRow returns sql.ErrNoRows when a statement affected no rows
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // RedisCache implements Cache interface using Redis
 type RedisCache struct{}
 
 // Get retrieves a value from cache
-func (r *RedisCache) Get(key string, value interface{}) error {
-	return cache.Get(key, value)
+func (r *RedisCache) Get(ctx context.Context, key string, value interface{}) error {
+	return cache.Get(ctx, key, value)
 }
 
 // Set stores a value in cache with expiration
-func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration) error {
-	return cache.Set(key, value, expiration)
+func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return cache.Set(ctx, key, value, expiration)
 }
 
 // GetOrSet retrieves value from cache or sets it if not exists
-func (r *RedisCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	return cache.GetOrSet(key, value, expiration, fn)
+func (r *RedisCache) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func(ctx context.Context) (interface{}, error)) error {
+	return cache.GetOrSet(ctx, key, value, expiration, fn)
+}
+
+// Invalidate removes keys from the cache on every replica
+func (r *RedisCache) Invalidate(ctx context.Context, keys ...string) error {
+	return cache.Invalidate(ctx, keys...)
 }
 
 // RabbitMQQueue implements MessageQueue interface using RabbitMQ
 type RabbitMQQueue struct{}
 
 // PublishMessage publishes a message to RabbitMQ
-func (r *RabbitMQQueue) PublishMessage(config queue.Config, message interface{}) error {
-	return queue.PublishMessage(config, message)
+func (r *RabbitMQQueue) PublishMessage(ctx context.Context, config queue.Config, message interface{}) error {
+	return queue.PublishMessage(ctx, config, message)
 }
 
-// NewOrderController creates a new order controller
-func NewOrderController(db *sql.DB) *OrderController {
+// NewOrderController creates a new order controller using the given service clients
+func NewOrderController(db *sql.DB, inventory InventoryServiceInterface, notification NotificationServiceInterface, payment PaymentServiceInterface) *OrderController {
 	return &OrderController{
 		DB:                  db,
-		OrderRepo:           &DBOrderRepository{DB: db},
+		OrderRepo:           &DBOrderRepository{DB: db, Timeout: 3 * time.Second},
+		ReturnRepo:          &DBReturnRepository{DB: db, Timeout: 3 * time.Second},
 		Cache:               &RedisCache{},
 		Queue:               &RabbitMQQueue{},
-		InventoryService:    service.NewInventoryService(),
-		NotificationService: service.NewNotificationService(),
-		PaymentService:      service.NewPaymentService(),
+		InventoryService:    inventory,
+		NotificationService: notification,
+		PaymentService:      payment,
+	}
+}
+
+// goBackground runs fn in a goroutine that Wait waits for during shutdown
+func (oc *OrderController) goBackground(fn func()) {
+	oc.background.Add(1)
+	go func() {
+		defer oc.background.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until background work started by handlers has finished, or ctx is done
+func (oc *OrderController) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		oc.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background work still running: %w", ctx.Err())
+	}
+}
+
+// stockUnavailable answers when the inventory service could not be asked about stock
+func stockUnavailable(c *gin.Context, err error) {
+	slog.WarnContext(c.Request.Context(), "Failed to check inventory", "error", err)
+	problem.Abort(c, problem.CodeUnavailable, "Stock could not be checked, try again later")
+}
+
+// insufficientStock answers when the product does not have quantity units in stock
+func insufficientStock(c *gin.Context, productID, quantity int) {
+	p := problem.New(problem.CodeInsufficientStock, fmt.Sprintf("Product %d does not have %d units in stock", productID, quantity))
+	problem.Write(c, p.With("product_id", productID))
+}
+
+// paymentFailed answers when the order was stored but its payment could not be
+// created. The order stays pending, so the client can retry the payment.
+func paymentFailed(c *gin.Context, orderID int, err error) {
+	var declined *problem.Problem
+	if errors.As(err, &declined) && declined.Code == problem.CodePaymentDeclined {
+		p := problem.New(problem.CodePaymentDeclined, declined.Detail)
+		if code, ok := declined.Extensions["decline_code"]; ok {
+			p.With("decline_code", code)
+		}
+		problem.Write(c, p.With("order_id", orderID))
+		return
+	}
+	p := problem.New(problem.CodePaymentFailed, "The order was created but its payment could not be started, try again later")
+	problem.Write(c, p.With("order_id", orderID))
+}
+
+// reserveStock reserves the stock of a stored order. When that fails the order
+// is cancelled as out of stock, the request is answered and false is returned.
+func (oc *OrderController) reserveStock(c *gin.Context, order *model.Order) bool {
+	ctx := c.Request.Context()
+	err := oc.InventoryService.ReserveStock(ctx, order.ID, order.ProductID, order.Quantity)
+	if err == nil {
+		return true
+	}
+
+	if oc.OrderRepo != nil {
+		if _, cerr := oc.OrderRepo.CancelOrder(ctx, order.ID, model.CancelOutOfStock); cerr != nil {
+			slog.ErrorContext(ctx, "Failed to cancel order without stock", "order_id", order.ID, "error", cerr)
+		} else {
+			metrics.OrdersCancelled.WithLabelValues(model.CancelOutOfStock).Inc()
+		}
+	}
+
+	var p *problem.Problem
+	if errors.As(err, &p) && p.Code == problem.CodeInsufficientStock {
+		insufficientStock(c, order.ProductID, order.Quantity)
+		return false
+	}
+	stockUnavailable(c, err)
+	return false
+}
+
+// changeable answers and returns false when an order may not be given status:
+// cancelled orders are final, and cancelling must go through the cancel route
+// so that stock and payments are released
+func changeable(c *gin.Context, order *model.Order, status string) bool {
+	if order.Status == model.StatusCancelled {
+		p := problem.New(problem.CodeConflict, fmt.Sprintf("Order %d is cancelled and can no longer be changed", order.ID))
+		problem.Write(c, p.With("order_id", order.ID))
+		return false
+	}
+	if status == model.StatusCancelled {
+		p := problem.New(problem.CodeValidation, "One or more fields are invalid")
+		p.Errors = []problem.FieldError{{Field: "status", Rule: "cancel", Message: fmt.Sprintf("cannot be set to cancelled, use POST /orders/%d/cancel", order.ID)}}
+		problem.Write(c, p)
+		return false
+	}
+	return true
+}
+
+// sameStock rejects an update that changes the product or quantity of an
+// order, since its reserved stock was taken for those
+func sameStock(c *gin.Context, order *model.Order, update *model.Order) bool {
+	var fields []problem.FieldError
+	if update.ProductID != order.ProductID {
+		fields = append(fields, problem.FieldError{Field: "product_id", Rule: "immutable", Message: "cannot be changed; cancel the order and place a new one"})
+	}
+	if update.Quantity != order.Quantity {
+		fields = append(fields, problem.FieldError{Field: "quantity", Rule: "immutable", Message: "cannot be changed; cancel the order and place a new one"})
+	}
+	if len(fields) == 0 {
+		return true
+	}
+	p := problem.New(problem.CodeValidation, "One or more fields are invalid")
+	p.Errors = fields
+	problem.Write(c, p)
+	return false
+}
+
+// notUpdated answers an update that matched no row: the order is missing, or
+// was cancelled since it was read
+func (oc *OrderController) notUpdated(c *gin.Context, id int) {
+	order, err := oc.OrderRepo.GetOrderFromDB(c.Request.Context(), strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to update order", err)
+		return
+	}
+	if changeable(c, order, order.Status) {
+		problem.Abort(c, problem.CodeConflict, fmt.Sprintf("Order %d changed while it was being updated", id))
+	}
+}
+
+// cacheOrder writes a newly created order through to the cache, so the first
+// read does not go to the database
+func (oc *OrderController) cacheOrder(ctx context.Context, order model.Order) {
+	if oc.Cache == nil {
+		return
+	}
+	if err := oc.Cache.Set(ctx, OrderCacheKey(strconv.Itoa(order.ID)), order, orderCacheTTL); err != nil {
+		slog.WarnContext(ctx, "Failed to cache order", "order_id", order.ID, "error", err)
+	}
+}
+
+// invalidateOrder drops an order that was changed or deleted from the cache
+// on every replica. Failures are logged: the order is then served stale until
+// its cache entry expires.
+func (oc *OrderController) invalidateOrder(ctx context.Context, orderID int) {
+	if oc.Cache == nil {
+		return
+	}
+	if err := oc.Cache.Invalidate(ctx, OrderCacheKey(strconv.Itoa(orderID))); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate cached order", "order_id", orderID, "error", err)
 	}
 }
 
 // CreateOrder handles creation of a new order
 func (oc *OrderController) CreateOrder(c *gin.Context) {
 	var order model.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &order) {
 		return
 	}
 
 	// Check inventory availability using circuit breaker
-	available, err := oc.InventoryService.CheckAvailability(order.ProductID, order.Quantity)
+	available, err := oc.InventoryService.CheckAvailability(c.Request.Context(), order.ProductID, order.Quantity)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
+		stockUnavailable(c, err)
 		return
 	}
 	if !available {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product not available in requested quantity"})
+		insufficientStock(c, order.ProductID, order.Quantity)
 		return
 	}
 
 	// Insert order into database
 	if oc.OrderRepo != nil {
-		err = oc.OrderRepo.InsertOrder(&order)
+		err = oc.OrderRepo.InsertOrder(c.Request.Context(), &order)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
+			problem.Internal(c, "Failed to create order", err)
 			return
 		}
 	} else {
@@ -182,25 +527,30 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		order.Status = "pending"
 		order.CreatedAt = time.Now()
 	}
+	if !oc.reserveStock(c, &order) {
+		return
+	}
+	oc.cacheOrder(c.Request.Context(), order)
 
 	// Publish order created event to message queue
 	if oc.Queue != nil {
 		orderMsg, _ := json.Marshal(order)
-		if err := oc.Queue.PublishMessage(queue.Config{
+		if err := oc.Queue.PublishMessage(c.Request.Context(), queue.Config{
 			QueueName:    "orders",
 			RoutingKey:   "order.created",
 			ExchangeName: "orders",
 		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
+			slog.WarnContext(c.Request.Context(), "Failed to publish order created event", "order_id", order.ID, "error", err)
 		}
 	}
 
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(order.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
+	// Send notification using circuit breaker, in the background but as part of this trace
+	ctx := context.WithoutCancel(c.Request.Context())
+	oc.goBackground(func() {
+		if err := oc.NotificationService.SendOrderNotification(ctx, order.ID, order.CustomerID); err != nil {
+			slog.ErrorContext(ctx, "Failed to send order notification", "order_id", order.ID, "error", err)
 		}
-	}()
+	})
 
 	c.JSON(http.StatusCreated, order)
 }
@@ -209,30 +559,36 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 	var orderWithPayment struct {
 		model.Order
-		Currency string `json:"currency" binding:"required"`
+		Currency string `json:"currency" binding:"required,currency"`
 	}
 	
-	if err := c.ShouldBindJSON(&orderWithPayment); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &orderWithPayment) {
+		return
+	}
+	// The order's total is charged, so it can't be left out
+	if orderWithPayment.TotalPrice <= 0 {
+		p := problem.New(problem.CodeValidation, "One or more fields are invalid")
+		p.Errors = []problem.FieldError{{Field: "total_price", Rule: "gt", Message: "must be greater than 0"}}
+		problem.Write(c, p)
 		return
 	}
 
 	// Check inventory availability using circuit breaker
-	available, err := oc.InventoryService.CheckAvailability(orderWithPayment.ProductID, orderWithPayment.Quantity)
+	available, err := oc.InventoryService.CheckAvailability(c.Request.Context(), orderWithPayment.ProductID, orderWithPayment.Quantity)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
+		stockUnavailable(c, err)
 		return
 	}
 	if !available {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product not available in requested quantity"})
+		insufficientStock(c, orderWithPayment.ProductID, orderWithPayment.Quantity)
 		return
 	}
 
 	// Insert order into database
 	if oc.OrderRepo != nil {
-		err = oc.OrderRepo.InsertOrder(&orderWithPayment.Order)
+		err = oc.OrderRepo.InsertOrder(c.Request.Context(), &orderWithPayment.Order)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
+			problem.Internal(c, "Failed to create order", err)
 			return
 		}
 	} else {
@@ -241,42 +597,44 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 		orderWithPayment.Order.Status = "pending"
 		orderWithPayment.Order.CreatedAt = time.Now()
 	}
+	if !oc.reserveStock(c, &orderWithPayment.Order) {
+		return
+	}
+	oc.cacheOrder(c.Request.Context(), orderWithPayment.Order)
 
 	// Create payment intent
 	paymentResp, err := oc.PaymentService.CreatePayment(
+		c.Request.Context(),
 		orderWithPayment.ID,
 		orderWithPayment.CustomerID,
 		orderWithPayment.TotalPrice,
 		orderWithPayment.Currency,
 	)
 	if err != nil {
-		log.Printf("Warning: Failed to create payment intent: %v\n", err)
-		// Still return the order but indicate payment failed
-		c.JSON(http.StatusCreated, gin.H{
-			"order": orderWithPayment.Order,
-			"payment_error": "Failed to create payment intent: " + err.Error(),
-		})
+		slog.WarnContext(c.Request.Context(), "Failed to create payment intent", "order_id", orderWithPayment.ID, "error", err)
+		paymentFailed(c, orderWithPayment.ID, err)
 		return
 	}
 
 	// Publish order created event to message queue
 	if oc.Queue != nil {
 		orderMsg, _ := json.Marshal(orderWithPayment.Order)
-		if err := oc.Queue.PublishMessage(queue.Config{
+		if err := oc.Queue.PublishMessage(c.Request.Context(), queue.Config{
 			QueueName:    "orders",
 			RoutingKey:   "order.created",
 			ExchangeName: "orders",
 		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
+			slog.WarnContext(c.Request.Context(), "Failed to publish order created event", "order_id", orderWithPayment.ID, "error", err)
 		}
 	}
 
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(orderWithPayment.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
+	// Send notification using circuit breaker, in the background but as part of this trace
+	ctx := context.WithoutCancel(c.Request.Context())
+	oc.goBackground(func() {
+		if err := oc.NotificationService.SendOrderNotification(ctx, orderWithPayment.ID, orderWithPayment.CustomerID); err != nil {
+			slog.ErrorContext(ctx, "Failed to send order notification", "order_id", orderWithPayment.ID, "error", err)
 		}
-	}()
+	})
 
 	c.JSON(http.StatusCreated, gin.H{
 		"order": orderWithPayment.Order,
@@ -284,26 +642,53 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 	})
 }
 
-// GetOrders returns all orders
+// orderListing describes how orders are filtered, sorted and paged
+var orderListing = &listing.Spec[model.Order]{
+	Table:   "orders",
+	Columns: orderColumns,
+	Key:     "id",
+	KeyOf:   func(o model.Order) int64 { return int64(o.ID) },
+	Filters: []listing.Filter{
+		{Param: "status", Column: "status", Op: listing.Eq, Kind: listing.String},
+		{Param: "customer_id", Column: "customer_id", Op: listing.Eq, Kind: listing.Int},
+		{Param: "product_id", Column: "product_id", Op: listing.Eq, Kind: listing.Int},
+		{Param: "created_from", Column: "created_at", Op: listing.Gte, Kind: listing.Time},
+		{Param: "created_to", Column: "created_at", Op: listing.Lt, Kind: listing.Time},
+	},
+	Sorts: map[string]listing.Field[model.Order]{
+		"id":          {Column: "id", Kind: listing.Int, Value: func(o model.Order) interface{} { return o.ID }},
+		"created_at":  {Column: "created_at", Kind: listing.Time, Value: func(o model.Order) interface{} { return o.CreatedAt }},
+		"total_price": {Column: "total_price", Kind: listing.Float, Value: func(o model.Order) interface{} { return o.TotalPrice }},
+	},
+	DefaultSort: "-created_at",
+}
+
+// OrderListParams describes the query parameters GetOrders accepts
+func OrderListParams() []listing.Param {
+	return orderListing.Params()
+}
+
+// scanOrder reads an order row selected with orderListing's columns
+func scanOrder(rows *sql.Rows) (model.Order, error) {
+	var o model.Order
+	err := rows.Scan(orderFields(&o)...)
+	return o, err
+}
+
+// GetOrders returns a page of orders, newest first unless sorted otherwise
 func (oc *OrderController) GetOrders(c *gin.Context) {
-	rows, err := oc.DB.Query("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	req, p := orderListing.Parse(c.Request.URL.Query())
+	if p != nil {
+		problem.Write(c, p)
 		return
 	}
-	defer rows.Close()
 
-	var orders []model.Order
-	for rows.Next() {
-		var o model.Order
-		if err := rows.Scan(&o.ID, &o.CustomerID, &o.ProductID, &o.Quantity, &o.TotalPrice, &o.Status); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		orders = append(orders, o)
+	page, err := listing.List(c.Request.Context(), oc.DB, req, scanOrder)
+	if err != nil {
+		problem.Internal(c, "Failed to list orders", err)
+		return
 	}
-
-	c.JSON(http.StatusOK, orders)
+	listing.Respond(c, page)
 }
 
 // GetOrder returns a specific order by ID
@@ -312,41 +697,45 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 
 	// Try to get order from cache first
 	var order model.Order
-	cacheKey := "order:" + orderID
+	cacheKey := OrderCacheKey(orderID)
 	
 	// If cache is not available, get directly from database
 	if oc.Cache == nil {
 		if oc.OrderRepo != nil {
-			order, err := oc.OrderRepo.GetOrderFromDB(orderID)
+			order, err := oc.OrderRepo.GetOrderFromDB(c.Request.Context(), orderID)
 			if err != nil {
 				if err == sql.ErrNoRows {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+					problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %s does not exist", orderID))
 					return
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order: " + err.Error()})
+				problem.Internal(c, "Failed to get order", err)
 				return
 			}
 			c.JSON(http.StatusOK, order)
 			return
 		}
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %s does not exist", orderID))
 		return
 	}
 	
-	err := oc.Cache.GetOrSet(cacheKey, &order, 30*time.Minute, func() (interface{}, error) {
-		// If not in cache, get from database
-		if oc.OrderRepo != nil {
-			return oc.OrderRepo.GetOrderFromDB(orderID)
+	err := oc.Cache.GetOrSet(c.Request.Context(), cacheKey, &order, orderCacheTTL, func(ctx context.Context) (interface{}, error) {
+		// If not in cache, get from database; a missing order is cached briefly too
+		if oc.OrderRepo == nil {
+			return nil, cache.ErrNotFound
+		}
+		order, err := oc.OrderRepo.GetOrderFromDB(ctx, orderID)
+		if err == sql.ErrNoRows {
+			return nil, cache.ErrNotFound
 		}
-		return nil, sql.ErrNoRows
+		return order, err
 	})
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		if errors.Is(err, cache.ErrNotFound) {
+			problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %s does not exist", orderID))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order: " + err.Error()})
+		problem.Internal(c, "Failed to get order", err)
 		return
 	}
 
@@ -357,153 +746,107 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 func (oc *OrderController) UpdateOrder(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
 		return
 	}
 
 	// Get existing order to compare status change
-	var existingOrder model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&existingOrder.ID, &existingOrder.CustomerID, &existingOrder.ProductID, &existingOrder.Quantity, &existingOrder.TotalPrice, &existingOrder.Status)
-
+	existingOrder, err := oc.OrderRepo.GetOrderFromDB(c.Request.Context(), strconv.Itoa(id))
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to update order", err)
 		return
 	}
 
 	var updatedOrder model.Order
-	if err := c.BindJSON(&updatedOrder); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &updatedOrder) {
 		return
 	}
-
-	result, err := oc.DB.Exec(
-		"UPDATE orders SET customer_id = $1, product_id = $2, quantity = $3, total_price = $4, status = $5 WHERE id = $6",
-		updatedOrder.CustomerID, updatedOrder.ProductID, updatedOrder.Quantity, updatedOrder.TotalPrice, updatedOrder.Status, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !changeable(c, existingOrder, updatedOrder.Status) || !sameStock(c, existingOrder, &updatedOrder) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	updatedOrder.ID = id
+	updatedOrder.CancelledAt, updatedOrder.CancelReason = nil, ""
+	err = oc.OrderRepo.UpdateOrder(c.Request.Context(), &updatedOrder)
+	if err == sql.ErrNoRows {
+		oc.notUpdated(c, id)
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to update order", err)
 		return
 	}
+	oc.invalidateOrder(c.Request.Context(), id)
 
 	// If status changed, send notification
 	if existingOrder.Status != updatedOrder.Status {
-		err = oc.NotificationService.SendOrderStatusUpdate(id, updatedOrder.CustomerID, updatedOrder.Status)
+		err = oc.NotificationService.SendOrderStatusUpdate(c.Request.Context(), id, updatedOrder.CustomerID, updatedOrder.Status)
 		if err != nil {
 			// Log the error but continue (non-blocking)
-			fmt.Printf("Failed to send status update notification: %v\n", err)
+			slog.WarnContext(c.Request.Context(), "Failed to send status update notification", "order_id", id, "error", err)
 		}
 	}
 
-	updatedOrder.ID = id
 	c.JSON(http.StatusOK, updatedOrder)
 }
 
-// DeleteOrder deletes an order
-func (oc *OrderController) DeleteOrder(c *gin.Context) {
-	id := c.Param("id")
-
-	// Get the order first
-	var order model.Order
-	err := oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Delete the order
-	result, err := oc.DB.Exec("DELETE FROM orders WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
-	}
-
-	// Send notification that order was deleted/cancelled
-	err = oc.NotificationService.SendOrderStatusUpdate(order.ID, order.CustomerID, "cancelled")
-	if err != nil {
-		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send cancellation notification: %v\n", err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
-}
-
 // UpdateOrderStatus updates only the status of an order
 func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
 		return
 	}
 
 	var statusUpdate struct {
-		Status string `json:"status"`
+		Status string `json:"status" binding:"required,order_status"`
 	}
-	if err := c.BindJSON(&statusUpdate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &statusUpdate) {
 		return
 	}
 
 	// Get existing order to get customer ID
-	var order model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
-
+	order, err := oc.OrderRepo.GetOrderFromDB(c.Request.Context(), strconv.Itoa(id))
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to update order status", err)
+		return
+	}
+	if !changeable(c, order, statusUpdate.Status) {
 		return
 	}
 
 	// Update order status
-	result, err := oc.DB.Exec("UPDATE orders SET status = $1 WHERE id = $2", statusUpdate.Status, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	err = oc.OrderRepo.UpdateOrderStatus(c.Request.Context(), id, statusUpdate.Status)
+	if err == sql.ErrNoRows {
+		oc.notUpdated(c, id)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	if err != nil {
+		problem.Internal(c, "Failed to update order status", err)
 		return
 	}
+	oc.invalidateOrder(c.Request.Context(), id)
 
 	metrics.OrderStatusUpdated.WithLabelValues(statusUpdate.Status).Inc()
 
 	// Update active orders metric based on status
-	if statusUpdate.Status == "completed" || statusUpdate.Status == "cancelled" {
+	if statusUpdate.Status == "completed" {
 		metrics.ActiveOrders.Dec()
 	}
 
 	// Send notification about status change
-	err = oc.NotificationService.SendOrderStatusUpdate(id, order.CustomerID, statusUpdate.Status)
+	err = oc.NotificationService.SendOrderStatusUpdate(c.Request.Context(), id, order.CustomerID, statusUpdate.Status)
 	if err != nil {
 		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send status update notification: %v\n", err)
+		slog.WarnContext(c.Request.Context(), "Failed to send status update notification", "order_id", id, "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -513,20 +856,46 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 	})
 }
 
+// maxBatchSize is the largest number of orders accepted in one batch
+const maxBatchSize = 100
+
+// batchFailure describes an order a batch failed to process with a problem
+// code and a detail that is safe to show the client
+func batchFailure(ctx context.Context, result worker.Result) map[string]interface{} {
+	code, detail := problem.CodeInternal, "The order could not be processed"
+	var p *problem.Problem
+	switch {
+	case errors.As(result.Error, &p):
+		code, detail = p.Code, p.Detail
+	case errors.Is(result.Error, context.DeadlineExceeded), errors.Is(result.Error, context.Canceled):
+		code, detail = problem.CodeUnavailable, "The batch stopped before the order was processed"
+	default:
+		slog.ErrorContext(ctx, "Failed to process batch order", "order_id", result.OrderID, "error", result.Error)
+	}
+	return map[string]interface{}{
+		"order_id": result.OrderID,
+		"code":     code,
+		"detail":   detail,
+	}
+}
+
 // CreateBatchOrders handles creation of multiple orders in parallel
 func (oc *OrderController) CreateBatchOrders(c *gin.Context) {
 	var orders []model.Order
-	if err := c.ShouldBindJSON(&orders); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &orders) {
+		return
+	}
+	if len(orders) == 0 || len(orders) > maxBatchSize {
+		problem.Abort(c, problem.CodeValidation, fmt.Sprintf("A batch must hold 1 to %d orders", maxBatchSize))
 		return
 	}
 
 	// Configure batch processing
-	numWorkers := 10            // Số lượng worker xử lý song song
-	timeout := 30 * time.Second // Thời gian timeout cho toàn bộ batch
+	numWorkers := 10            // Orders processed in parallel
+	timeout := 30 * time.Second // Time limit for the whole batch
 
 	// Process orders in parallel using worker pool
-	results := worker.ProcessBatch(orders, numWorkers, timeout)
+	results := worker.ProcessBatch(c.Request.Context(), orders, numWorkers, timeout)
 
 	// Count successes and failures
 	successful := 0
@@ -536,10 +905,7 @@ func (oc *OrderController) CreateBatchOrders(c *gin.Context) {
 	for _, result := range results {
 		if result.Error != nil {
 			failed++
-			failedOrders = append(failedOrders, map[string]interface{}{
-				"order_id": result.OrderID,
-				"error":    result.Error.Error(),
-			})
+			failedOrders = append(failedOrders, batchFailure(c.Request.Context(), result))
 		} else {
 			successful++
 		}
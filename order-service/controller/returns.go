@@ -0,0 +1,421 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/metrics"
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReturnRepository defines the interface for order return database operations.
+// GetReturn and TransitionReturn return sql.ErrNoRows when the return does not
+// exist, and TransitionReturn also when the return is no longer in status from.
+type ReturnRepository interface {
+	CreateReturn(ctx context.Context, ret *model.Return, orderQuantity int) error
+	ListReturns(ctx context.Context, orderID int) ([]model.Return, error)
+	GetReturn(ctx context.Context, orderID, returnID int) (*model.Return, error)
+	TransitionReturn(ctx context.Context, ret *model.Return, from string) error
+	RefundedReturns(ctx context.Context, orderID int) (units int, amount float64, err error)
+}
+
+// ReturnQuantityError is returned by CreateReturn when the order has fewer
+// units left to return than were asked for
+type ReturnQuantityError struct {
+	Returnable int
+}
+
+func (e *ReturnQuantityError) Error() string {
+	return fmt.Sprintf("only %d units can still be returned", e.Returnable)
+}
+
+// DBReturnRepository implements ReturnRepository using SQL database
+type DBReturnRepository struct {
+	DB *sql.DB
+	// Timeout bounds each query within the caller's deadline; zero means no extra bound
+	Timeout time.Duration
+}
+
+// returnColumns are the columns a return is read from, in returnFields' order
+const returnColumns = "id, order_id, quantity, reason, COALESCE(comment, ''), status, COALESCE(note, ''), refund_amount, created_at, updated_at"
+
+// returnFields returns the scan destinations for returnColumns
+func returnFields(r *model.Return) []interface{} {
+	return []interface{}{&r.ID, &r.OrderID, &r.Quantity, &r.Reason, &r.Comment, &r.Status, &r.Note, &r.RefundAmount, &r.CreatedAt, &r.UpdatedAt}
+}
+
+// CreateReturn stores a requested return of an order with orderQuantity
+// units. The order is locked while the units already being returned are
+// counted, so concurrent requests can't return more than was ordered.
+func (r *DBReturnRepository) CreateReturn(ctx context.Context, ret *model.Return, orderQuantity int) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM orders WHERE id = $1 FOR UPDATE", ret.OrderID).Scan(&id); err != nil {
+		return err
+	}
+	var returning int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(quantity), 0) FROM order_returns WHERE order_id = $1 AND status <> $2",
+		ret.OrderID, model.ReturnRejected).Scan(&returning)
+	if err != nil {
+		return err
+	}
+	if returning+ret.Quantity > orderQuantity {
+		return &ReturnQuantityError{Returnable: max(orderQuantity-returning, 0)}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO order_returns (order_id, quantity, reason, comment, status, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NOW(), NOW())
+		RETURNING `+returnColumns,
+		ret.OrderID, ret.Quantity, ret.Reason, ret.Comment, model.ReturnRequested,
+	).Scan(returnFields(ret)...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListReturns returns the returns of an order, oldest first
+func (r *DBReturnRepository) ListReturns(ctx context.Context, orderID int) ([]model.Return, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+returnColumns+" FROM order_returns WHERE order_id = $1 ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []model.Return{}
+	for rows.Next() {
+		var ret model.Return
+		if err := rows.Scan(returnFields(&ret)...); err != nil {
+			return nil, err
+		}
+		returns = append(returns, ret)
+	}
+	return returns, rows.Err()
+}
+
+// GetReturn retrieves a return of an order
+func (r *DBReturnRepository) GetReturn(ctx context.Context, orderID, returnID int) (*model.Return, error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var ret model.Return
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT "+returnColumns+" FROM order_returns WHERE id = $1 AND order_id = $2", returnID, orderID,
+	).Scan(returnFields(&ret)...)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// TransitionReturn stores ret's status, note and refund amount if the return
+// is still in status from, so that concurrent transitions can't both apply
+func (r *DBReturnRepository) TransitionReturn(ctx context.Context, ret *model.Return, from string) error {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, `
+		UPDATE order_returns SET status = $1, note = NULLIF($2, ''), refund_amount = $3, updated_at = NOW()
+		WHERE id = $4 AND status = $5
+		RETURNING updated_at`,
+		ret.Status, ret.Note, ret.RefundAmount, ret.ID, from,
+	).Scan(&ret.UpdatedAt)
+}
+
+// RefundedReturns counts the units of an order's refunded returns and the
+// amount refunded for them
+func (r *DBReturnRepository) RefundedReturns(ctx context.Context, orderID int) (units int, amount float64, err error) {
+	ctx, cancel := withTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	err = r.DB.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(quantity), 0), COALESCE(SUM(refund_amount), 0) FROM order_returns WHERE order_id = $1 AND status = $2",
+		orderID, model.ReturnRefunded).Scan(&units, &amount)
+	return units, amount, err
+}
+
+// returnOrder reads the order of a return route and answers when it is
+// missing or the ID is invalid
+func (oc *OrderController) returnOrder(c *gin.Context) (*model.Order, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return nil, false
+	}
+	order, err := oc.OrderRepo.GetOrderFromDB(c.Request.Context(), strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		problem.Abort(c, problem.CodeOrderNotFound, fmt.Sprintf("Order %d does not exist", id))
+		return nil, false
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve order", err)
+		return nil, false
+	}
+	return order, true
+}
+
+// orderReturn reads the order and return of a return route and answers when
+// either is missing or an ID is invalid
+func (oc *OrderController) orderReturn(c *gin.Context) (*model.Order, *model.Return, bool) {
+	returnID, err := strconv.Atoi(c.Param("returnId"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "Return ID must be an integer")
+		return nil, nil, false
+	}
+	order, ok := oc.returnOrder(c)
+	if !ok {
+		return nil, nil, false
+	}
+	ret, err := oc.ReturnRepo.GetReturn(c.Request.Context(), order.ID, returnID)
+	if err == sql.ErrNoRows {
+		problem.Abort(c, problem.CodeReturnNotFound, fmt.Sprintf("Order %d has no return %d", order.ID, returnID))
+		return nil, nil, false
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve return", err)
+		return nil, nil, false
+	}
+	return order, ret, true
+}
+
+// returnNotAllowed answers when a return is not in a status the step applies to
+func returnNotAllowed(c *gin.Context, ret *model.Return, step string) {
+	p := problem.New(problem.CodeReturnNotAllowed, fmt.Sprintf("Return %d is %s and can't be %s", ret.ID, ret.Status, step))
+	problem.Write(c, p.With("status", ret.Status))
+}
+
+// transition moves ret from status from to status to and tells the customer.
+// It answers and returns false when the return was moved concurrently.
+func (oc *OrderController) transition(c *gin.Context, order *model.Order, ret *model.Return, from, to string) bool {
+	ret.Status = to
+	err := oc.ReturnRepo.TransitionReturn(c.Request.Context(), ret, from)
+	if err == sql.ErrNoRows {
+		ret.Status = from
+		p := problem.New(problem.CodeReturnNotAllowed, fmt.Sprintf("Return %d was changed by another request, reload it and try again", ret.ID))
+		problem.Write(c, p.With("status", from))
+		return false
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to update return", err)
+		return false
+	}
+	oc.returned(c.Request.Context(), *order, *ret)
+	return true
+}
+
+// returned records that a return reached its status and tells the customer
+func (oc *OrderController) returned(ctx context.Context, order model.Order, ret model.Return) {
+	metrics.OrderReturns.WithLabelValues(ret.Status).Inc()
+	slog.InfoContext(ctx, "Order return "+ret.Status, "order_id", order.ID, "return_id", ret.ID)
+
+	notifyCtx := context.WithoutCancel(ctx)
+	oc.goBackground(func() {
+		if err := oc.NotificationService.SendOrderStatusUpdate(notifyCtx, order.ID, order.CustomerID, "return_"+ret.Status); err != nil {
+			slog.WarnContext(notifyCtx, "Failed to send return notification", "order_id", order.ID, "return_id", ret.ID, "error", err)
+		}
+	})
+}
+
+// RequestReturn asks to return some or all of a delivered order's units
+func (oc *OrderController) RequestReturn(c *gin.Context) {
+	order, ok := oc.returnOrder(c)
+	if !ok {
+		return
+	}
+
+	var req model.ReturnRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	if !model.Returnable(order.Status) {
+		p := problem.New(problem.CodeReturnNotAllowed, fmt.Sprintf("Order %d is %s; only delivered orders can be returned", order.ID, order.Status))
+		problem.Write(c, p.With("status", order.Status))
+		return
+	}
+
+	ret := model.Return{OrderID: order.ID, Quantity: req.Quantity, Reason: req.Reason, Comment: req.Comment}
+	err := oc.ReturnRepo.CreateReturn(c.Request.Context(), &ret, order.Quantity)
+	var exceeded *ReturnQuantityError
+	if errors.As(err, &exceeded) {
+		p := problem.New(problem.CodeReturnNotAllowed, fmt.Sprintf("Only %d units of order %d can still be returned", exceeded.Returnable, order.ID))
+		problem.Write(c, p.With("returnable", exceeded.Returnable))
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to create return", err)
+		return
+	}
+	oc.returned(c.Request.Context(), *order, ret)
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// GetReturns lists the returns of an order, oldest first
+func (oc *OrderController) GetReturns(c *gin.Context) {
+	order, ok := oc.returnOrder(c)
+	if !ok {
+		return
+	}
+
+	returns, err := oc.ReturnRepo.ListReturns(c.Request.Context(), order.ID)
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve returns", err)
+		return
+	}
+	c.JSON(http.StatusOK, returns)
+}
+
+// ApproveReturn accepts a requested return; the customer can then send the
+// units back. The body with a note is optional.
+func (oc *OrderController) ApproveReturn(c *gin.Context) {
+	order, ret, ok := oc.orderReturn(c)
+	if !ok {
+		return
+	}
+
+	var req model.ReturnApproval
+	if c.Request.ContentLength != 0 && !problem.BindJSON(c, &req) {
+		return
+	}
+	if ret.Status != model.ReturnRequested {
+		returnNotAllowed(c, ret, "approved")
+		return
+	}
+
+	ret.Note = req.Note
+	if !oc.transition(c, order, ret, model.ReturnRequested, model.ReturnApproved) {
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// RejectReturn turns down a requested return with a note for the customer.
+// Its units can be asked to be returned again.
+func (oc *OrderController) RejectReturn(c *gin.Context) {
+	order, ret, ok := oc.orderReturn(c)
+	if !ok {
+		return
+	}
+
+	var req model.ReturnRejection
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	if ret.Status != model.ReturnRequested {
+		returnNotAllowed(c, ret, "rejected")
+		return
+	}
+
+	ret.Note = req.Note
+	if !oc.transition(c, order, ret, model.ReturnRequested, model.ReturnRejected) {
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// ReceiveReturn records that the units of an approved return arrived, puts
+// them back into stock and refunds their share of the order's price. When the
+// restock or refund fails the return stays received and receiving it again
+// retries them; both are idempotent per return.
+func (oc *OrderController) ReceiveReturn(c *gin.Context) {
+	order, ret, ok := oc.orderReturn(c)
+	if !ok {
+		return
+	}
+
+	switch ret.Status {
+	case model.ReturnApproved:
+		if !oc.transition(c, order, ret, model.ReturnApproved, model.ReturnReceived) {
+			return
+		}
+	case model.ReturnReceived:
+		// A previous restock or refund failed
+	default:
+		returnNotAllowed(c, ret, "received")
+		return
+	}
+	ctx := c.Request.Context()
+
+	if err := oc.InventoryService.RestockReturn(ctx, ret.ID, order.ID, order.ProductID, ret.Quantity); err != nil {
+		metrics.OrderReturnSteps.WithLabelValues("restock", "failure").Inc()
+		slog.WarnContext(ctx, "Failed to restock returned units", "order_id", order.ID, "return_id", ret.ID, "error", err)
+		p := problem.New(problem.CodeUnavailable, "The return was received but its units could not be restocked, try again later")
+		problem.Write(c, p.With("return_id", ret.ID))
+		return
+	}
+	metrics.OrderReturnSteps.WithLabelValues("restock", "success").Inc()
+
+	refundedUnits, refunded, err := oc.ReturnRepo.RefundedReturns(ctx, order.ID)
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve refunded returns", err)
+		return
+	}
+	amount := refundAmount(*order, ret.Quantity, refundedUnits, refunded)
+	if amount > 0 {
+		refund, err := oc.PaymentService.RefundOrder(ctx, order.ID, amount, fmt.Sprintf("return-%d", ret.ID))
+		var p *problem.Problem
+		switch {
+		case errors.As(err, &p) && p.Code == problem.CodePaymentNotFound:
+			// The order was not paid through the payment service
+			slog.InfoContext(ctx, "Returned order has no payment to refund", "order_id", order.ID, "return_id", ret.ID)
+			amount = 0
+		case err != nil:
+			metrics.OrderReturnSteps.WithLabelValues("refund", "failure").Inc()
+			slog.WarnContext(ctx, "Failed to refund return", "order_id", order.ID, "return_id", ret.ID, "error", err)
+			failed := problem.New(problem.CodePaymentFailed, "The return was received but could not be refunded, try again later")
+			problem.Write(c, failed.With("return_id", ret.ID))
+			return
+		default:
+			amount = refund.Amount
+		}
+	}
+	metrics.OrderReturnSteps.WithLabelValues("refund", "success").Inc()
+
+	ret.RefundAmount = amount
+	if !oc.transition(c, order, ret, model.ReturnReceived, model.ReturnRefunded) {
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// refundAmount is what returning quantity of an order's units refunds, after
+// refundedUnits were already refunded with refunded. The price of all the
+// units returned so far is rounded to cents and what was refunded before is
+// taken off, so rounding each return can't add up to more than the order's
+// price, and the last unit returned refunds exactly what is left.
+func refundAmount(order model.Order, quantity, refundedUnits int, refunded float64) float64 {
+	if order.Quantity == 0 {
+		return 0
+	}
+	units := min(refundedUnits+quantity, order.Quantity)
+	share := cents(order.TotalPrice * float64(units) / float64(order.Quantity))
+	return min(max(cents(share-refunded), 0), cents(order.TotalPrice-refunded))
+}
+
+// cents rounds an amount to cents
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -1,65 +1,55 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
-	"os"
+	"embed"
+	"log/slog"
+
+	"go-microservices/pkg/config"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/tracing"
 
 	_ "github.com/lib/pq"
 )
 
-// GetDB returns a database connection
-func GetDB() *sql.DB {
-	// Read from environment variables or use defaults
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "orders_db")
+// migrations holds the versioned up/down SQL files for this service's schema
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
+// GetDB returns a database connection
+func GetDB(cfg config.Database) *sql.DB {
+	db, err := tracing.OpenDB("postgres", cfg.DSN())
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to open database", "error", err)
 	}
 
 	// Check if connection is established
 	err = db.Ping()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 
-	log.Println("Successfully connected to the orders database")
+	slog.Info("Successfully connected to the orders database")
 	return db
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// NewMigrator returns the migrator for the embedded orders schema migrations
+func NewMigrator(database *sql.DB) *migrate.Migrator {
+	m, err := migrate.New(database, "order-service", migrations)
+	if err != nil {
+		logging.Fatal("Failed to load migrations", "error", err)
 	}
-	return value
+	return m
 }
 
-// InitSchema initializes the database schema
-func InitSchema(db *sql.DB) {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS orders (
-		id SERIAL PRIMARY KEY,
-		customer_id INT NOT NULL,
-		product_id INT NOT NULL,
-		quantity INT NOT NULL,
-		total_price DECIMAL(10, 2) NOT NULL,
-		status VARCHAR(50) NOT NULL
-	);`
-
-	_, err := db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
+// Migrate applies pending schema migrations
+func Migrate(database *sql.DB) {
+	if err := NewMigrator(database).Up(context.Background()); err != nil {
+		logging.Fatal("Failed to migrate database", "error", err)
 	}
 
-	log.Println("Order table created or already exists")
+	slog.Info("Database schema is up to date")
 }
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_status;
+DROP INDEX IF EXISTS idx_orders_customer_id;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,2 @@
+-- Keyset pagination orders by (created_at, id) by default
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders(created_at, id);
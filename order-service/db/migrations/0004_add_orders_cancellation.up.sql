@@ -0,0 +1,3 @@
+-- Cancelled orders are kept for finance; only an admin purge deletes them
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancel_reason VARCHAR(50);
@@ -0,0 +1,16 @@
+-- Returns of delivered orders. Rejected returns don't count against the
+-- quantity of the order that can still be returned.
+CREATE TABLE IF NOT EXISTS order_returns (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    quantity INT NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    comment TEXT,
+    status VARCHAR(20) NOT NULL,
+    note TEXT,
+    refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_returns_order_id ON order_returns(order_id);
@@ -0,0 +1,120 @@
+// Package expiry cancels orders whose checkout was abandoned: orders that are
+// still pending, with a payment attempt that never succeeded, some time after
+// they were placed. Every replica runs the schedule; an order is cancelled
+// only while it is still pending, so each is expired once.
+package expiry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+// Config describes when orders expire and how often they are looked for
+type Config struct {
+	// After is how long an order may stay pending; 0 turns expiry off
+	After time.Duration `env:"ORDER_EXPIRY_AFTER"`
+	// Interval is how often each replica looks for expired orders
+	Interval time.Duration `env:"ORDER_EXPIRY_INTERVAL"`
+	// BatchSize is how many orders are listed and checked at a time
+	BatchSize int `env:"ORDER_EXPIRY_BATCH_SIZE"`
+}
+
+// DefaultConfig returns the default schedule
+func DefaultConfig() Config {
+	return Config{
+		After:     24 * time.Hour,
+		Interval:  5 * time.Minute,
+		BatchSize: 50,
+	}
+}
+
+// Validate checks the durations and batch size
+func (c *Config) Validate() error {
+	var problems []error
+	if c.After < 0 {
+		problems = append(problems, fmt.Errorf("ORDER_EXPIRY_AFTER must not be negative, got %s", c.After))
+	}
+	if c.Interval <= 0 {
+		problems = append(problems, fmt.Errorf("ORDER_EXPIRY_INTERVAL must be positive, got %s", c.Interval))
+	}
+	if c.BatchSize < 1 || c.BatchSize > 1000 {
+		problems = append(problems, fmt.Errorf("ORDER_EXPIRY_BATCH_SIZE must be between 1 and 1000, got %d", c.BatchSize))
+	}
+	return errors.Join(problems...)
+}
+
+// Expirer cancels the unpaid orders that have been pending since before
+// cutoff, limit at a time, and returns how many it cancelled
+type Expirer interface {
+	ExpireOrders(ctx context.Context, cutoff time.Time, limit int) (int, error)
+}
+
+// Scheduler runs an Expirer every Config.Interval until it is stopped
+type Scheduler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start runs expirer on cfg's schedule in the background. When expiry is
+// turned off the returned scheduler does nothing.
+func Start(cfg Config, expirer Expirer) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{cancel: cancel, done: make(chan struct{})}
+	if cfg.After == 0 {
+		slog.Info("Order expiry is turned off")
+		close(s.done)
+		return s
+	}
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A run may not overlap the next one
+				runCtx, cancelRun := context.WithTimeout(ctx, cfg.Interval)
+				Run(runCtx, cfg, expirer)
+				cancelRun()
+			}
+		}
+	}()
+	return s
+}
+
+// Stop ends the schedule and waits for a run in progress, or until ctx is done
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("order expiry still running: %w", ctx.Err())
+	}
+}
+
+// Run expires the orders that are due once and records the run's metrics
+func Run(ctx context.Context, cfg Config, expirer Expirer) (int, error) {
+	start := time.Now()
+	expired, err := expirer.ExpireOrders(ctx, start.Add(-cfg.After), cfg.BatchSize)
+	metrics.OrderExpiryDuration.Observe(time.Since(start).Seconds())
+	metrics.OrdersExpired.Add(float64(expired))
+	if err != nil {
+		metrics.OrderExpiryRuns.WithLabelValues("failure").Inc()
+		slog.ErrorContext(ctx, "Order expiry failed", "expired", expired, "error", err)
+		return expired, err
+	}
+	metrics.OrderExpiryRuns.WithLabelValues("success").Inc()
+	if expired > 0 {
+		slog.InfoContext(ctx, "Expired pending orders", "expired", expired, "after", cfg.After)
+	}
+	return expired, nil
+}
@@ -1,36 +1,81 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
 
 	"go-microservices/order-service/cache"
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/db"
+	"go-microservices/order-service/expiry"
 	"go-microservices/order-service/queue"
+	"go-microservices/order-service/resilience"
 	"go-microservices/order-service/routes"
+	"go-microservices/order-service/service"
+	"go-microservices/order-service/worker"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/lifecycle"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	logging.Init("order-service")
+	cfg := loadConfig()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "order-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
 	// Initialize database connection
-	database := db.GetDB()
-	defer database.Close()
+	database := db.GetDB(cfg.Database)
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// "migrate <command>" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := migrate.Main(context.Background(), db.NewMigrator(database), os.Args[2:])
+		database.Close()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		db.Migrate(database)
+	} else {
+		slog.Info("Skipping schema migrations", "reason", "DB_AUTO_MIGRATE=false")
+	}
 
-	// Initialize Redis
-	if err := cache.InitRedis(); err != nil {
-		log.Printf("Warning: Failed to initialize Redis: %v\n", err)
+	// Initialize Redis, behind the in-process cache tier
+	cache.Configure(cfg.Cache.Options())
+	if err := cache.InitRedis(cfg.Redis.Addr(), cfg.Redis.Password); err != nil {
+		slog.Warn("Failed to initialize Redis", "error", err)
 	}
 
+	// Drop local copies of cache entries that any replica invalidates
+	invalidations, stopInvalidations := context.WithCancel(context.Background())
+	go func() {
+		if err := cache.ListenForInvalidations(invalidations); err != nil {
+			slog.Warn("Cache invalidation listener stopped", "error", err)
+		}
+	}()
+
 	// Initialize RabbitMQ
-	if err := queue.InitRabbitMQ(); err != nil {
-		log.Printf("Warning: Failed to initialize RabbitMQ: %v\n", err)
+	if err := queue.InitRabbitMQ(cfg.RabbitMQ.URL()); err != nil {
+		slog.Warn("Failed to initialize RabbitMQ", "error", err)
 	}
-	defer queue.Close()
 
 	// Declare queues
 	orderQueue := queue.Config{
@@ -40,24 +85,71 @@ func main() {
 		ExchangeType: "topic",
 	}
 	if err := queue.DeclareQueue(orderQueue); err != nil {
-		log.Printf("Warning: Failed to declare order queue: %v\n", err)
+		slog.Warn("Failed to declare order queue", "error", err)
 	}
 
 	// Create order controller
-	orderController := controller.NewOrderController(database)
+	orderController := controller.NewOrderController(
+		database,
+		service.NewInventoryService(cfg.Services.InventoryURL, cfg.Service.Token, resilience.New("inventory-service", cfg.Resilience.Inventory)),
+		service.NewNotificationService(cfg.Services.NotificationURL, resilience.New("notification-service", cfg.Resilience.Notification)),
+		service.NewPaymentService(cfg.Services.PaymentURL, cfg.Service.Token, resilience.New("payment-service", cfg.Resilience.Payment)),
+	)
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware(), tracing.Middleware("order-service"), logging.Middleware(), logging.AccessLog())
+	router.NoRoute(problem.NoRoute)
+
+	// Add log level admin endpoint
+	logging.RegisterAdminRoutes(router, cfg.Admin.Token)
+
+	// Add liveness and readiness probes
+	checker := health.NewChecker("order-service", cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: health.SQL(database)})
+	checker.Add(health.Check{Name: "redis", Probe: cache.Ping})
+	checker.Add(health.Check{Name: "rabbitmq", Probe: queue.Ping})
+	checker.Add(health.Check{Name: "inventory-service", Critical: true, Probe: health.HTTP(http.DefaultClient, cfg.Services.InventoryURL+"/livez")})
+	checker.Add(health.Check{Name: "payment-service", Probe: health.HTTP(http.DefaultClient, cfg.Services.PaymentURL+"/livez")})
+	checker.Add(health.Check{Name: "notification-service", Probe: health.HTTP(http.DefaultClient, cfg.Services.NotificationURL+"/livez")})
+	checker.RegisterRoutes(router)
 
 	// Add prometheus metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Show the state of the resilience policies
+	router.GET("/debug/resilience", resilience.Handler)
+
 	// Setup routes
-	routes.SetupRoutes(router, orderController)
+	routes.SetupRoutes(router, orderController, cfg.Admin.Token)
+
+	// Serve the OpenAPI document and its reference page
+	openapi.Register(router, routes.Spec())
+
+	// Cancel pending orders whose checkout was abandoned
+	expiryScheduler := expiry.Start(cfg.Expiry, orderController)
 
 	// Start server
-	log.Println("Order Service starting on port 8081...")
-	if err := router.Run(":8081"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	slog.Info("Order Service starting", "port", cfg.HTTP.Port)
+	server := lifecycle.NewServer(cfg.HTTP.Addr(), router, cfg.Shutdown)
+	server.OnDrain(func() { checker.SetDraining(true) })
+	// Stop taking work before closing the connections it uses
+	server.OnShutdown("consumers", queue.StopConsumers)
+	server.OnShutdown("order expiry", expiryScheduler.Stop)
+	server.OnShutdown("background work", orderController.Wait)
+	server.OnShutdown("worker pools", worker.Wait)
+	server.OnShutdown("publisher", func(context.Context) error {
+		queue.Close()
+		return nil
+	})
+	server.OnShutdown("cache invalidations", func(context.Context) error {
+		stopInvalidations()
+		return nil
+	})
+	server.OnShutdown("redis", lifecycle.Closer(cache.Close))
+	server.OnShutdown("database", lifecycle.Closer(database.Close))
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(); err != nil {
+		logging.Fatal("Server shutdown failed", "error", err)
 	}
 }
@@ -21,6 +21,42 @@ var (
 		Help: "The total number of order status updates by status",
 	}, []string{"status"})
 
+	OrdersCancelled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_cancelled_total",
+		Help: "Cancelled orders by reason",
+	}, []string{"reason"})
+
+	OrderCompensations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_compensations_total",
+		Help: "Steps undone for cancelled orders by step (release_stock, settle_payments, refund_late_payment) and result (success, failure)",
+	}, []string{"step", "result"})
+
+	OrderReturns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_returns_total",
+		Help: "Order returns by the status they reached (requested, approved, rejected, received, refunded)",
+	}, []string{"status"})
+
+	OrderReturnSteps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_return_steps_total",
+		Help: "Steps taken for received returns by step (restock, refund) and result (success, failure)",
+	}, []string{"step", "result"})
+
+	OrdersExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_expired_total",
+		Help: "Pending orders cancelled because they were not paid in time",
+	})
+
+	OrderExpiryRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_expiry_runs_total",
+		Help: "Runs of the order expiry schedule by result (success, failure)",
+	}, []string{"result"})
+
+	OrderExpiryDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "order_expiry_duration_seconds",
+		Help:    "Time taken by a run of the order expiry schedule",
+		Buckets: prometheus.DefBuckets,
+	})
+
 	OrderProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "order_processing_duration_seconds",
 		Help:    "Time taken to process orders",
@@ -31,4 +67,54 @@ var (
 		Name: "active_orders",
 		Help: "The current number of active orders",
 	})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cache lookups by tier (local, redis) and result (hit, miss)",
+	}, []string{"tier", "result"})
+
+	CacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_errors_total",
+		Help: "Failed Redis commands by operation",
+	}, []string{"operation"})
+
+	CacheLoads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_loads_total",
+		Help: "Loads after a cache miss by result (loaded, not_found, error), loads not cached because the key was invalidated meanwhile (invalidated), and callers that shared another caller's load (shared)",
+	}, []string{"result"})
+
+	CacheEarlyRefreshes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cache_early_refreshes_total",
+		Help: "Entries reloaded before they expired",
+	})
+
+	CacheLocalEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_local_entries",
+		Help: "Entries held in the in-process cache tier",
+	})
+
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "resilience_circuit_breaker_state",
+		Help: "Circuit breaker state by policy: 0 closed, 1 half-open, 2 open",
+	}, []string{"policy"})
+
+	ResilienceCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_calls_total",
+		Help: "Calls through a resilience policy by result (success, failure, rejected)",
+	}, []string{"policy", "result"})
+
+	ResilienceRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_retries_total",
+		Help: "Retried attempts by policy",
+	}, []string{"policy"})
+
+	ResilienceRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_rejections_total",
+		Help: "Calls rejected without being attempted, by policy and reason (circuit_open, bulkhead_full)",
+	}, []string{"policy", "reason"})
+
+	BulkheadInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "resilience_bulkhead_in_flight",
+		Help: "Calls currently holding a bulkhead slot, by policy",
+	}, []string{"policy"})
 )
@@ -5,29 +5,46 @@ import "time"
 // Order represents an order entity
 type Order struct {
 	ID         int       `json:"id"`
-	CustomerID int       `json:"customer_id"`
-	ProductID  int       `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	TotalPrice float64   `json:"total_price"`
-	Status     string    `json:"status"` // pending, processing, shipped, delivered, cancelled
+	CustomerID int       `json:"customer_id" binding:"required,gt=0"`
+	ProductID  int       `json:"product_id" binding:"required,gt=0"`
+	Quantity   int       `json:"quantity" binding:"required,gt=0,max=1000"`
+	TotalPrice float64   `json:"total_price" binding:"gte=0"`
+	Status     string    `json:"status" binding:"omitempty,order_status"` // pending, processing, shipped, delivered, completed, cancelled
 	CreatedAt  time.Time `json:"created_at"`
+	// CancelledAt and CancelReason are set when the order is cancelled; they
+	// are read-only through the order routes
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
 }
 
-// InventoryCheck is used to check inventory availability
-type InventoryCheck struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
-}
+// Order statuses the cancellation and return workflows depend on
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDelivered  = "delivered"
+	StatusCompleted  = "completed"
+	StatusCancelled  = "cancelled"
+)
 
-// InventoryResponse is the response from inventory service
-type InventoryResponse struct {
-	Available bool   `json:"available"`
-	Message   string `json:"message,omitempty"`
+// Cancellable reports whether an order in status can still be cancelled:
+// once it has shipped it can only be returned
+func Cancellable(status string) bool {
+	return status == StatusPending || status == StatusProcessing
 }
 
-// OrderStatusUpdate is used to notify about order status updates
-type OrderStatusUpdate struct {
-	OrderID    int    `json:"order_id"`
-	CustomerID int    `json:"customer_id"`
-	Status     string `json:"status"`
+// Reasons an order is cancelled for
+const (
+	CancelCustomerRequest = "customer_request"
+	CancelOutOfStock      = "out_of_stock"
+	CancelPaymentFailed   = "payment_failed"
+	CancelFraudSuspected  = "fraud_suspected"
+	CancelDuplicateOrder  = "duplicate_order"
+	CancelOther           = "other"
+	// CancelExpired is set by the expiry schedule only; it can't be requested
+	CancelExpired = "expired"
+)
+
+// CancelRequest is the body of a cancellation
+type CancelRequest struct {
+	Reason string `json:"reason" binding:"required,oneof=customer_request out_of_stock payment_failed fraud_suspected duplicate_order other"`
 }
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Return is a customer's request to send back some or all of an order's
+// units. It moves from requested to approved or rejected, and an approved
+// return is received and then refunded.
+type Return struct {
+	ID       int    `json:"id"`
+	OrderID  int    `json:"order_id"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+	Comment  string `json:"comment,omitempty"`
+	Status   string `json:"status"`
+	// Note is the staff's explanation of an approval or rejection
+	Note string `json:"note,omitempty"`
+	// RefundAmount is what was refunded once the return is refunded
+	RefundAmount float64   `json:"refund_amount"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Return statuses
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+	ReturnRefunded  = "refunded"
+)
+
+// Returnable reports whether an order in status can be returned: only once
+// it has been delivered
+func Returnable(status string) bool {
+	return status == StatusDelivered || status == StatusCompleted
+}
+
+// ReturnRequest is the body of a return request
+type ReturnRequest struct {
+	Quantity int    `json:"quantity" binding:"required,gt=0,max=1000"`
+	Reason   string `json:"reason" binding:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Comment  string `json:"comment" binding:"max=1000"`
+}
+
+// ReturnApproval is the optional body of a return approval
+type ReturnApproval struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// ReturnRejection is the body of a return rejection; the customer is told why
+type ReturnRejection struct {
+	Note string `json:"note" binding:"required,notblank,max=1000"`
+}
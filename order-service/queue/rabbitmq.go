@@ -4,15 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"log/slog"
+	"sync"
+
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
 var (
 	channel *amqp.Channel
 	conn    *amqp.Connection
-	ctx     = context.Background()
+
+	// consumers tracks consumer goroutines and their tags so they can be stopped on shutdown
+	consumers    sync.WaitGroup
+	consumerMu   sync.Mutex
+	consumerTags []string
 )
 
 // Config holds RabbitMQ configuration
@@ -24,15 +33,10 @@ type Config struct {
 	ConnectionRetry int
 }
 
-// InitRabbitMQ initializes RabbitMQ connection
-func InitRabbitMQ() error {
-	rabbitHost := os.Getenv("RABBITMQ_HOST")
-	if rabbitHost == "" {
-		rabbitHost = "rabbitmq" // Docker default
-	}
-
+// InitRabbitMQ initializes the RabbitMQ connection to an amqp:// URL
+func InitRabbitMQ(url string) error {
 	var err error
-	conn, err = amqp.Dial(fmt.Sprintf("amqp://guest:guest@%s:5672/", rabbitHost))
+	conn, err = amqp.Dial(url)
 	if err != nil {
 		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
@@ -89,13 +93,18 @@ func DeclareQueue(config Config) error {
 	return nil
 }
 
-// PublishMessage publishes a message to queue
-func PublishMessage(config Config, message interface{}) error {
+// PublishMessage publishes a message to queue, carrying the trace context of
+// ctx in the message headers and its request ID as the correlation ID
+func PublishMessage(ctx context.Context, config Config, message interface{}) error {
 	body, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
+	headers := amqp.Table{}
+	ctx, span := tracing.StartPublish(ctx, config.ExchangeName, config.RoutingKey, headers)
+	defer span.End()
+
 	err = channel.PublishWithContext(
 		ctx,
 		config.ExchangeName,
@@ -103,22 +112,33 @@ func PublishMessage(config Config, message interface{}) error {
 		false, // mandatory
 		false, // immediate
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:   "application/json",
+			CorrelationId: requestid.FromContext(ctx),
+			Headers:       headers,
+			Body:          body,
 		},
 	)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
 	return nil
 }
 
-// ConsumeMessages starts consuming messages from queue
-func ConsumeMessages(config Config, handler func([]byte) error) error {
+// ConsumeMessages starts consuming messages from queue. Each message is handled
+// with a context that continues the trace it was published in and carries its
+// correlation ID as the request ID.
+func ConsumeMessages(config Config, handler func(context.Context, []byte) error) error {
+	consumerMu.Lock()
+	tag := fmt.Sprintf("%s-%d", config.QueueName, len(consumerTags)+1)
+	consumerTags = append(consumerTags, tag)
+	consumerMu.Unlock()
+
 	msgs, err := channel.Consume(
 		config.QueueName,
-		"",    // consumer
+		tag,   // consumer
 		false, // auto-ack
 		false, // exclusive
 		false, // no-local
@@ -129,16 +149,27 @@ func ConsumeMessages(config Config, handler func([]byte) error) error {
 		return fmt.Errorf("failed to register a consumer: %w", err)
 	}
 
+	consumers.Add(1)
 	go func() {
+		defer consumers.Done()
 		for msg := range msgs {
-			if err := handler(msg.Body); err != nil {
-				fmt.Printf("Error processing message: %v\n", err)
+			ctx := requestid.WithID(context.Background(), msg.CorrelationId)
+			ctx, span := tracing.StartConsume(ctx, config.QueueName, msg)
+			err := handler(ctx, msg.Body)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "handler failed")
+			}
+			span.End()
+
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to process message", "queue", config.QueueName, "error", err)
 				if err := msg.Nack(false, true); err != nil { // Negative acknowledgement, requeue
-					fmt.Printf("Error sending nack: %v\n", err)
+					slog.ErrorContext(ctx, "Failed to nack message", "queue", config.QueueName, "error", err)
 				}
 			} else {
 				if err := msg.Ack(false); err != nil { // Positive acknowledgement
-					fmt.Printf("Error sending ack: %v\n", err)
+					slog.ErrorContext(ctx, "Failed to ack message", "queue", config.QueueName, "error", err)
 				}
 			}
 		}
@@ -147,16 +178,55 @@ func ConsumeMessages(config Config, handler func([]byte) error) error {
 	return nil
 }
 
+// StopConsumers cancels every consumer and waits for messages being handled to
+// finish; unacknowledged messages are redelivered to another instance
+func StopConsumers(ctx context.Context) error {
+	consumerMu.Lock()
+	tags := consumerTags
+	consumerTags = nil
+	consumerMu.Unlock()
+
+	for _, tag := range tags {
+		if err := channel.Cancel(tag, false); err != nil {
+			slog.WarnContext(ctx, "Failed to cancel consumer", "consumer", tag, "error", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("consumers still running: %w", ctx.Err())
+	}
+}
+
+// Ping checks that the RabbitMQ connection and channel are open
+func Ping(ctx context.Context) error {
+	if conn == nil || channel == nil {
+		return fmt.Errorf("rabbitmq not initialized")
+	}
+	if conn.IsClosed() || channel.IsClosed() {
+		return fmt.Errorf("rabbitmq connection closed")
+	}
+	return ctx.Err()
+}
+
 // Close closes RabbitMQ connection
 func Close() {
 	if channel != nil {
 		if err := channel.Close(); err != nil {
-			fmt.Printf("Error closing channel: %v\n", err)
+			slog.Error("Failed to close RabbitMQ channel", "error", err)
 		}
 	}
 	if conn != nil {
 		if err := conn.Close(); err != nil {
-			fmt.Printf("Error closing connection: %v\n", err)
+			slog.Error("Failed to close RabbitMQ connection", "error", err)
 		}
 	}
 }
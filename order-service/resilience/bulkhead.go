@@ -0,0 +1,66 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+// ErrBulkheadFull is returned, wrapped, for calls rejected because too many
+// are already in flight
+var ErrBulkheadFull = errors.New("too many calls in flight")
+
+// bulkhead caps the calls in flight to one dependency, so a slow dependency
+// can tie up only its own share of the service's goroutines and connections
+type bulkhead struct {
+	name    string
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// newBulkhead returns a bulkhead with max slots; max 0 means no cap
+func newBulkhead(name string, max int, maxWait time.Duration) *bulkhead {
+	b := &bulkhead{name: name, maxWait: maxWait}
+	if max > 0 {
+		b.slots = make(chan struct{}, max)
+	}
+	return b
+}
+
+// acquire takes a slot, waiting up to maxWait for one to free up. The
+// returned function gives the slot back.
+func (b *bulkhead) acquire(ctx context.Context) (func(), error) {
+	if b.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+	default:
+		if b.maxWait <= 0 {
+			return nil, ErrBulkheadFull
+		}
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		select {
+		case b.slots <- struct{}{}:
+		case <-timer.C:
+			return nil, ErrBulkheadFull
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	metrics.BulkheadInFlight.WithLabelValues(b.name).Inc()
+	return func() {
+		<-b.slots
+		metrics.BulkheadInFlight.WithLabelValues(b.name).Dec()
+	}, nil
+}
+
+// inFlight returns the number of calls holding a slot
+func (b *bulkhead) inFlight() int {
+	return len(b.slots)
+}
@@ -0,0 +1,124 @@
+package resilience
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config describes a policy. Its keys are relative: embed it in a service's
+// configuration under a prefix tag, e.g. prefix:"PAYMENT_SERVICE_" reads
+// PAYMENT_SERVICE_TIMEOUT.
+type Config struct {
+	// Timeout bounds each attempt; the caller's context bounds the whole call
+	Timeout time.Duration `env:"TIMEOUT"`
+
+	// MaxRetries is how many times a failed call is retried; 0 disables retries
+	MaxRetries int `env:"MAX_RETRIES"`
+	// RetryBaseDelay is the wait before the first retry; it doubles for each
+	// later one, up to RetryMaxDelay
+	RetryBaseDelay time.Duration `env:"RETRY_BASE_DELAY"`
+	RetryMaxDelay  time.Duration `env:"RETRY_MAX_DELAY"`
+	// RetryJitter is the fraction of each wait that is randomized, from 0
+	// (fixed waits) to 1 (anywhere between zero and the full wait)
+	RetryJitter float64 `env:"RETRY_JITTER"`
+
+	// The breaker opens after BreakerConsecutiveFailures failures in a row
+	// (0 disables that rule), or when at least BreakerMinRequests calls in
+	// the current BreakerInterval failed at BreakerFailureRatio or more
+	BreakerConsecutiveFailures uint32        `env:"BREAKER_CONSECUTIVE_FAILURES"`
+	BreakerMinRequests         uint32        `env:"BREAKER_MIN_REQUESTS"`
+	BreakerFailureRatio        float64       `env:"BREAKER_FAILURE_RATIO"`
+	BreakerInterval            time.Duration `env:"BREAKER_INTERVAL"`
+	// BreakerOpenTimeout is how long the breaker stays open before it lets
+	// BreakerHalfOpenRequests trial calls through
+	BreakerOpenTimeout      time.Duration `env:"BREAKER_OPEN_TIMEOUT"`
+	BreakerHalfOpenRequests uint32        `env:"BREAKER_HALF_OPEN_REQUESTS"`
+
+	// MaxConcurrent caps calls in flight (0 means no cap); a call that finds
+	// no free slot within BulkheadMaxWait is rejected
+	MaxConcurrent   int           `env:"MAX_CONCURRENT"`
+	BulkheadMaxWait time.Duration `env:"BULKHEAD_MAX_WAIT"`
+}
+
+// DefaultConfig returns the settings of a policy for an idempotent call
+func DefaultConfig() Config {
+	return Config{
+		Timeout:                 2 * time.Second,
+		MaxRetries:              2,
+		RetryBaseDelay:          100 * time.Millisecond,
+		RetryMaxDelay:           2 * time.Second,
+		RetryJitter:             0.5,
+		BreakerMinRequests:      10,
+		BreakerFailureRatio:     0.5,
+		BreakerInterval:         10 * time.Second,
+		BreakerOpenTimeout:      30 * time.Second,
+		BreakerHalfOpenRequests: 3,
+		MaxConcurrent:           100,
+		BulkheadMaxWait:         100 * time.Millisecond,
+	}
+}
+
+// ValidatePrefixed checks the settings, naming keys with prefix
+func (c *Config) ValidatePrefixed(prefix string) error {
+	var problems []error
+	for _, setting := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"TIMEOUT", c.Timeout},
+		{"RETRY_BASE_DELAY", c.RetryBaseDelay},
+		{"RETRY_MAX_DELAY", c.RetryMaxDelay},
+		{"BREAKER_INTERVAL", c.BreakerInterval},
+		{"BREAKER_OPEN_TIMEOUT", c.BreakerOpenTimeout},
+	} {
+		if setting.value <= 0 {
+			problems = append(problems, fmt.Errorf("%s%s must be positive, got %s", prefix, setting.key, setting.value))
+		}
+	}
+	if c.RetryMaxDelay < c.RetryBaseDelay {
+		problems = append(problems, fmt.Errorf("%sRETRY_MAX_DELAY must not be less than %sRETRY_BASE_DELAY", prefix, prefix))
+	}
+	if c.MaxRetries < 0 {
+		problems = append(problems, fmt.Errorf("%sMAX_RETRIES must not be negative, got %d", prefix, c.MaxRetries))
+	}
+	if c.RetryJitter < 0 || c.RetryJitter > 1 {
+		problems = append(problems, fmt.Errorf("%sRETRY_JITTER must be between 0 and 1, got %g", prefix, c.RetryJitter))
+	}
+	if c.BreakerFailureRatio <= 0 || c.BreakerFailureRatio > 1 {
+		problems = append(problems, fmt.Errorf("%sBREAKER_FAILURE_RATIO must be above 0 and at most 1, got %g", prefix, c.BreakerFailureRatio))
+	}
+	if c.BreakerMinRequests == 0 {
+		problems = append(problems, fmt.Errorf("%sBREAKER_MIN_REQUESTS must be positive", prefix))
+	}
+	if c.BreakerHalfOpenRequests == 0 {
+		problems = append(problems, fmt.Errorf("%sBREAKER_HALF_OPEN_REQUESTS must be positive", prefix))
+	}
+	if c.MaxConcurrent < 0 {
+		problems = append(problems, fmt.Errorf("%sMAX_CONCURRENT must not be negative, got %d", prefix, c.MaxConcurrent))
+	}
+	if c.BulkheadMaxWait < 0 {
+		problems = append(problems, fmt.Errorf("%sBULKHEAD_MAX_WAIT must not be negative, got %s", prefix, c.BulkheadMaxWait))
+	}
+	return errors.Join(problems...)
+}
+
+// MarshalJSON writes the settings with durations as strings such as "2s"
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"timeout":                      c.Timeout.String(),
+		"max_retries":                  c.MaxRetries,
+		"retry_base_delay":             c.RetryBaseDelay.String(),
+		"retry_max_delay":              c.RetryMaxDelay.String(),
+		"retry_jitter":                 c.RetryJitter,
+		"breaker_consecutive_failures": c.BreakerConsecutiveFailures,
+		"breaker_min_requests":         c.BreakerMinRequests,
+		"breaker_failure_ratio":        c.BreakerFailureRatio,
+		"breaker_interval":             c.BreakerInterval.String(),
+		"breaker_open_timeout":         c.BreakerOpenTimeout.String(),
+		"breaker_half_open_requests":   c.BreakerHalfOpenRequests,
+		"max_concurrent":               c.MaxConcurrent,
+		"bulkhead_max_wait":            c.BulkheadMaxWait.String(),
+	})
+}
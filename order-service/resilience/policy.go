@@ -0,0 +1,202 @@
+// Package resilience guards calls to other services with named policies that
+// combine a per-attempt timeout, retries with exponential backoff and jitter,
+// a circuit breaker and a bulkhead
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"go-microservices/order-service/metrics"
+	"go-microservices/pkg/problem"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrCircuitOpen is returned, wrapped, for calls the circuit breaker rejects
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Policy guards the calls to one dependency. Create it with New; it is safe
+// for concurrent use.
+type Policy struct {
+	name     string
+	config   Config
+	breaker  *gobreaker.CircuitBreaker
+	bulkhead *bulkhead
+}
+
+// New creates the policy called name and registers it for GET
+// /debug/resilience. A policy created under a name already in use replaces
+// the earlier one there.
+func New(name string, config Config) *Policy {
+	p := &Policy{
+		name:     name,
+		config:   config,
+		bulkhead: newBulkhead(name, config.MaxConcurrent, config.BulkheadMaxWait),
+	}
+	p.breaker = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: config.BreakerHalfOpenRequests,
+		Interval:    config.BreakerInterval,
+		Timeout:     config.BreakerOpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if config.BreakerConsecutiveFailures > 0 && counts.ConsecutiveFailures >= config.BreakerConsecutiveFailures {
+				return true
+			}
+			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
+			return counts.Requests >= config.BreakerMinRequests && failureRatio >= config.BreakerFailureRatio
+		},
+		IsSuccessful: healthy,
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+			slog.Warn("Circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+		},
+	})
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+	register(p)
+	return p
+}
+
+// Name returns the policy's name
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// Execute runs fn under the policy. It waits for a bulkhead slot, then makes
+// up to 1+MaxRetries attempts through the circuit breaker, each bounded by
+// Timeout. Only errors Retryable accepts are retried. It gives up as soon as
+// ctx is cancelled or its deadline passes, including while waiting between
+// attempts. The error of the last attempt is returned, so callers can still
+// inspect it with errors.As.
+func (p *Policy) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := p.bulkhead.acquire(ctx)
+	if err != nil {
+		if errors.Is(err, ErrBulkheadFull) {
+			metrics.ResilienceRejections.WithLabelValues(p.name, "bulkhead_full").Inc()
+			metrics.ResilienceCalls.WithLabelValues(p.name, "rejected").Inc()
+		}
+		return fmt.Errorf("%s: %w", p.name, err)
+	}
+	defer release()
+
+	err = p.retry(ctx, fn)
+	switch {
+	case err == nil:
+		metrics.ResilienceCalls.WithLabelValues(p.name, "success").Inc()
+	case errors.Is(err, ErrCircuitOpen):
+		metrics.ResilienceCalls.WithLabelValues(p.name, "rejected").Inc()
+	default:
+		metrics.ResilienceCalls.WithLabelValues(p.name, "failure").Inc()
+	}
+	return err
+}
+
+// retry makes the attempts of one call
+func (p *Policy) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return abortedError(err, lastErr)
+		}
+
+		_, err := p.breaker.Execute(func() (interface{}, error) {
+			attemptCtx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+			defer cancel()
+			err := fn(attemptCtx)
+			if err != nil && ctx.Err() != nil {
+				// The caller gave up; that says nothing about the dependency
+				return nil, abortedError(ctx.Err(), err)
+			}
+			return nil, err
+		})
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			metrics.ResilienceRejections.WithLabelValues(p.name, "circuit_open").Inc()
+			if lastErr != nil {
+				return fmt.Errorf("%s: %w (last error: %v)", p.name, ErrCircuitOpen, lastErr)
+			}
+			return fmt.Errorf("%s: %w", p.name, ErrCircuitOpen)
+		}
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+		if attempt >= p.config.MaxRetries || !Retryable(err) {
+			return err
+		}
+
+		metrics.ResilienceRetries.WithLabelValues(p.name).Inc()
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return abortedError(ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before retry number attempt+1: RetryBaseDelay
+// doubled for each earlier retry, capped at RetryMaxDelay, with RetryJitter
+// of it randomized so callers that failed together don't retry together
+func (p *Policy) backoff(attempt int) time.Duration {
+	delay := p.config.RetryMaxDelay
+	if attempt < 30 {
+		if d := p.config.RetryBaseDelay << attempt; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	jitter := time.Duration(p.config.RetryJitter * rand.Float64() * float64(delay))
+	return delay - jitter
+}
+
+// Retryable reports whether a failed attempt is worth repeating. Rejected
+// requests (4xx problems other than 408 and 429) would fail the same way
+// again, and a cancelled call has no one waiting for it; everything else,
+// such as connection errors, attempt timeouts and 5xx responses, is retried.
+func Retryable(err error) bool {
+	var p *problem.Problem
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &p):
+		return p.Status >= 500 || p.Status == http.StatusRequestTimeout || p.Status == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// healthy reports whether an attempt's outcome counts as a success for the
+// circuit breaker. A caller giving up or a rejected request, such as a
+// declined card, says nothing about the health of the service.
+func healthy(err error) bool {
+	var p *problem.Problem
+	return err == nil || isAborted(err) || errors.Is(err, context.Canceled) ||
+		errors.As(err, &p) && p.Status < 500 && p.Status != http.StatusTooManyRequests
+}
+
+// aborted marks an error caused by the caller's context ending
+type aborted struct {
+	err error
+}
+
+func (a *aborted) Error() string { return a.err.Error() }
+func (a *aborted) Unwrap() error { return a.err }
+
+// isAborted reports whether err came from the caller's context ending
+func isAborted(err error) bool {
+	var a *aborted
+	return errors.As(err, &a)
+}
+
+// abortedError reports a call stopped by its context, keeping the context
+// error matchable with errors.Is
+func abortedError(ctxErr, lastErr error) error {
+	if lastErr == nil {
+		return &aborted{err: ctxErr}
+	}
+	return &aborted{err: fmt.Errorf("retries aborted: %w (last error: %v)", ctxErr, lastErr)}
+}
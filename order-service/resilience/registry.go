@@ -0,0 +1,80 @@
+package resilience
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Policy{}
+)
+
+// register makes p visible in Status
+func register(p *Policy) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[p.name] = p
+}
+
+// PolicyStatus is the state of a policy as reported by GET /debug/resilience
+type PolicyStatus struct {
+	Name     string        `json:"name"`
+	State    string        `json:"state"`
+	Counts   BreakerCounts `json:"counts"`
+	InFlight int           `json:"in_flight"`
+	Config   Config        `json:"config"`
+}
+
+// BreakerCounts are the circuit breaker's counts for its current interval
+type BreakerCounts struct {
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"total_successes"`
+	TotalFailures        uint32 `json:"total_failures"`
+	ConsecutiveSuccesses uint32 `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32 `json:"consecutive_failures"`
+}
+
+// Status returns the state of a policy
+func (p *Policy) Status() PolicyStatus {
+	counts := p.breaker.Counts()
+	return PolicyStatus{
+		Name:  p.name,
+		State: p.breaker.State().String(),
+		Counts: BreakerCounts{
+			Requests:             counts.Requests,
+			TotalSuccesses:       counts.TotalSuccesses,
+			TotalFailures:        counts.TotalFailures,
+			ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+			ConsecutiveFailures:  counts.ConsecutiveFailures,
+		},
+		InFlight: p.bulkhead.inFlight(),
+		Config:   p.config,
+	}
+}
+
+// Status returns the state of every registered policy, sorted by name
+func Status() []PolicyStatus {
+	registryMu.Lock()
+	policies := make([]*Policy, 0, len(registry))
+	for _, p := range registry {
+		policies = append(policies, p)
+	}
+	registryMu.Unlock()
+
+	statuses := make([]PolicyStatus, len(policies))
+	for i, p := range policies {
+		statuses[i] = p.Status()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Handler serves GET /debug/resilience: every policy's breaker state and
+// counts, calls in flight and settings
+func Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"policies": Status()})
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/resilience"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+)
+
+// Spec documents every route the order service serves
+func Spec() *openapi.Document {
+	withPayment := struct {
+		model.Order
+		Currency string `json:"currency" binding:"required,currency"`
+	}{}
+	withPaymentResult := struct {
+		Order   model.Order                   `json:"order"`
+		Payment *paymentmodel.PaymentResponse `json:"payment"`
+	}{}
+	batchResult := struct {
+		TotalOrders  int `json:"total_orders"`
+		Successful   int `json:"successful"`
+		Failed       int `json:"failed"`
+		FailedOrders []struct {
+			OrderID int          `json:"order_id"`
+			Code    problem.Code `json:"code"`
+			Detail  string       `json:"detail"`
+		} `json:"failed_orders"`
+		ProcessingTime time.Duration `json:"processing_time"`
+	}{}
+	statusUpdate := struct {
+		Status string `json:"status" binding:"required,order_status"`
+	}{}
+	statusResult := struct {
+		Message string `json:"message"`
+		OrderID int    `json:"order_id"`
+		Status  string `json:"status"`
+	}{}
+	policies := struct {
+		Policies []resilience.PolicyStatus `json:"policies"`
+	}{}
+	stock := []int{http.StatusBadRequest, http.StatusConflict, http.StatusServiceUnavailable}
+	staff := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict}
+
+	return openapi.New("Order Service API", "1.0", "Orders, from placement with a stock check and payment through to delivery.").
+		Tag("orders", "Orders and their status").
+		Tag("returns", "Returns of delivered orders, approved, received and refunded by staff").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "POST", Path: "/orders", ID: "createOrder", Summary: "Place an order", Tag: "orders",
+				Body: model.Order{}, Status: http.StatusCreated, Response: model.Order{}, Errors: stock},
+			openapi.Route{Method: "POST", Path: "/orders/with-payment", ID: "createOrderWithPayment", Summary: "Place an order and create its payment intent", Tag: "orders",
+				Body: withPayment, Status: http.StatusCreated, Response: withPaymentResult,
+				Errors: append(stock, http.StatusPaymentRequired, http.StatusBadGateway)},
+			openapi.Route{Method: "POST", Path: "/orders/batch", ID: "createOrderBatch", Summary: "Process a batch of orders in parallel", Tag: "orders",
+				Body: []model.Order{}, Response: batchResult, Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/orders", ID: "listOrders", Summary: "List orders", Tag: "orders",
+				Params: openapi.ListParams(controller.OrderListParams()), Response: []model.Order{}, Headers: openapi.ListHeaders,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/orders/:id", ID: "getOrder", Summary: "Get an order", Tag: "orders",
+				Response: model.Order{}, Errors: []int{http.StatusNotFound}},
+			openapi.Route{Method: "PUT", Path: "/orders/:id", ID: "updateOrder", Summary: "Replace an order", Tag: "orders",
+				Body: model.Order{}, Response: model.Order{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "DELETE", Path: "/orders/:id", ID: "deleteOrder", Summary: "Cancel an order at the customer's request", Tag: "orders",
+				Response: controller.Cancellation{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "POST", Path: "/orders/:id/cancel", ID: "cancelOrder", Summary: "Cancel an order, release its stock and void or refund its payments", Tag: "orders",
+				Body: model.CancelRequest{}, Response: controller.Cancellation{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "PATCH", Path: "/orders/:id/status", ID: "updateOrderStatus", Summary: "Change an order's status and notify the customer", Tag: "orders",
+				Body: statusUpdate, Response: statusResult, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "POST", Path: "/orders/:id/returns", ID: "requestReturn", Summary: "Ask to return some or all of a delivered order's units", Tag: "returns",
+				Body: model.ReturnRequest{}, Status: http.StatusCreated, Response: model.Return{},
+				Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "GET", Path: "/orders/:id/returns", ID: "listReturns", Summary: "List an order's returns", Tag: "returns",
+				Response: []model.Return{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+			openapi.Route{Method: "POST", Path: "/admin/orders/:id/returns/:returnId/approve", ID: "approveReturn", Summary: "Approve a requested return", Tag: "returns",
+				Params: []openapi.Parameter{admin.Param()}, Body: model.ReturnApproval{}, Response: model.Return{}, Errors: staff},
+			openapi.Route{Method: "POST", Path: "/admin/orders/:id/returns/:returnId/reject", ID: "rejectReturn", Summary: "Reject a requested return", Tag: "returns",
+				Params: []openapi.Parameter{admin.Param()}, Body: model.ReturnRejection{}, Response: model.Return{}, Errors: staff},
+			openapi.Route{Method: "POST", Path: "/admin/orders/:id/returns/:returnId/receive", ID: "receiveReturn", Summary: "Record a return's arrival, restock its units and refund them", Tag: "returns",
+				Params: []openapi.Parameter{admin.Param()}, Response: model.Return{},
+				Errors: append(staff, http.StatusBadGateway, http.StatusServiceUnavailable)},
+			openapi.Route{Method: "DELETE", Path: "/admin/orders/:id", ID: "purgeOrder", Summary: "Delete a cancelled order for good", Tag: "operations",
+				Params: []openapi.Parameter{admin.Param()}, Response: openapi.Message{},
+				Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict}},
+			openapi.Route{Method: "GET", Path: "/debug/resilience", ID: "getResilience", Summary: "State of the resilience policies guarding calls to other services", Tag: "operations",
+				Response: policies},
+			openapi.Metrics,
+		).
+		Add(health.Routes()...).
+		Add(logging.AdminRoutes()...)
+}
@@ -2,12 +2,14 @@ package routes
 
 import (
 	"go-microservices/order-service/controller"
+	"go-microservices/pkg/admin"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures the API routes for the order service
-func SetupRoutes(router *gin.Engine, orderController *controller.OrderController) {
+// SetupRoutes configures the API routes for the order service. Admin routes
+// require adminToken and are closed while it is empty, see admin.Authorize.
+func SetupRoutes(router *gin.Engine, orderController *controller.OrderController, adminToken string) {
 	// Order routes
 	router.POST("/orders", orderController.CreateOrder)
 	router.POST("/orders/with-payment", orderController.CreateOrderWithPayment)
@@ -17,4 +19,17 @@ func SetupRoutes(router *gin.Engine, orderController *controller.OrderController
 	router.PUT("/orders/:id", orderController.UpdateOrder)
 	router.DELETE("/orders/:id", orderController.DeleteOrder)
 	router.PATCH("/orders/:id/status", orderController.UpdateOrderStatus)
+	router.POST("/orders/:id/cancel", orderController.CancelOrder)
+
+	// Returns of delivered orders; staff decide on them and record their receipt
+	router.POST("/orders/:id/returns", orderController.RequestReturn)
+	router.GET("/orders/:id/returns", orderController.GetReturns)
+
+	// Hard deletion of cancelled orders, for erasure requests
+	router.DELETE("/admin/orders/:id", admin.Authorize(adminToken), orderController.PurgeOrder)
+
+	staff := router.Group("/admin/orders/:id/returns/:returnId", admin.Authorize(adminToken))
+	staff.POST("/approve", orderController.ApproveReturn)
+	staff.POST("/reject", orderController.RejectReturn)
+	staff.POST("/receive", orderController.ReceiveReturn)
 }
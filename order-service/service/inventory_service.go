@@ -1,121 +1,88 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"time"
+	"context"
+	"errors"
 
-	"go-microservices/order-service/model"
+	inventoryclient "go-microservices/inventory-service/client"
+	inventorymodel "go-microservices/inventory-service/model"
 	"go-microservices/order-service/resilience"
-
-	"github.com/sony/gobreaker"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/servicetoken"
 )
 
-// InventoryService is a client for the inventory service
+// InventoryService calls the inventory service through its client
 type InventoryService struct {
-	BaseURL    string
-	HTTPClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	client *inventoryclient.Client
+	policy *resilience.Policy
 }
 
-// NewInventoryService creates a new inventory service client
-func NewInventoryService() *InventoryService {
-	baseURL := os.Getenv("INVENTORY_SERVICE_URL")
-	if baseURL == "" {
-		baseURL = "http://inventory-service:8082" // Docker default
-	}
-
-	// Create circuit breaker
-	cbConfig := resilience.DefaultConfig("inventory-service")
-	cb := resilience.NewCircuitBreaker(cbConfig)
-
+// NewInventoryService creates a new inventory service client whose calls are
+// guarded by policy and send the service token
+func NewInventoryService(baseURL, serviceToken string, policy *resilience.Policy) *InventoryService {
 	return &InventoryService{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
-		cb: cb,
+		client: inventoryclient.New(baseURL, servicetoken.HTTPClient(serviceToken)),
+		policy: policy,
 	}
 }
 
-// CheckInventory checks if a product is available in inventory
-func (is *InventoryService) CheckInventory(productID int, quantity int) (*model.InventoryResponse, error) {
-	data := model.InventoryCheck{
-		ProductID: productID,
-		Quantity:  quantity,
-	}
-
-	jsonData, err := json.Marshal(data)
+// CheckAvailability reports whether quantity units of a product are in stock
+func (s *InventoryService) CheckAvailability(ctx context.Context, productID int, quantity int) (bool, error) {
+	var response *inventorymodel.InventoryResponse
+	err := s.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = s.client.Check(ctx, inventorymodel.InventoryCheck{
+			ProductID: productID,
+			Quantity:  quantity,
+		})
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal inventory check: %w", err)
+		return false, err
 	}
 
-	// Use circuit breaker with retry
-	result, err := resilience.ExecuteWithRetry(is.cb, func() (interface{}, error) {
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s/inventory/check", is.BaseURL), bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := is.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("inventory service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("inventory service returned non-OK status: %d", resp.StatusCode)
-		}
-
-		var inventoryResponse model.InventoryResponse
-		if err := json.NewDecoder(resp.Body).Decode(&inventoryResponse); err != nil {
-			return nil, fmt.Errorf("failed to decode inventory response: %w", err)
-		}
-
-		return &inventoryResponse, nil
-	}, 3) // Maximum 3 retries
+	return response.Available, nil
+}
 
-	if err != nil {
-		return nil, err
+// ReserveStock takes quantity units of a product out of stock for an order.
+// Repeating it for the same order reserves nothing more.
+func (s *InventoryService) ReserveStock(ctx context.Context, orderID int, productID int, quantity int) error {
+	req := inventorymodel.ReservationRequest{
+		OrderID:   orderID,
+		ProductID: productID,
+		Quantity:  quantity,
 	}
-
-	return result.(*model.InventoryResponse), nil
+	return s.policy.Execute(ctx, func(ctx context.Context) error {
+		_, err := s.client.Reserve(ctx, req)
+		return err
+	})
 }
 
-func (s *InventoryService) CheckAvailability(productID int, quantity int) (bool, error) {
-	url := fmt.Sprintf("%s/check/%d?quantity=%d",
-		s.BaseURL,
-		productID,
-		quantity)
-
-	result, err := s.cb.Execute(func() (interface{}, error) {
-		resp, err := s.HTTPClient.Get(url)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
-		}
-
-		var response struct {
-			Available bool `json:"available"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			return nil, err
-		}
-
-		return response.Available, nil
+// ReleaseStock puts the stock an order reserved back. An order that reserved
+// nothing, such as one placed before reservations existed, has nothing to release.
+func (s *InventoryService) ReleaseStock(ctx context.Context, orderID int) error {
+	err := s.policy.Execute(ctx, func(ctx context.Context) error {
+		_, err := s.client.Release(ctx, orderID)
+		return err
 	})
-
-	if err != nil {
-		return false, err
+	var p *problem.Problem
+	if errors.As(err, &p) && p.Code == problem.CodeReservationNotFound {
+		return nil
 	}
+	return err
+}
 
-	return result.(bool), nil
+// RestockReturn puts the units of an order return back into stock. Repeating
+// it for the same return restocks nothing more.
+func (s *InventoryService) RestockReturn(ctx context.Context, returnID, orderID, productID, quantity int) error {
+	req := inventorymodel.RestockRequest{
+		ReturnID:  returnID,
+		OrderID:   orderID,
+		ProductID: productID,
+		Quantity:  quantity,
+	}
+	return s.policy.Execute(ctx, func(ctx context.Context) error {
+		_, err := s.client.Restock(ctx, req)
+		return err
+	})
 }
@@ -1,119 +1,44 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"time"
+	"context"
 
-	"go-microservices/order-service/model"
+	notificationclient "go-microservices/notification-service/client"
+	notificationmodel "go-microservices/notification-service/model"
 	"go-microservices/order-service/resilience"
-
-	"github.com/sony/gobreaker"
 )
 
-// NotificationService is a client for the notification service
+// OrderCreatedStatus is the status the customer is notified of when an order is placed
+const OrderCreatedStatus = "created"
+
+// NotificationService calls the notification service through its client
 type NotificationService struct {
-	BaseURL    string
-	HTTPClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	client *notificationclient.Client
+	policy *resilience.Policy
 }
 
-// NewNotificationService creates a new notification service client
-func NewNotificationService() *NotificationService {
-	baseURL := os.Getenv("NOTIFICATION_SERVICE_URL")
-	if baseURL == "" {
-		baseURL = "http://notification-service:8083" // Docker default
-	}
-
-	// Create circuit breaker
-	cbConfig := resilience.DefaultConfig("notification-service")
-	cb := resilience.NewCircuitBreaker(cbConfig)
-
+// NewNotificationService creates a new notification service client whose calls are guarded by policy
+func NewNotificationService(baseURL string, policy *resilience.Policy) *NotificationService {
 	return &NotificationService{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
-		cb: cb,
+		client: notificationclient.New(baseURL, nil),
+		policy: policy,
 	}
 }
 
-// SendOrderNotification sends an order notification to the notification service
-func (ns *NotificationService) SendOrderNotification(orderID int) error {
-	url := fmt.Sprintf("%s/notify/order/%d", ns.BaseURL, orderID)
-
-	notification := struct {
-		OrderID int    `json:"order_id"`
-		Type    string `json:"type"`
-	}{
-		OrderID: orderID,
-		Type:    "order_created",
-	}
-
-	body, err := json.Marshal(notification)
-	if err != nil {
-		return err
-	}
-
-	_, err = ns.cb.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ns.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("notification service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("notification service returned status: %d", resp.StatusCode)
-		}
-
-		return nil, nil
-	})
-
-	return err
+// SendOrderNotification tells the customer that their order was placed
+func (ns *NotificationService) SendOrderNotification(ctx context.Context, orderID int, customerID int) error {
+	return ns.SendOrderStatusUpdate(ctx, orderID, customerID, OrderCreatedStatus)
 }
 
-// SendOrderStatusUpdate sends an order status update to the notification service
-func (ns *NotificationService) SendOrderStatusUpdate(orderID int, customerID int, status string) error {
-	data := model.OrderStatusUpdate{
+// SendOrderStatusUpdate tells the customer that their order has reached status
+func (ns *NotificationService) SendOrderStatusUpdate(ctx context.Context, orderID int, customerID int, status string) error {
+	update := notificationmodel.OrderStatusUpdate{
 		OrderID:    orderID,
 		CustomerID: customerID,
 		Status:     status,
 	}
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal order status update: %w", err)
-	}
-
-	// Use circuit breaker with retry
-	_, err = resilience.ExecuteWithRetry(ns.cb, func() (interface{}, error) {
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s/notify/status", ns.BaseURL), bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ns.HTTPClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("notification service request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("notification service returned status: %d", resp.StatusCode)
-		}
-
-		return nil, nil
-	}, 3) // Maximum 3 retries
-
-	return err
+	return ns.policy.Execute(ctx, func(ctx context.Context) error {
+		_, err := ns.client.OrderStatus(ctx, update)
+		return err
+	})
 }
@@ -1,173 +1,102 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
 
-	"github.com/sony/gobreaker"
+	"go-microservices/order-service/resilience"
+	paymentclient "go-microservices/payment-service/client"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/servicetoken"
 )
 
-// PaymentService handles payment-related operations
+// PaymentService calls the payment service through its client
 type PaymentService struct {
-	baseURL       string
-	client        *http.Client
-	circuitBreaker *gobreaker.CircuitBreaker
+	client *paymentclient.Client
+	policy *resilience.Policy
 }
 
-// PaymentRequest represents a payment creation request
-type PaymentRequest struct {
-	OrderID    int     `json:"order_id"`
-	CustomerID int     `json:"customer_id"`
-	Amount     float64 `json:"amount"`
-	Currency   string  `json:"currency"`
-}
-
-// PaymentResponse represents a payment response
-type PaymentResponse struct {
-	Payment struct {
-		ID                int     `json:"id"`
-		OrderID           int     `json:"order_id"`
-		CustomerID        int     `json:"customer_id"`
-		Amount            float64 `json:"amount"`
-		Currency          string  `json:"currency"`
-		Status            string  `json:"status"`
-		StripePaymentID   string  `json:"stripe_payment_id"`
-		PaymentMethod     string  `json:"payment_method"`
-		CreatedAt         string  `json:"created_at"`
-		UpdatedAt         string  `json:"updated_at"`
-	} `json:"payment"`
-	ClientSecret string `json:"client_secret,omitempty"`
-	Message      string `json:"message,omitempty"`
-}
-
-// NewPaymentService creates a new payment service instance
-func NewPaymentService() *PaymentService {
-	baseURL := getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
-	
-	// Circuit breaker settings
-	settings := gobreaker.Settings{
-		Name:        "PaymentService",
-		MaxRequests: 3,
-		Interval:    time.Second * 10,
-		Timeout:     time.Second * 30,
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			return counts.ConsecutiveFailures > 2
-		},
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			fmt.Printf("CircuitBreaker '%s' changed from '%s' to '%s'\n", name, from, to)
-		},
-	}
-
+// NewPaymentService creates a new payment service client whose calls are
+// guarded by policy and send the service token. Creating a payment is not
+// idempotent, so the policy should not retry.
+func NewPaymentService(baseURL, serviceToken string, policy *resilience.Policy) *PaymentService {
 	return &PaymentService{
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		circuitBreaker: gobreaker.NewCircuitBreaker(settings),
+		client: paymentclient.New(baseURL, servicetoken.HTTPClient(serviceToken)),
+		policy: policy,
 	}
 }
 
 // CreatePayment creates a payment intent for an order
-func (ps *PaymentService) CreatePayment(orderID, customerID int, amount float64, currency string) (*PaymentResponse, error) {
-	paymentReq := PaymentRequest{
+func (ps *PaymentService) CreatePayment(ctx context.Context, orderID, customerID int, amount float64, currency string) (*paymentmodel.PaymentResponse, error) {
+	req := paymentmodel.PaymentRequest{
 		OrderID:    orderID,
 		CustomerID: customerID,
 		Amount:     amount,
 		Currency:   currency,
 	}
 
-	jsonData, err := json.Marshal(paymentReq)
+	var resp *paymentmodel.PaymentResponse
+	err := ps.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = ps.client.Create(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payment request: %w", err)
+		return nil, fmt.Errorf("payment service: %w", err)
 	}
 
-	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("POST", ps.baseURL+"/payments", bytes.NewBuffer(jsonData))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := ps.client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
-		}
-
-		var paymentResp PaymentResponse
-		if err := json.NewDecoder(resp.Body).Decode(&paymentResp); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
+	return resp, nil
+}
 
-		return &paymentResp, nil
+// GetPaymentsByOrder retrieves payments for a specific order
+func (ps *PaymentService) GetPaymentsByOrder(ctx context.Context, orderID int) ([]paymentmodel.Payment, error) {
+	var payments []paymentmodel.Payment
+	err := ps.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		payments, err = ps.client.ListByOrder(ctx, orderID)
+		return err
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
-	}
-
-	paymentResp, ok := result.(*PaymentResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type from payment service")
+		return nil, fmt.Errorf("payment service: %w", err)
 	}
 
-	return paymentResp, nil
+	return payments, nil
 }
 
-// GetPaymentsByOrder retrieves payments for a specific order
-func (ps *PaymentService) GetPaymentsByOrder(orderID int) ([]PaymentResponse, error) {
-	url := fmt.Sprintf("%s/payments/order/%d", ps.baseURL, orderID)
-
-	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := ps.client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
-		}
-
-		var payments []PaymentResponse
-		if err := json.NewDecoder(resp.Body).Decode(&payments); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
-
-		return payments, nil
+// CancelOrderPayments voids an order's unpaid payment intents and refunds its
+// paid ones. The payment service skips settled payments, so it can be repeated.
+func (ps *PaymentService) CancelOrderPayments(ctx context.Context, orderID int, reason string) ([]paymentmodel.Payment, error) {
+	var payments []paymentmodel.Payment
+	err := ps.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		payments, err = ps.client.CancelOrder(ctx, orderID, reason)
+		return err
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
-	}
-
-	payments, ok := result.([]PaymentResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type from payment service")
+		return nil, fmt.Errorf("payment service: %w", err)
 	}
 
 	return payments, nil
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// RefundOrder refunds amount of an order's payments. The payment service
+// refunds each reference once, so it can be repeated.
+func (ps *PaymentService) RefundOrder(ctx context.Context, orderID int, amount float64, reference string) (*paymentmodel.Refund, error) {
+	req := paymentmodel.RefundRequest{
+		Amount:    amount,
+		Reference: reference,
+		Reason:    "requested_by_customer",
+	}
+
+	var refund *paymentmodel.Refund
+	err := ps.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		refund, err = ps.client.Refund(ctx, orderID, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payment service: %w", err)
 	}
-	return value
-}
\ No newline at end of file
+
+	return refund, nil
+}
@@ -0,0 +1,171 @@
+package contract
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	inventoryclient "go-microservices/inventory-service/client"
+	"go-microservices/inventory-service/controller"
+	"go-microservices/inventory-service/routes"
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceToken is the token the providers require and the consumers send
+const serviceToken = "contract-service-token"
+
+// inventoryProvider serves the inventory service's routes over db
+func inventoryProvider(t *testing.T) (*httptest.Server, *stubDB) {
+	gin.SetMode(gin.TestMode)
+	db, stub := newStubDB(t)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewInventoryController(db), serviceToken)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, stub
+}
+
+func TestInventoryContract_CheckAvailability(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("SELECT quantity FROM inventory", []string{"quantity"}, []driver.Value{int64(5)})
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	available, err := inventory.CheckAvailability(context.Background(), 7, 5)
+	require.NoError(t, err)
+	assert.True(t, available)
+	assert.Equal(t, []driver.Value{int64(7)}, stub.args(t, "SELECT quantity FROM inventory"))
+
+	available, err = inventory.CheckAvailability(context.Background(), 7, 6)
+	require.NoError(t, err)
+	assert.False(t, available)
+}
+
+func TestInventoryContract_CheckUnstockedProduct(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("SELECT quantity FROM inventory", []string{"quantity"})
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	available, err := inventory.CheckAvailability(context.Background(), 7, 1)
+	require.NoError(t, err)
+	assert.False(t, available)
+}
+
+func TestInventoryContract_Get(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("FROM inventory WHERE id", []string{"id", "product_id", "quantity", "sku", "location"},
+		[]driver.Value{int64(3), int64(7), int64(5), "SKU-7", "A1"})
+	client := inventoryclient.New(server.URL, nil)
+
+	item, err := client.Get(context.Background(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, item.ID)
+	assert.Equal(t, 7, item.ProductID)
+	assert.Equal(t, 5, item.Quantity)
+	assert.Equal(t, "SKU-7", item.SKU)
+	assert.Equal(t, "A1", item.Location)
+
+	stub.on("FROM inventory WHERE id", []string{"id", "product_id", "quantity", "sku", "location"})
+	_, err = client.Get(context.Background(), 4)
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, problem.CodeInventoryNotFound, p.Code)
+}
+
+// reservationRow is an inventory_reservations row for order 42
+func reservationRow(releasedAt interface{}) []driver.Value {
+	return []driver.Value{int64(42), int64(7), int64(3), int64(2), time.Now(), releasedAt}
+}
+
+var reservationColumns = []string{"order_id", "product_id", "inventory_id", "quantity", "created_at", "released_at"}
+
+func TestInventoryContract_ReserveStock(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("FROM inventory_reservations WHERE order_id", reservationColumns)
+	stub.on("SELECT id FROM inventory", []string{"id"}, []driver.Value{int64(3)})
+	stub.on("UPDATE inventory SET", nil, []driver.Value{})
+	stub.on("INSERT INTO inventory_reservations", reservationColumns, reservationRow(nil))
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	require.NoError(t, inventory.ReserveStock(context.Background(), 42, 7, 2))
+	assert.Equal(t, []driver.Value{int64(7), int64(2)}, stub.args(t, "SELECT id FROM inventory"))
+	assert.Equal(t, []driver.Value{int64(2), int64(3)}, stub.args(t, "UPDATE inventory SET"))
+
+	// Reserving again for the same order returns its reservation
+	stub.on("FROM inventory_reservations WHERE order_id", reservationColumns, reservationRow(nil))
+	require.NoError(t, inventory.ReserveStock(context.Background(), 42, 7, 2))
+}
+
+func TestInventoryContract_ReserveUnavailableStock(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("FROM inventory_reservations WHERE order_id", reservationColumns)
+	stub.on("SELECT id FROM inventory", []string{"id"})
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	err := inventory.ReserveStock(context.Background(), 42, 7, 2)
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, problem.CodeInsufficientStock, p.Code)
+}
+
+func TestInventoryContract_ReleaseStock(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("UPDATE inventory_reservations", reservationColumns, reservationRow(time.Now()))
+	stub.on("UPDATE inventory SET", nil, []driver.Value{})
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	require.NoError(t, inventory.ReleaseStock(context.Background(), 42))
+	assert.Equal(t, []driver.Value{int64(2), int64(3)}, stub.args(t, "UPDATE inventory SET"))
+
+	// An order that reserved nothing has nothing to release
+	stub.on("UPDATE inventory_reservations", reservationColumns)
+	stub.on("FROM inventory_reservations WHERE order_id", reservationColumns)
+	require.NoError(t, inventory.ReleaseStock(context.Background(), 43))
+}
+
+func TestInventoryContract_RequiresServiceToken(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	inventory := service.NewInventoryService(server.URL, "guess", resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	var p *problem.Problem
+	require.ErrorAs(t, inventory.ReserveStock(context.Background(), 42, 7, 2), &p)
+	assert.Equal(t, problem.CodeUnauthorized, p.Code)
+	require.ErrorAs(t, inventory.ReleaseStock(context.Background(), 42), &p)
+	assert.Equal(t, problem.CodeUnauthorized, p.Code)
+	require.ErrorAs(t, inventory.RestockReturn(context.Background(), 12, 42, 7, 2), &p)
+	assert.Equal(t, problem.CodeUnauthorized, p.Code)
+	assert.Empty(t, stub.calls, "rejected before reaching the database")
+}
+
+var restockColumns = []string{"return_id", "order_id", "product_id", "inventory_id", "quantity", "created_at"}
+
+func TestInventoryContract_RestockReturn(t *testing.T) {
+	server, stub := inventoryProvider(t)
+	stub.on("FROM inventory_restocks WHERE return_id", restockColumns)
+	stub.on("FROM inventory i", []string{"id"}, []driver.Value{int64(3)})
+	stub.on("UPDATE inventory SET", nil, []driver.Value{})
+	stub.on("INSERT INTO inventory_restocks", restockColumns,
+		[]driver.Value{int64(12), int64(42), int64(7), int64(3), int64(2), time.Now()})
+	inventory := service.NewInventoryService(server.URL, serviceToken, resilience.New("inventory-service", resilience.DefaultConfig()))
+
+	require.NoError(t, inventory.RestockReturn(context.Background(), 12, 42, 7, 2))
+	assert.Equal(t, []driver.Value{int64(7), int64(42)}, stub.args(t, "FROM inventory i"))
+	assert.Equal(t, []driver.Value{int64(2), int64(3)}, stub.args(t, "UPDATE inventory SET"))
+
+	// A product without an inventory item can't be restocked
+	stub.on("FROM inventory i", []string{"id"})
+	err := inventory.RestockReturn(context.Background(), 13, 42, 7, 2)
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, problem.CodeInventoryNotFound, p.Code)
+}
@@ -0,0 +1,75 @@
+package contract
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	notificationclient "go-microservices/notification-service/client"
+	"go-microservices/notification-service/controller"
+	"go-microservices/notification-service/model"
+	"go-microservices/notification-service/routes"
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notificationProvider serves the notification service's routes over db
+func notificationProvider(t *testing.T) (*httptest.Server, *stubDB) {
+	gin.SetMode(gin.TestMode)
+	db, stub := newStubDB(t)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewNotificationController(db))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, stub
+}
+
+func TestNotificationContract_OrderNotifications(t *testing.T) {
+	server, stub := notificationProvider(t)
+	stub.on("INSERT INTO notifications", []string{"id"}, []driver.Value{int64(11)})
+	notifications := service.NewNotificationService(server.URL, resilience.New("notification-service", resilience.DefaultConfig()))
+
+	require.NoError(t, notifications.SendOrderNotification(context.Background(), 7, 3))
+	args := stub.args(t, "INSERT INTO notifications")
+	assert.Equal(t, int64(7), args[0])
+	assert.Equal(t, int64(3), args[1])
+	assert.Equal(t, service.OrderCreatedStatus, args[3])
+
+	require.NoError(t, notifications.SendOrderStatusUpdate(context.Background(), 7, 3, "shipped"))
+	args = stub.args(t, "INSERT INTO notifications")
+	assert.Equal(t, "Your order #7 status has changed to: shipped", args[2])
+	assert.Equal(t, "shipped", args[3])
+}
+
+func TestNotificationContract_OrderStatusResult(t *testing.T) {
+	server, stub := notificationProvider(t)
+	stub.on("INSERT INTO notifications", []string{"id"}, []driver.Value{int64(11)})
+	client := notificationclient.New(server.URL, nil)
+
+	result, err := client.OrderStatus(context.Background(), model.OrderStatusUpdate{OrderID: 7, CustomerID: 3, Status: "shipped"})
+	require.NoError(t, err)
+	assert.Equal(t, 11, result.NotificationID)
+	assert.NotEmpty(t, result.Message)
+}
+
+func TestNotificationContract_Get(t *testing.T) {
+	server, stub := notificationProvider(t)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stub.on("FROM notifications WHERE id", []string{"id", "order_id", "customer_id", "message", "status", "created_at", "delivered_at"},
+		[]driver.Value{int64(11), int64(7), int64(3), "Your order #7 status has changed to: shipped", "shipped", created, nil})
+	client := notificationclient.New(server.URL, nil)
+
+	notification, err := client.Get(context.Background(), 11)
+	require.NoError(t, err)
+	assert.Equal(t, 11, notification.ID)
+	assert.Equal(t, 7, notification.OrderID)
+	assert.Equal(t, 3, notification.CustomerID)
+	assert.Equal(t, "shipped", notification.Status)
+	assert.True(t, created.Equal(notification.CreatedAt))
+}
@@ -0,0 +1,183 @@
+package contract
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/service"
+	paymentclient "go-microservices/payment-service/client"
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/routes"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v76"
+)
+
+// paymentColumns are the columns the payment service selects for a payment
+var paymentColumns = []string{"id", "order_id", "customer_id", "amount", "currency", "status",
+	"stripe_payment_id", "payment_method", "created_at", "updated_at"}
+
+// stripeCall is the last request the fake Stripe received
+type stripeCall struct {
+	path string
+	form url.Values
+}
+
+// paymentProvider serves the payment service's routes over db, with Stripe
+// replaced by a fake that creates every payment intent it is asked for
+func paymentProvider(t *testing.T) (*httptest.Server, *stubDB, *stripeCall) {
+	gin.SetMode(gin.TestMode)
+	db, stub := newStubDB(t)
+	router := gin.New()
+	routes.SetupRoutes(router, controller.NewPaymentController(db, "sk_test_contract"), serviceToken)
+
+	intents := &stripeCall{}
+	fakeStripe := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		intents.path, intents.form = r.URL.Path, r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"pi_123","object":"payment_intent","client_secret":"pi_123_secret","status":"requires_payment_method"}`))
+	}))
+	t.Cleanup(fakeStripe.Close)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(fakeStripe.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+		LeveledLogger:     &stripe.LeveledLogger{Level: stripe.LevelNull},
+	}))
+	t.Cleanup(func() { stripe.SetBackend(stripe.APIBackend, nil) })
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, stub, intents
+}
+
+func TestPaymentContract_CreatePayment(t *testing.T) {
+	server, stub, intent := paymentProvider(t)
+	stub.on("INSERT INTO payments", []string{"id"}, []driver.Value{int64(21)})
+	payments := service.NewPaymentService(server.URL, serviceToken, resilience.New("payment-service", resilience.DefaultConfig()))
+
+	resp, err := payments.CreatePayment(context.Background(), 7, 3, 19.99, "usd")
+	require.NoError(t, err)
+	assert.Equal(t, 21, resp.Payment.ID)
+	assert.Equal(t, 7, resp.Payment.OrderID)
+	assert.Equal(t, model.PaymentStatusPending, resp.Payment.Status)
+	assert.Equal(t, "pi_123", resp.Payment.StripePaymentID)
+	assert.Equal(t, "pi_123_secret", resp.ClientSecret)
+
+	assert.Equal(t, "/v1/payment_intents", intent.path)
+	assert.Equal(t, "1999", intent.form.Get("amount"))
+	assert.Equal(t, "7", intent.form.Get("metadata[order_id]"))
+}
+
+func TestPaymentContract_CreatePaymentRejected(t *testing.T) {
+	server, _, _ := paymentProvider(t)
+	payments := service.NewPaymentService(server.URL, serviceToken, resilience.New("payment-service", resilience.DefaultConfig()))
+
+	_, err := payments.CreatePayment(context.Background(), 7, 3, 0, "usd")
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+}
+
+func TestPaymentContract_GetAndListByOrder(t *testing.T) {
+	server, stub, _ := paymentProvider(t)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	row := []driver.Value{int64(21), int64(7), int64(3), 19.99, "usd", "succeeded", "pi_123", "card", created, created}
+	stub.on("FROM payments WHERE id", paymentColumns, row)
+	stub.on("FROM payments WHERE order_id", paymentColumns, row)
+	client := paymentclient.New(server.URL, nil)
+
+	payment, err := client.Get(context.Background(), 21)
+	require.NoError(t, err)
+	assert.Equal(t, 21, payment.ID)
+	assert.Equal(t, 19.99, payment.Amount)
+	assert.Equal(t, "card", payment.PaymentMethod)
+
+	payments := service.NewPaymentService(server.URL, serviceToken, resilience.New("payment-service", resilience.DefaultConfig()))
+	list, err := payments.GetPaymentsByOrder(context.Background(), 7)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, 21, list[0].ID)
+	assert.Equal(t, int64(7), stub.args(t, "FROM payments WHERE order_id")[0])
+}
+
+func TestPaymentContract_CancelOrderPayments(t *testing.T) {
+	server, stub, intent := paymentProvider(t)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stub.on("FROM payments WHERE order_id", paymentColumns,
+		[]driver.Value{int64(20), int64(7), int64(3), 19.99, "usd", "refunded", "pi_100", "card", created, created},
+		[]driver.Value{int64(21), int64(7), int64(3), 19.99, "usd", "pending", "pi_123", "", created, created})
+	stub.on("UPDATE payments", nil, []driver.Value{})
+	payments := service.NewPaymentService(server.URL, serviceToken, resilience.New("payment-service", resilience.DefaultConfig()))
+
+	list, err := payments.CancelOrderPayments(context.Background(), 7, "requested_by_customer")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	// The refunded payment is left alone and the unpaid intent is cancelled
+	assert.Equal(t, model.PaymentStatusRefunded, list[0].Status)
+	assert.Equal(t, model.PaymentStatusCanceled, list[1].Status)
+	assert.Equal(t, "/v1/payment_intents/pi_123/cancel", intent.path)
+	assert.Equal(t, "requested_by_customer", intent.form.Get("cancellation_reason"))
+	args := stub.args(t, "UPDATE payments")
+	assert.Equal(t, model.PaymentStatusCanceled, args[0])
+	assert.Equal(t, int64(21), args[2])
+}
+
+func TestPaymentContract_RequiresServiceToken(t *testing.T) {
+	server, stub, intent := paymentProvider(t)
+	payments := service.NewPaymentService(server.URL, "guess", resilience.New("payment-service", resilience.DefaultConfig()))
+
+	_, err := payments.CancelOrderPayments(context.Background(), 7, "requested_by_customer")
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, problem.CodeUnauthorized, p.Code)
+	_, err = payments.RefundOrder(context.Background(), 7, 25, "return-12")
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, problem.CodeUnauthorized, p.Code)
+	assert.Empty(t, stub.calls)
+	assert.Empty(t, intent.path, "Stripe was not called")
+}
+
+func TestPaymentContract_RefundOrder(t *testing.T) {
+	server, stub, call := paymentProvider(t)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	refundColumns := []string{"id", "payment_id", "order_id", "amount", "currency", "reason", "reference", "stripe_refund_id", "created_at"}
+	stub.on("FROM refunds WHERE reference", refundColumns)
+	stub.on("FROM payments WHERE order_id", paymentColumns,
+		[]driver.Value{int64(21), int64(7), int64(3), 100.0, "usd", "succeeded", "pi_123", "card", created, created})
+	stub.on("FROM refunds WHERE payment_id", []string{"sum"}, []driver.Value{60.0})
+	stub.on("INSERT INTO refunds", refundColumns,
+		[]driver.Value{int64(4), int64(21), int64(7), 25.0, "usd", "requested_by_customer", "return-12", "pi_123", created})
+	payments := service.NewPaymentService(server.URL, serviceToken, resilience.New("payment-service", resilience.DefaultConfig()))
+
+	refund, err := payments.RefundOrder(context.Background(), 7, 25, "return-12")
+	require.NoError(t, err)
+	assert.Equal(t, 4, refund.ID)
+	assert.Equal(t, 25.0, refund.Amount)
+	assert.Equal(t, "/v1/refunds", call.path)
+	assert.Equal(t, "2500", call.form.Get("amount"))
+	assert.Equal(t, "pi_123", call.form.Get("payment_intent"))
+	assert.Equal(t, "return-12", stub.args(t, "INSERT INTO refunds")[5])
+
+	// More than is left of the payment is refused
+	_, err = payments.RefundOrder(context.Background(), 7, 50, "return-13")
+	var p *problem.Problem
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, http.StatusConflict, p.Status)
+
+	// An order without a succeeded payment has nothing to refund
+	stub.on("FROM payments WHERE order_id", paymentColumns)
+	_, err = payments.RefundOrder(context.Background(), 7, 25, "return-14")
+	require.ErrorAs(t, err, &p)
+	assert.Equal(t, problem.CodePaymentNotFound, p.Code)
+}
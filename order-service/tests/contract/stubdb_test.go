@@ -0,0 +1,138 @@
+package contract
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubDB is a database/sql driver that answers queries with canned rows, so
+// the providers' real handlers can serve the contract tests without Postgres.
+// A query that matches no rule fails, which the handlers report as a 500.
+type stubDB struct {
+	mu    sync.Mutex
+	rules []rule
+	calls []call
+}
+
+// rule answers every query containing match
+type rule struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+}
+
+// call is a query the stub answered
+type call struct {
+	query string
+	args  []driver.Value
+}
+
+// newStubDB opens a database backed by a new stub
+func newStubDB(t *testing.T) (*sql.DB, *stubDB) {
+	stub := &stubDB{}
+	db := sql.OpenDB(stub)
+	t.Cleanup(func() { db.Close() })
+	return db, stub
+}
+
+// on answers queries containing match with columns and rows. A later rule
+// takes precedence over earlier ones.
+func (s *stubDB) on(match string, columns []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{match: match, columns: columns, rows: rows})
+}
+
+// args returns the arguments of the last query containing match
+func (s *stubDB) args(t *testing.T, match string) []driver.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.calls) - 1; i >= 0; i-- {
+		if strings.Contains(s.calls[i].query, match) {
+			return s.calls[i].args
+		}
+	}
+	t.Fatalf("no query containing %q was run", match)
+	return nil
+}
+
+func (s *stubDB) answer(query string, args []driver.NamedValue) (*stubRows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	s.calls = append(s.calls, call{query: query, args: values})
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		if r := s.rules[i]; strings.Contains(query, r.match) {
+			return &stubRows{columns: r.columns, rows: r.rows}, nil
+		}
+	}
+	return nil, fmt.Errorf("stub database has no rule for query %q", query)
+}
+
+// Connect implements driver.Connector
+func (s *stubDB) Connect(context.Context) (driver.Conn, error) { return &stubConn{db: s}, nil }
+
+// Driver implements driver.Connector
+func (s *stubDB) Driver() driver.Driver { return stubDriver{} }
+
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("stub database is opened with sql.OpenDB")
+}
+
+type stubConn struct {
+	db *stubDB
+}
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("stub database does not prepare statements")
+}
+func (c *stubConn) Close() error              { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.answer(query, args)
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.db.answer(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows.rows)), nil
+}
+
+// stubTx lets handlers use transactions; their queries are answered like
+// any other, and committing or rolling back changes nothing
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
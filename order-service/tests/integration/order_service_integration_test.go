@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/model"
 	"go-microservices/order-service/queue"
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -28,14 +31,19 @@ func setupTestEnvironment(t *testing.T) (*gin.Engine, *redis.Client, func()) {
 	})
 
 	// Setup RabbitMQ
-	err := queue.InitRabbitMQ()
+	err := queue.InitRabbitMQ(rabbitMQURL())
 	if err != nil {
 		t.Fatalf("Failed to initialize RabbitMQ: %v", err)
 	}
 
 	// Setup router and controller
 	router := gin.New()
-	orderController := controller.NewOrderController(nil) // Pass test DB here
+	orderController := controller.NewOrderController(
+		nil, // Pass test DB here
+		service.NewInventoryService("http://localhost:8082", os.Getenv("SERVICE_TOKEN"), resilience.New("inventory-service", resilience.DefaultConfig())),
+		service.NewNotificationService("http://localhost:8083", resilience.New("notification-service", resilience.DefaultConfig())),
+		service.NewPaymentService("http://localhost:8084", os.Getenv("SERVICE_TOKEN"), resilience.New("payment-service", resilience.DefaultConfig())),
+	)
 	router.POST("/orders", orderController.CreateOrder)
 	router.GET("/orders/:id", orderController.GetOrder)
 	router.POST("/orders/batch", orderController.CreateBatchOrders)
@@ -77,9 +85,9 @@ func TestOrderFlowIntegration(t *testing.T) {
 	assert.NotZero(t, createdOrder.ID)
 
 	// Test retrieving the order from cache
-	cacheKey := fmt.Sprintf("order:%d", createdOrder.ID)
+	cacheKey := controller.OrderCacheKey(strconv.Itoa(createdOrder.ID))
 	var cachedOrder model.Order
-	err = cache.Get(cacheKey, &cachedOrder)
+	err = cache.Get(context.Background(), cacheKey, &cachedOrder)
 	assert.NoError(t, err)
 	assert.Equal(t, createdOrder.ID, cachedOrder.ID)
 
@@ -104,7 +112,7 @@ func TestOrderFlowIntegration(t *testing.T) {
 }
 
 func TestCacheIntegration(t *testing.T) {
-	router, redisClient, cleanup := setupTestEnvironment(t)
+	router, _, cleanup := setupTestEnvironment(t)
 	defer cleanup()
 
 	// Create test data
@@ -115,8 +123,8 @@ func TestCacheIntegration(t *testing.T) {
 	}
 
 	// Set in cache
-	cacheKey := "order:1"
-	err := cache.Set(cacheKey, testOrder, 1*time.Minute)
+	cacheKey := controller.OrderCacheKey("1")
+	err := cache.Set(context.Background(), cacheKey, testOrder, 1*time.Minute)
 	assert.NoError(t, err)
 
 	// Test retrieving from cache via API
@@ -132,7 +140,7 @@ func TestCacheIntegration(t *testing.T) {
 	assert.Equal(t, testOrder.ID, response.ID)
 
 	// Test cache expiration
-	redisClient.Del(context.Background(), cacheKey)
+	cache.Delete(context.Background(), cacheKey)
 	w = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/orders/1", nil)
 	router.ServeHTTP(w, req)
@@ -156,7 +164,7 @@ func TestMessageQueueIntegration(t *testing.T) {
 
 	// Create a channel to receive messages
 	messages := make(chan []byte)
-	err = queue.ConsumeMessages(testQueue, func(msg []byte) error {
+	err = queue.ConsumeMessages(testQueue, func(ctx context.Context, msg []byte) error {
 		messages <- msg
 		return nil
 	})
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetCache empties the in-process tier. Unit tests never call InitRedis,
+// so the cache runs as it does while Redis is unavailable.
+func resetCache(t *testing.T, configure func(*cache.Options)) {
+	opts := cache.DefaultOptions()
+	opts.EarlyRefreshBeta = 0
+	if configure != nil {
+		configure(&opts)
+	}
+	cache.Configure(opts)
+	t.Cleanup(func() { cache.Configure(cache.DefaultOptions()) })
+}
+
+func TestCache_CoalescesConcurrentLoads(t *testing.T) {
+	resetCache(t, nil)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := cache.GetOrSet(context.Background(), "hot", &results[i], time.Minute, func(context.Context) (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "value", nil
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		assert.Equal(t, "value", result)
+	}
+}
+
+func TestCache_CachesNotFoundBriefly(t *testing.T) {
+	resetCache(t, func(o *cache.Options) { o.NegativeTTL = 50 * time.Millisecond })
+	calls := 0
+	missing := func(context.Context) (interface{}, error) {
+		calls++
+		return nil, cache.ErrNotFound
+	}
+
+	var value string
+	assert.ErrorIs(t, cache.GetOrSet(context.Background(), "order:404", &value, time.Minute, missing), cache.ErrNotFound)
+	assert.ErrorIs(t, cache.GetOrSet(context.Background(), "order:404", &value, time.Minute, missing), cache.ErrNotFound)
+	assert.Equal(t, 1, calls)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.ErrorIs(t, cache.GetOrSet(context.Background(), "order:404", &value, time.Minute, missing), cache.ErrNotFound)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCache_LoadErrorsAreNotCached(t *testing.T) {
+	resetCache(t, nil)
+	calls := 0
+	failing := func(context.Context) (interface{}, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	}
+
+	var value string
+	assert.Error(t, cache.GetOrSet(context.Background(), "order:1", &value, time.Minute, failing))
+	assert.Error(t, cache.GetOrSet(context.Background(), "order:1", &value, time.Minute, failing))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCache_SharedLoadOutlivesTheCallerThatStartedIt(t *testing.T) {
+	resetCache(t, nil)
+	started, release := make(chan struct{}), make(chan struct{})
+	var loadErr error
+	load := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		loadErr = ctx.Err()
+		return "value", nil
+	}
+
+	// The first caller gives up while its load is running
+	first, cancel := context.WithCancel(context.Background())
+	firstDone := make(chan error)
+	go func() {
+		var value string
+		firstDone <- cache.GetOrSet(first, "order:1", &value, time.Minute, load)
+	}()
+	<-started
+	secondDone := make(chan string)
+	go func() {
+		var value string
+		assert.NoError(t, cache.GetOrSet(context.Background(), "order:1", &value, time.Minute, load))
+		secondDone <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-firstDone, context.Canceled)
+
+	// The second caller still gets the value the first one's load produced
+	close(release)
+	assert.Equal(t, "value", <-secondDone)
+	assert.NoError(t, loadErr)
+}
+
+func TestCache_LoadsAreBoundedByTheLoadTimeout(t *testing.T) {
+	resetCache(t, func(o *cache.Options) { o.LoadTimeout = 20 * time.Millisecond })
+	hanging := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	var value string
+	err := cache.GetOrSet(context.Background(), "order:1", &value, time.Minute, hanging)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCache_InvalidateDuringLoadKeepsTheResultOutOfTheCache(t *testing.T) {
+	resetCache(t, nil)
+	ctx := context.Background()
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	stale := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return "pending", nil
+	}
+
+	done := make(chan string)
+	go func() {
+		var value string
+		assert.NoError(t, cache.GetOrSet(ctx, "order:1", &value, time.Minute, stale))
+		done <- value
+	}()
+	<-started
+	cache.Invalidate(ctx, "order:1")
+	close(release)
+	// The caller that asked before the invalidation gets what was read
+	assert.Equal(t, "pending", <-done)
+
+	var value string
+	require.NoError(t, cache.GetOrSet(ctx, "order:1", &value, time.Minute, func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		return "cancelled", nil
+	}))
+	assert.Equal(t, "cancelled", value)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_ServesFromProcessWithoutRedis(t *testing.T) {
+	resetCache(t, nil)
+	ctx := context.Background()
+
+	// Writes report that Redis is unavailable but keep the local copy
+	assert.Error(t, cache.Set(ctx, "order:1", "shipped", time.Minute))
+	var value string
+	require.NoError(t, cache.Get(ctx, "order:1", &value))
+	assert.Equal(t, "shipped", value)
+
+	assert.Error(t, cache.Invalidate(ctx, "order:1"))
+	assert.Error(t, cache.Get(ctx, "order:1", &value))
+}
+
+func TestCache_LocalTierEvictsLeastRecentlyUsed(t *testing.T) {
+	resetCache(t, func(o *cache.Options) { o.LocalSize = 2 })
+	ctx := context.Background()
+
+	for i := 1; i <= 2; i++ {
+		cache.Set(ctx, fmt.Sprintf("order:%d", i), i, time.Minute)
+	}
+	var value int
+	require.NoError(t, cache.Get(ctx, "order:1", &value))
+	cache.Set(ctx, "order:3", 3, time.Minute)
+
+	assert.NoError(t, cache.Get(ctx, "order:1", &value))
+	assert.Error(t, cache.Get(ctx, "order:2", &value), "least recently used entry should be evicted")
+	assert.NoError(t, cache.Get(ctx, "order:3", &value))
+}
+
+func TestCache_RefreshesSlowEntriesEarly(t *testing.T) {
+	resetCache(t, func(o *cache.Options) { o.EarlyRefreshBeta = 1e9 })
+	calls := 0
+	slow := func(context.Context) (interface{}, error) {
+		calls++
+		time.Sleep(2 * time.Millisecond)
+		return calls, nil
+	}
+
+	var value int
+	require.NoError(t, cache.GetOrSet(context.Background(), "order:1", &value, time.Hour, slow))
+	require.NoError(t, cache.GetOrSet(context.Background(), "order:1", &value, time.Hour, slow))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, value)
+}
@@ -0,0 +1,71 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_StopsWhenContextIsCancelled(t *testing.T) {
+	config := resilience.DefaultConfig()
+	config.MaxRetries = 3
+	config.RetryBaseDelay = 2 * time.Second
+	config.RetryMaxDelay = 2 * time.Second
+	config.RetryJitter = 0
+	policy := resilience.New("test", config)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := policy.Execute(ctx, func(context.Context) error {
+		attempts++
+		return errors.New("unavailable")
+	})
+
+	// Without cancellation the backoff alone would take 6 seconds
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestPolicy_DoesNotRunWithCancelledContext(t *testing.T) {
+	policy := resilience.New("test", resilience.DefaultConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := policy.Execute(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+}
+
+func TestProcessBatch_StopsWhenContextIsCancelled(t *testing.T) {
+	orders := make([]model.Order, 50)
+	for i := range orders {
+		orders[i].ID = i + 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results := worker.ProcessBatch(ctx, orders, 2, 30*time.Second)
+
+	// Two workers at 100ms per order would need 2.5 seconds for the whole batch
+	assert.Less(t, len(results), len(orders))
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/expiry"
+	"go-microservices/order-service/model"
+	paymentmodel "go-microservices/payment-service/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireOrders_CancelsUnpaidOrders(t *testing.T) {
+	env := setupCancelEnvironment()
+	cutoff := time.Now().Add(-time.Hour)
+	due := []model.Order{{ID: 7, CustomerID: 3}, {ID: 8, CustomerID: 3}, {ID: 9, CustomerID: 3}, {ID: 10, CustomerID: 3}}
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 7).Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusPending}}, nil)
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 8).Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusSucceeded}}, nil)
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 9).Return(nil, errors.New("connection refused"))
+	// Order 10 was placed without checkout, so it has no payment to wait for
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 10).Return([]paymentmodel.Payment{}, nil)
+
+	// A full batch is followed by another, which finds nothing more; only the
+	// unpaid order is cancelled
+	env.repo.On("PendingOrders", mock.Anything, cutoff, 0, 4).Return(due, nil).Once()
+	env.repo.On("PendingOrders", mock.Anything, cutoff, 10, 4).Return(nil, nil).Once()
+	env.repo.On("ExpireOrder", mock.Anything, 7, cutoff).Return(cancelledOrder(model.CancelExpired), nil).Once()
+	env.cache.On("Invalidate", mock.Anything, mock.Anything).Return(nil)
+	env.inventory.On("ReleaseStock", mock.Anything, 7).Return(nil)
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "abandoned").Return([]paymentmodel.Payment{}, nil)
+	notified := make(chan struct{})
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "cancelled").Return(nil).Run(func(mock.Arguments) {
+		close(notified)
+	})
+
+	expired, err := env.oc.ExpireOrders(context.Background(), cutoff, 4)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("expiry notification was not sent")
+	}
+	env.repo.AssertExpectations(t)
+	env.repo.AssertNumberOfCalls(t, "ExpireOrder", 1)
+	env.inventory.AssertExpectations(t)
+	env.payment.AssertExpectations(t)
+}
+
+func TestExpireOrders_SkipsOrdersThatChangedSinceListed(t *testing.T) {
+	env := setupCancelEnvironment()
+	cutoff := time.Now().Add(-time.Hour)
+	env.repo.On("PendingOrders", mock.Anything, cutoff, 0, 10).Return([]model.Order{{ID: 7, CustomerID: 3}}, nil).Once()
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 7).Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusPending}}, nil)
+	// Another replica expired it, or it was paid, after it was listed
+	env.repo.On("ExpireOrder", mock.Anything, 7, cutoff).Return(nil, sql.ErrNoRows)
+
+	expired, err := env.oc.ExpireOrders(context.Background(), cutoff, 10)
+
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+	env.repo.AssertNumberOfCalls(t, "PendingOrders", 1)
+	env.inventory.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+	env.payment.AssertNotCalled(t, "CancelOrderPayments", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExpireOrders_RefundsPaymentsThatWentThroughWhileExpiring(t *testing.T) {
+	env := setupCancelEnvironment()
+	cutoff := time.Now().Add(-time.Hour)
+	env.repo.On("PendingOrders", mock.Anything, cutoff, 0, 10).Return([]model.Order{{ID: 7, CustomerID: 3}}, nil).Once()
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 7).Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusPending}}, nil).Once()
+	env.repo.On("ExpireOrder", mock.Anything, 7, cutoff).Return(cancelledOrder(model.CancelExpired), nil).Once()
+	env.cache.On("Invalidate", mock.Anything, mock.Anything).Return(nil)
+	env.inventory.On("ReleaseStock", mock.Anything, 7).Return(nil)
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "cancelled").Return(nil)
+	// The customer paid after the payments were checked and before the order
+	// was cancelled, too late for the intent to be cancelled instead
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "abandoned").Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusPending}}, nil).Once()
+	env.payment.On("GetPaymentsByOrder", mock.Anything, 7).Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusSucceeded}}, nil).Once()
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "abandoned").Return([]paymentmodel.Payment{{Status: paymentmodel.PaymentStatusRefunded}}, nil).Once()
+
+	expired, err := env.oc.ExpireOrders(context.Background(), cutoff, 10)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	env.payment.AssertNumberOfCalls(t, "CancelOrderPayments", 2)
+	env.payment.AssertExpectations(t)
+}
+
+func TestExpireOrders_StopsOnDatabaseError(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.repo.On("PendingOrders", mock.Anything, mock.Anything, 0, 10).Return(nil, errors.New("connection reset"))
+
+	expired, err := env.oc.ExpireOrders(context.Background(), time.Now(), 10)
+
+	assert.Error(t, err)
+	assert.Zero(t, expired)
+	env.inventory.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+}
+
+// fakeExpirer records the runs of the expiry schedule
+type fakeExpirer struct {
+	mu      sync.Mutex
+	cutoffs []time.Time
+	limits  []int
+}
+
+func (f *fakeExpirer) ExpireOrders(_ context.Context, cutoff time.Time, limit int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cutoffs = append(f.cutoffs, cutoff)
+	f.limits = append(f.limits, limit)
+	return 2, nil
+}
+
+func (f *fakeExpirer) runs() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.cutoffs)
+}
+
+func TestExpiryRun_ExpiresOrdersPendingForLongerThanAfter(t *testing.T) {
+	cfg := expiry.Config{After: time.Hour, Interval: time.Minute, BatchSize: 25}
+	expirer := &fakeExpirer{}
+
+	expired, err := expiry.Run(context.Background(), cfg, expirer)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, expired)
+	assert.Equal(t, []int{25}, expirer.limits)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), expirer.cutoffs[0], time.Second)
+}
+
+func TestExpiryScheduler_RunsUntilStopped(t *testing.T) {
+	expirer := &fakeExpirer{}
+	scheduler := expiry.Start(expiry.Config{After: time.Hour, Interval: 10 * time.Millisecond, BatchSize: 5}, expirer)
+
+	assert.Eventually(t, func() bool { return expirer.runs() >= 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, scheduler.Stop(context.Background()))
+	runs := expirer.runs()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, runs, expirer.runs())
+}
+
+func TestExpiryScheduler_CanBeTurnedOff(t *testing.T) {
+	expirer := &fakeExpirer{}
+	scheduler := expiry.Start(expiry.Config{After: 0, Interval: time.Millisecond, BatchSize: 5}, expirer)
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, scheduler.Stop(context.Background()))
+	assert.Zero(t, expirer.runs())
+}
+
+func TestExpiryConfig_Validate(t *testing.T) {
+	cfg := expiry.DefaultConfig()
+	assert.NoError(t, cfg.Validate())
+
+	cfg = expiry.Config{After: -time.Minute, Interval: 0, BatchSize: 0}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ORDER_EXPIRY_AFTER")
+	assert.Contains(t, err.Error(), "ORDER_EXPIRY_INTERVAL")
+	assert.Contains(t, err.Error(), "ORDER_EXPIRY_BATCH_SIZE")
+}
@@ -0,0 +1,350 @@
+package unit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/routes"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type cancelEnv struct {
+	router       *gin.Engine
+	repo         *MockOrderRepository
+	inventory    *MockInventoryService
+	notification *MockNotificationService
+	payment      *MockPaymentService
+	cache        *MockCache
+	// oc serves the routes; calls that don't go through HTTP use it directly
+	oc *controller.OrderController
+}
+
+// setupCancelEnvironment serves the order routes that cancel, create and purge orders
+func setupCancelEnvironment() *cancelEnv {
+	gin.SetMode(gin.TestMode)
+	env := &cancelEnv{
+		router:       gin.New(),
+		repo:         new(MockOrderRepository),
+		inventory:    new(MockInventoryService),
+		notification: new(MockNotificationService),
+		payment:      new(MockPaymentService),
+		cache:        new(MockCache),
+	}
+	env.oc = &controller.OrderController{
+		OrderRepo:           env.repo,
+		InventoryService:    env.inventory,
+		NotificationService: env.notification,
+		PaymentService:      env.payment,
+		Cache:               env.cache,
+	}
+	oc := env.oc
+	env.router.POST("/orders", oc.CreateOrder)
+	env.router.PUT("/orders/:id", oc.UpdateOrder)
+	env.router.PATCH("/orders/:id/status", oc.UpdateOrderStatus)
+	env.router.DELETE("/orders/:id", oc.DeleteOrder)
+	env.router.POST("/orders/:id/cancel", oc.CancelOrder)
+	env.router.DELETE("/admin/orders/:id", admin.Authorize("secret"), oc.PurgeOrder)
+	return env
+}
+
+func (env *cancelEnv) do(method, path, body string) *httptest.ResponseRecorder {
+	return env.doAs(method, path, body, "secret")
+}
+
+// doAs sends a request with adminToken, or without one when it is empty
+func (env *cancelEnv) doAs(method, path, body, adminToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if adminToken != "" {
+		req.Header.Set(admin.Header, adminToken)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func cancelledOrder(reason string) *model.Order {
+	at := time.Now()
+	return &model.Order{ID: 7, CustomerID: 3, ProductID: 1, Quantity: 2, Status: model.StatusCancelled, CancelledAt: &at, CancelReason: reason}
+}
+
+func TestCancelOrder_ReleasesStockAndSettlesPayments(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.repo.On("CancelOrder", mock.Anything, 7, model.CancelFraudSuspected).Return(cancelledOrder(model.CancelFraudSuspected), nil)
+	env.cache.On("Invalidate", mock.Anything, []string{controller.OrderCacheKey("7")}).Return(nil)
+	env.inventory.On("ReleaseStock", mock.Anything, 7).Return(nil)
+	refunded := []paymentmodel.Payment{{ID: 1, OrderID: 7, Status: paymentmodel.PaymentStatusRefunded}}
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "fraudulent").Return(refunded, nil)
+	notified := make(chan struct{})
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "cancelled").Return(nil).Run(func(mock.Arguments) {
+		close(notified)
+	})
+
+	w := env.do("POST", "/orders/7/cancel", `{"reason":"fraud_suspected"}`)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got controller.Cancellation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, model.StatusCancelled, got.Order.Status)
+	assert.Equal(t, model.CancelFraudSuspected, got.Order.CancelReason)
+	assert.NotNil(t, got.Order.CancelledAt)
+	assert.True(t, got.StockReleased)
+	assert.True(t, got.PaymentsSettled)
+	assert.Equal(t, refunded, got.Payments)
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("cancellation notification was not sent")
+	}
+	env.cache.AssertExpectations(t)
+}
+
+func TestCancelOrder_RetriesCompensationOfCancelledOrder(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.repo.On("CancelOrder", mock.Anything, 7, model.CancelOther).Return(nil, sql.ErrNoRows)
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(cancelledOrder(model.CancelCustomerRequest), nil)
+	env.inventory.On("ReleaseStock", mock.Anything, 7).Return(nil)
+	// The reason recorded when the order was cancelled is the one Stripe gets
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "requested_by_customer").Return([]paymentmodel.Payment{}, nil)
+
+	w := env.do("POST", "/orders/7/cancel", `{"reason":"other"}`)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got controller.Cancellation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, model.CancelCustomerRequest, got.Order.CancelReason)
+	assert.True(t, got.StockReleased)
+	assert.True(t, got.PaymentsSettled)
+	env.inventory.AssertExpectations(t)
+	env.payment.AssertExpectations(t)
+	env.cache.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+	env.notification.AssertNotCalled(t, "SendOrderStatusUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelOrder_ReportsFailedCompensation(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.repo.On("CancelOrder", mock.Anything, 7, model.CancelCustomerRequest).Return(cancelledOrder(model.CancelCustomerRequest), nil)
+	env.cache.On("Invalidate", mock.Anything, mock.Anything).Return(nil)
+	env.inventory.On("ReleaseStock", mock.Anything, 7).Return(errors.New("connection refused"))
+	env.payment.On("CancelOrderPayments", mock.Anything, 7, "requested_by_customer").Return(nil, errors.New("connection refused"))
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "cancelled").Return(nil)
+
+	// DELETE cancels at the customer's request
+	w := env.do("DELETE", "/orders/7", "")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got controller.Cancellation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, model.StatusCancelled, got.Order.Status)
+	assert.False(t, got.StockReleased)
+	assert.False(t, got.PaymentsSettled)
+}
+
+func TestCancelOrder_Rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		order  *model.Order
+		err    error
+		status int
+		code   problem.Code
+	}{
+		{name: "shipped", body: `{"reason":"other"}`, order: &model.Order{ID: 7, Status: "shipped"},
+			status: http.StatusConflict, code: problem.CodeOrderNotCancellable},
+		{name: "missing", body: `{"reason":"other"}`, err: sql.ErrNoRows,
+			status: http.StatusNotFound, code: problem.CodeOrderNotFound},
+		{name: "unknown reason", body: `{"reason":"bored"}`,
+			status: http.StatusBadRequest, code: problem.CodeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupCancelEnvironment()
+			env.repo.On("CancelOrder", mock.Anything, 7, mock.Anything).Return(nil, sql.ErrNoRows)
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(tt.order, tt.err)
+
+			w := env.do("POST", "/orders/7/cancel", tt.body)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.code, got.Code)
+			env.inventory.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+			env.payment.AssertNotCalled(t, "CancelOrderPayments", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOrderChanges_CannotCancelOrChangeCancelledOrders(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		order  *model.Order
+		status int
+		code   problem.Code
+	}{
+		{name: "status to cancelled", method: "PATCH", path: "/orders/7/status", body: `{"status":"cancelled"}`,
+			order: &model.Order{ID: 7, Status: "pending"}, status: http.StatusBadRequest, code: problem.CodeValidation},
+		{name: "update to cancelled", method: "PUT", path: "/orders/7", body: `{"customer_id":3,"product_id":1,"quantity":2,"status":"cancelled"}`,
+			order: &model.Order{ID: 7, Status: "pending"}, status: http.StatusBadRequest, code: problem.CodeValidation},
+		{name: "update cancelled order", method: "PUT", path: "/orders/7", body: `{"customer_id":3,"product_id":1,"quantity":2,"status":"pending"}`,
+			order: cancelledOrder(model.CancelOther), status: http.StatusConflict, code: problem.CodeConflict},
+		{name: "status of cancelled order", method: "PATCH", path: "/orders/7/status", body: `{"status":"shipped"}`,
+			order: cancelledOrder(model.CancelOther), status: http.StatusConflict, code: problem.CodeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupCancelEnvironment()
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(tt.order, nil)
+
+			w := env.do(tt.method, tt.path, tt.body)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.code, got.Code)
+			env.repo.AssertNotCalled(t, "UpdateOrder", mock.Anything, mock.Anything)
+			env.repo.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOrderChanges_OrderCancelledDuringUpdateIsAConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		setup  func(repo *MockOrderRepository)
+	}{
+		{name: "update", method: "PUT", path: "/orders/7", body: `{"customer_id":3,"product_id":1,"quantity":2,"status":"processing"}`,
+			setup: func(repo *MockOrderRepository) {
+				repo.On("UpdateOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(sql.ErrNoRows)
+			}},
+		{name: "status", method: "PATCH", path: "/orders/7/status", body: `{"status":"processing"}`,
+			setup: func(repo *MockOrderRepository) {
+				repo.On("UpdateOrderStatus", mock.Anything, 7, "processing").Return(sql.ErrNoRows)
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupCancelEnvironment()
+			// Pending when read, cancelled by the time the update ran
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(&model.Order{ID: 7, CustomerID: 3, ProductID: 1, Quantity: 2, Status: "pending"}, nil).Once()
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(cancelledOrder(model.CancelOther), nil)
+			tt.setup(env.repo)
+
+			w := env.do(tt.method, tt.path, tt.body)
+
+			assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, problem.CodeConflict, got.Code)
+			env.cache.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateOrder_RejectsProductAndQuantityChanges(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(&model.Order{ID: 7, CustomerID: 3, ProductID: 1, Quantity: 2, Status: "pending"}, nil)
+
+	w := env.do("PUT", "/orders/7", `{"customer_id":3,"product_id":4,"quantity":5,"status":"pending"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var got problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, problem.CodeValidation, got.Code)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "product_id", Rule: "immutable", Message: "cannot be changed; cancel the order and place a new one"},
+		{Field: "quantity", Rule: "immutable", Message: "cannot be changed; cancel the order and place a new one"},
+	}, got.Errors)
+	env.repo.AssertNotCalled(t, "UpdateOrder", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_CancelsOrderWhenStockCannotBeReserved(t *testing.T) {
+	env := setupCancelEnvironment()
+	env.inventory.On("CheckAvailability", mock.Anything, 1, 2).Return(true, nil)
+	env.repo.On("InsertOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Order).ID = 42
+	})
+	// The stock was taken between the check and the reservation
+	env.inventory.On("ReserveStock", mock.Anything, 42, 1, 2).Return(problem.New(problem.CodeInsufficientStock, "Not enough stock"))
+	env.repo.On("CancelOrder", mock.Anything, 42, model.CancelOutOfStock).Return(cancelledOrder(model.CancelOutOfStock), nil)
+
+	w := env.do("POST", "/orders", `{"customer_id":1,"product_id":1,"quantity":2}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	var got problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, problem.CodeInsufficientStock, got.Code)
+	env.repo.AssertExpectations(t)
+	env.cache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	env.notification.AssertNotCalled(t, "SendOrderNotification", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPurgeOrder(t *testing.T) {
+	t.Run("cancelled", func(t *testing.T) {
+		env := setupCancelEnvironment()
+		env.repo.On("PurgeOrder", mock.Anything, 7).Return(nil)
+		env.cache.On("Invalidate", mock.Anything, []string{controller.OrderCacheKey("7")}).Return(nil)
+
+		w := env.do("DELETE", "/admin/orders/7", "")
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		env.repo.AssertExpectations(t)
+		env.cache.AssertExpectations(t)
+	})
+
+	t.Run("not cancelled", func(t *testing.T) {
+		env := setupCancelEnvironment()
+		env.repo.On("PurgeOrder", mock.Anything, 7).Return(sql.ErrNoRows)
+		env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(&model.Order{ID: 7, Status: "pending"}, nil)
+
+		w := env.do("DELETE", "/admin/orders/7", "")
+
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		env.cache.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("without admin token", func(t *testing.T) {
+		env := setupCancelEnvironment()
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/orders/7", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		env.repo.AssertNotCalled(t, "PurgeOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin token not configured", func(t *testing.T) {
+		env := setupCancelEnvironment()
+		router := gin.New()
+		routes.SetupRoutes(router, env.oc, "")
+		req := httptest.NewRequest("DELETE", "/admin/orders/7", nil)
+		req.Header.Set(admin.Header, "")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		env.repo.AssertNotCalled(t, "PurgeOrder", mock.Anything, mock.Anything)
+	})
+}
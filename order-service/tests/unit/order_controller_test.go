@@ -2,6 +2,8 @@ package unit
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +13,8 @@ import (
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/model"
 	"go-microservices/order-service/queue"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -22,36 +26,79 @@ type MockInventoryService struct {
 	mock.Mock
 }
 
-func (m *MockInventoryService) CheckAvailability(productID int, quantity int) (bool, error) {
-	args := m.Called(productID, quantity)
+func (m *MockInventoryService) CheckAvailability(ctx context.Context, productID int, quantity int) (bool, error) {
+	args := m.Called(ctx, productID, quantity)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockInventoryService) ReserveStock(ctx context.Context, orderID int, productID int, quantity int) error {
+	args := m.Called(ctx, orderID, productID, quantity)
+	return args.Error(0)
+}
+
+func (m *MockInventoryService) ReleaseStock(ctx context.Context, orderID int) error {
+	args := m.Called(ctx, orderID)
+	return args.Error(0)
+}
+
+func (m *MockInventoryService) RestockReturn(ctx context.Context, returnID int, orderID int, productID int, quantity int) error {
+	args := m.Called(ctx, returnID, orderID, productID, quantity)
+	return args.Error(0)
+}
+
 type MockNotificationService struct {
 	mock.Mock
 }
 
-func (m *MockNotificationService) SendOrderNotification(orderID int) error {
-	args := m.Called(orderID)
+func (m *MockNotificationService) SendOrderNotification(ctx context.Context, orderID int, customerID int) error {
+	args := m.Called(ctx, orderID, customerID)
 	return args.Error(0)
 }
 
-func (m *MockNotificationService) SendOrderStatusUpdate(orderID int, customerID int, status string) error {
-	args := m.Called(orderID, customerID, status)
+func (m *MockNotificationService) SendOrderStatusUpdate(ctx context.Context, orderID int, customerID int, status string) error {
+	args := m.Called(ctx, orderID, customerID, status)
 	return args.Error(0)
 }
 
+type MockPaymentService struct {
+	mock.Mock
+}
+
+func (m *MockPaymentService) CreatePayment(ctx context.Context, orderID int, customerID int, amount float64, currency string) (*paymentmodel.PaymentResponse, error) {
+	args := m.Called(ctx, orderID, customerID, amount, currency)
+	resp, _ := args.Get(0).(*paymentmodel.PaymentResponse)
+	return resp, args.Error(1)
+}
+
+func (m *MockPaymentService) CancelOrderPayments(ctx context.Context, orderID int, reason string) ([]paymentmodel.Payment, error) {
+	args := m.Called(ctx, orderID, reason)
+	payments, _ := args.Get(0).([]paymentmodel.Payment)
+	return payments, args.Error(1)
+}
+
+func (m *MockPaymentService) GetPaymentsByOrder(ctx context.Context, orderID int) ([]paymentmodel.Payment, error) {
+	args := m.Called(ctx, orderID)
+	payments, _ := args.Get(0).([]paymentmodel.Payment)
+	return payments, args.Error(1)
+}
+
+func (m *MockPaymentService) RefundOrder(ctx context.Context, orderID int, amount float64, reference string) (*paymentmodel.Refund, error) {
+	args := m.Called(ctx, orderID, amount, reference)
+	refund, _ := args.Get(0).(*paymentmodel.Refund)
+	return refund, args.Error(1)
+}
+
 type MockOrderRepository struct {
 	mock.Mock
 }
 
-func (m *MockOrderRepository) InsertOrder(order *model.Order) error {
-	args := m.Called(order)
+func (m *MockOrderRepository) InsertOrder(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
 	return args.Error(0)
 }
 
-func (m *MockOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
-	args := m.Called(orderID)
+func (m *MockOrderRepository) GetOrderFromDB(ctx context.Context, orderID string) (*model.Order, error) {
+	args := m.Called(ctx, orderID)
 	order, ok := args.Get(0).(*model.Order)
 	if !ok {
 		return nil, args.Error(1)
@@ -59,12 +106,45 @@ func (m *MockOrderRepository) GetOrderFromDB(orderID string) (*model.Order, erro
 	return order, args.Error(1)
 }
 
+func (m *MockOrderRepository) UpdateOrder(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpdateOrderStatus(ctx context.Context, orderID int, status string) error {
+	args := m.Called(ctx, orderID, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CancelOrder(ctx context.Context, orderID int, reason string) (*model.Order, error) {
+	args := m.Called(ctx, orderID, reason)
+	order, _ := args.Get(0).(*model.Order)
+	return order, args.Error(1)
+}
+
+func (m *MockOrderRepository) PurgeOrder(ctx context.Context, orderID int) error {
+	args := m.Called(ctx, orderID)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) PendingOrders(ctx context.Context, cutoff time.Time, afterID, limit int) ([]model.Order, error) {
+	args := m.Called(ctx, cutoff, afterID, limit)
+	orders, _ := args.Get(0).([]model.Order)
+	return orders, args.Error(1)
+}
+
+func (m *MockOrderRepository) ExpireOrder(ctx context.Context, orderID int, cutoff time.Time) (*model.Order, error) {
+	args := m.Called(ctx, orderID, cutoff)
+	order, _ := args.Get(0).(*model.Order)
+	return order, args.Error(1)
+}
+
 type MockMessageQueue struct {
 	mock.Mock
 }
 
-func (m *MockMessageQueue) PublishMessage(config queue.Config, message interface{}) error {
-	args := m.Called(config, message)
+func (m *MockMessageQueue) PublishMessage(ctx context.Context, config queue.Config, message interface{}) error {
+	args := m.Called(ctx, config, message)
 	return args.Error(0)
 }
 
@@ -72,18 +152,23 @@ type MockCache struct {
 	mock.Mock
 }
 
-func (m *MockCache) Get(key string, value interface{}) error {
-	args := m.Called(key, value)
+func (m *MockCache) Get(ctx context.Context, key string, value interface{}) error {
+	args := m.Called(ctx, key, value)
 	return args.Error(0)
 }
 
-func (m *MockCache) Set(key string, value interface{}, expiration time.Duration) error {
-	args := m.Called(key, value, expiration)
+func (m *MockCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	args := m.Called(ctx, key, value, expiration)
 	return args.Error(0)
 }
 
-func (m *MockCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	args := m.Called(key, value, expiration, fn)
+func (m *MockCache) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func(ctx context.Context) (interface{}, error)) error {
+	args := m.Called(ctx, key, value, expiration, fn)
+	return args.Error(0)
+}
+
+func (m *MockCache) Invalidate(ctx context.Context, keys ...string) error {
+	args := m.Called(ctx, keys)
 	return args.Error(0)
 }
 
@@ -112,13 +197,16 @@ func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventorySe
 	// Setup routes
 	router.POST("/orders", orderController.CreateOrder)
 	router.GET("/orders/:id", orderController.GetOrder)
+	router.PUT("/orders/:id", orderController.UpdateOrder)
+	router.PATCH("/orders/:id/status", orderController.UpdateOrderStatus)
+	router.DELETE("/orders/:id", orderController.DeleteOrder)
 
 	return router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache
 }
 
 func TestCreateOrder_Success(t *testing.T) {
 	// Setup
-	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache := setupTestEnvironment()
 
 	// Prepare test data
 	order := model.Order{
@@ -128,10 +216,18 @@ func TestCreateOrder_Success(t *testing.T) {
 	}
 
 	// Set up mock expectations
-	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
-	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
-	mockNotification.On("SendOrderNotification", mock.AnythingOfType("int")).Return(nil)
-	mockQueue.On("PublishMessage", mock.AnythingOfType("queue.Config"), mock.Anything).Return(nil)
+	mockOrderRepo.On("InsertOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Order).ID = 42
+	})
+	mockInventory.On("CheckAvailability", mock.Anything, 1, 2).Return(true, nil)
+	mockInventory.On("ReserveStock", mock.Anything, 42, 1, 2).Return(nil)
+	// The new order is written through to the cache
+	mockCache.On("Set", mock.Anything, controller.OrderCacheKey("42"), mock.AnythingOfType("model.Order"), 30*time.Minute).Return(nil)
+	notified := make(chan struct{})
+	mockNotification.On("SendOrderNotification", mock.Anything, 42, order.CustomerID).Return(nil).Run(func(mock.Arguments) {
+		close(notified)
+	})
+	mockQueue.On("PublishMessage", mock.Anything, mock.AnythingOfType("queue.Config"), mock.Anything).Return(nil)
 
 	// Create request
 	orderJSON, _ := json.Marshal(order)
@@ -147,11 +243,19 @@ func TestCreateOrder_Success(t *testing.T) {
 	// Assert response
 	assert.Equal(t, http.StatusCreated, w.Code)
 
+	// The notification is sent in the background after the response
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("order notification was not sent")
+	}
+
 	// Verify all mocks were called as expected
 	mockOrderRepo.AssertExpectations(t)
 	mockInventory.AssertExpectations(t)
 	mockNotification.AssertExpectations(t)
 	mockQueue.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
 
 	// Specifically verify that InsertOrder was called exactly once
 	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 1)
@@ -171,7 +275,7 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	}
 
 	// Set up mock expectations
-	mockInventory.On("CheckAvailability", 1, 100).Return(false, nil)
+	mockInventory.On("CheckAvailability", mock.Anything, 1, 100).Return(false, nil)
 	// Other mocks should not be called
 
 	// Create request
@@ -186,7 +290,11 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	// Assert response
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var body problem.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, problem.CodeInsufficientStock, body.Code)
 
 	// Verify mocks
 	mockInventory.AssertExpectations(t)
@@ -194,4 +302,190 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	mockOrderRepo.AssertNotCalled(t, "InsertOrder")
 	mockNotification.AssertNotCalled(t, "SendOrderNotification")
 	mockQueue.AssertNotCalled(t, "PublishMessage")
-}
\ No newline at end of file
+}
+
+func TestCreateOrderWithPayment_DeclinedReturnsPaymentRequired(t *testing.T) {
+	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	mockPayment := new(MockPaymentService)
+	orderController := &controller.OrderController{
+		OrderRepo:           mockOrderRepo,
+		InventoryService:    mockInventory,
+		NotificationService: mockNotification,
+		PaymentService:      mockPayment,
+		Queue:               mockQueue,
+	}
+	router.POST("/orders/with-payment", orderController.CreateOrderWithPayment)
+
+	declined := problem.New(problem.CodePaymentDeclined, "Your card was declined.").With("decline_code", "insufficient_funds")
+	mockInventory.On("CheckAvailability", mock.Anything, 1, 2).Return(true, nil)
+	mockOrderRepo.On("InsertOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Order).ID = 42
+	})
+	mockInventory.On("ReserveStock", mock.Anything, 42, 1, 2).Return(nil)
+	mockPayment.On("CreatePayment", mock.Anything, 42, 1, 20.0, "usd").Return(nil, declined)
+
+	body := `{"product_id":1,"customer_id":1,"quantity":2,"total_price":20,"currency":"usd"}`
+	req := httptest.NewRequest("POST", "/orders/with-payment", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	var got problem.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, problem.CodePaymentDeclined, got.Code)
+	assert.Equal(t, "Your card was declined.", got.Detail)
+	assert.Equal(t, float64(42), got.Extensions["order_id"])
+	assert.Equal(t, "insufficient_funds", got.Extensions["decline_code"])
+	mockQueue.AssertNotCalled(t, "PublishMessage")
+	mockNotification.AssertNotCalled(t, "SendOrderNotification")
+}
+
+func TestOrderMutations_InvalidateCachedOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		setup  func(repo *MockOrderRepository, notification *MockNotificationService)
+	}{
+		{
+			name:   "update",
+			method: "PUT",
+			path:   "/orders/7",
+			body:   `{"customer_id":3,"product_id":1,"quantity":2,"total_price":20,"status":"shipped"}`,
+			setup: func(repo *MockOrderRepository, notification *MockNotificationService) {
+				repo.On("UpdateOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool { return o.ID == 7 })).Return(nil)
+				notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "shipped").Return(nil)
+			},
+		},
+		{
+			name:   "status",
+			method: "PATCH",
+			path:   "/orders/7/status",
+			body:   `{"status":"shipped"}`,
+			setup: func(repo *MockOrderRepository, notification *MockNotificationService) {
+				repo.On("UpdateOrderStatus", mock.Anything, 7, "shipped").Return(nil)
+				notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "shipped").Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderRepo, _, mockNotification, _, mockCache := setupTestEnvironment()
+			mockOrderRepo.On("GetOrderFromDB", mock.Anything, "7").Return(&model.Order{ID: 7, CustomerID: 3, ProductID: 1, Quantity: 2, Status: "pending"}, nil)
+			mockCache.On("Invalidate", mock.Anything, []string{controller.OrderCacheKey("7")}).Return(nil)
+			tt.setup(mockOrderRepo, mockNotification)
+
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			mockOrderRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderMutations_MissingOrderIsNotInvalidated(t *testing.T) {
+	router, mockOrderRepo, _, _, _, mockCache := setupTestEnvironment()
+	mockOrderRepo.On("GetOrderFromDB", mock.Anything, "7").Return(&model.Order{ID: 7, CustomerID: 3}, nil).Once()
+	mockOrderRepo.On("UpdateOrderStatus", mock.Anything, 7, "shipped").Return(sql.ErrNoRows)
+	// Deleted between the read and the update
+	mockOrderRepo.On("GetOrderFromDB", mock.Anything, "7").Return(nil, sql.ErrNoRows)
+
+	req := httptest.NewRequest("PATCH", "/orders/7/status", bytes.NewBufferString(`{"status":"shipped"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockCache.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+}
+
+func TestOrderRequests_AreValidated(t *testing.T) {
+	router, mockOrderRepo, mockInventory, _, _, _ := setupTestEnvironment()
+	orderController := &controller.OrderController{OrderRepo: mockOrderRepo, InventoryService: mockInventory}
+	router.POST("/orders/with-payment", orderController.CreateOrderWithPayment)
+	router.POST("/orders/batch", orderController.CreateBatchOrders)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		errors []problem.FieldError
+	}{
+		{
+			name:   "create",
+			method: "POST",
+			path:   "/orders",
+			body:   `{"customer_id":-3,"quantity":0,"total_price":-1,"status":"lost"}`,
+			errors: []problem.FieldError{
+				{Field: "customer_id", Rule: "gt", Message: "must be greater than 0"},
+				{Field: "product_id", Rule: "required", Message: "is required"},
+				{Field: "quantity", Rule: "required", Message: "is required"},
+				{Field: "total_price", Rule: "gte", Message: "must be at least 0"},
+				{Field: "status", Rule: "order_status", Message: "must be one of pending, processing, shipped, delivered, completed, cancelled"},
+			},
+		},
+		{
+			name:   "with payment",
+			method: "POST",
+			path:   "/orders/with-payment",
+			body:   `{"customer_id":1,"product_id":1,"quantity":2,"total_price":20,"currency":"dollars"}`,
+			errors: []problem.FieldError{
+				{Field: "currency", Rule: "currency", Message: "must be an ISO 4217 currency code, e.g. usd"},
+			},
+		},
+		{
+			name:   "with payment without total",
+			method: "POST",
+			path:   "/orders/with-payment",
+			body:   `{"customer_id":1,"product_id":1,"quantity":2,"currency":"usd"}`,
+			errors: []problem.FieldError{
+				{Field: "total_price", Rule: "gt", Message: "must be greater than 0"},
+			},
+		},
+		{
+			name:   "batch",
+			method: "POST",
+			path:   "/orders/batch",
+			body:   `[{"customer_id":1,"product_id":1,"quantity":2},{"customer_id":1,"product_id":1,"quantity":5000}]`,
+			errors: []problem.FieldError{
+				{Field: "[1].quantity", Rule: "max", Message: "must be at most 1000"},
+			},
+		},
+		{
+			name:   "status",
+			method: "PATCH",
+			path:   "/orders/7/status",
+			body:   `{"status":"teleported"}`,
+			errors: []problem.FieldError{
+				{Field: "status", Rule: "order_status", Message: "must be one of pending, processing, shipped, delivered, completed, cancelled"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var got problem.Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, problem.CodeValidation, got.Code)
+			assert.Equal(t, tt.errors, got.Errors)
+		})
+	}
+
+	// Invalid requests never reach the inventory or the database
+	mockInventory.AssertNotCalled(t, "CheckAvailability", mock.Anything, mock.Anything, mock.Anything)
+	mockOrderRepo.AssertNotCalled(t, "InsertOrder", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,39 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/order-service/resilience"
+	"go-microservices/order-service/service"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/servicetoken"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceClients_ForwardRequestID(t *testing.T) {
+	var received, tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(requestid.Header))
+		tokens = append(tokens, r.Header.Get(servicetoken.Header))
+		w.Write([]byte(`{"available":true}`))
+	}))
+	defer server.Close()
+
+	ctx := requestid.WithID(context.Background(), "req-123")
+
+	inventory := service.NewInventoryService(server.URL, "service-secret", resilience.New("inventory-service", resilience.DefaultConfig()))
+	available, err := inventory.CheckAvailability(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, available)
+
+	notifications := service.NewNotificationService(server.URL, resilience.New("notification-service", resilience.DefaultConfig()))
+	require.NoError(t, notifications.SendOrderNotification(ctx, 1, 3))
+
+	assert.Equal(t, []string{"req-123", "req-123"}, received)
+	assert.Equal(t, []string{"service-secret", ""}, tokens)
+}
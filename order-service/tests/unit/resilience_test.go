@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/resilience"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastPolicy returns a policy with millisecond backoff, adjusted by configure
+func fastPolicy(name string, configure func(*resilience.Config)) *resilience.Policy {
+	config := resilience.DefaultConfig()
+	config.RetryBaseDelay = time.Millisecond
+	config.RetryMaxDelay = 4 * time.Millisecond
+	if configure != nil {
+		configure(&config)
+	}
+	return resilience.New(name, config)
+}
+
+func TestPolicy_RetriesTransientErrors(t *testing.T) {
+	policy := fastPolicy("retry-test", func(c *resilience.Config) { c.MaxRetries = 3 })
+
+	attempts := 0
+	err := policy.Execute(context.Background(), func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return problem.New(problem.CodeUnavailable, "")
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestPolicy_DoesNotRetryRejectedRequests(t *testing.T) {
+	policy := fastPolicy("reject-test", func(c *resilience.Config) {
+		c.MaxRetries = 3
+		c.BreakerConsecutiveFailures = 1
+	})
+
+	attempts := 0
+	for i := 0; i < 3; i++ {
+		err := policy.Execute(context.Background(), func(context.Context) error {
+			attempts++
+			return problem.New(problem.CodePaymentDeclined, "card declined")
+		})
+
+		// The problem is returned as is and does not count against the breaker
+		var p *problem.Problem
+		require.True(t, errors.As(err, &p))
+		assert.Equal(t, problem.CodePaymentDeclined, p.Code)
+	}
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, "closed", policy.Status().State)
+}
+
+func TestPolicy_AttemptTimeoutIsRetried(t *testing.T) {
+	policy := fastPolicy("timeout-test", func(c *resilience.Config) {
+		c.Timeout = 10 * time.Millisecond
+		c.MaxRetries = 1
+	})
+
+	attempts := 0
+	err := policy.Execute(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestPolicy_OpenBreakerRejectsCalls(t *testing.T) {
+	policy := fastPolicy("breaker-test", func(c *resilience.Config) {
+		c.MaxRetries = 0
+		c.BreakerConsecutiveFailures = 2
+	})
+	failing := func(context.Context) error { return errors.New("connection refused") }
+
+	require.Error(t, policy.Execute(context.Background(), failing))
+	require.Error(t, policy.Execute(context.Background(), failing))
+	assert.Equal(t, "open", policy.Status().State)
+
+	called := false
+	err := policy.Execute(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.False(t, called)
+}
+
+func TestPolicy_BulkheadRejectsExcessCalls(t *testing.T) {
+	policy := fastPolicy("bulkhead-test", func(c *resilience.Config) {
+		c.MaxConcurrent = 1
+		c.BulkheadMaxWait = 0
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		policy.Execute(context.Background(), func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	assert.Equal(t, 1, policy.Status().InFlight)
+	err := policy.Execute(context.Background(), func(context.Context) error { return nil })
+	assert.ErrorIs(t, err, resilience.ErrBulkheadFull)
+
+	close(release)
+	wg.Wait()
+	assert.NoError(t, policy.Execute(context.Background(), func(context.Context) error { return nil }))
+}
+
+func TestResilience_DebugEndpointListsPolicies(t *testing.T) {
+	fastPolicy("debug-test", func(c *resilience.Config) { c.MaxRetries = 4 })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/debug/resilience", resilience.Handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/debug/resilience", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Policies []struct {
+			Name   string                 `json:"name"`
+			State  string                 `json:"state"`
+			Config map[string]interface{} `json:"config"`
+		} `json:"policies"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	var found bool
+	for _, p := range body.Policies {
+		if p.Name == "debug-test" {
+			found = true
+			assert.Equal(t, "closed", p.State)
+			assert.Equal(t, float64(4), p.Config["max_retries"])
+			assert.Equal(t, "2s", p.Config["timeout"])
+		}
+	}
+	assert.True(t, found)
+}
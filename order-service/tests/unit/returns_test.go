@@ -0,0 +1,401 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/routes"
+	paymentmodel "go-microservices/payment-service/model"
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReturnRepository struct {
+	mock.Mock
+}
+
+func (m *MockReturnRepository) CreateReturn(ctx context.Context, ret *model.Return, orderQuantity int) error {
+	args := m.Called(ctx, ret, orderQuantity)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) ListReturns(ctx context.Context, orderID int) ([]model.Return, error) {
+	args := m.Called(ctx, orderID)
+	returns, _ := args.Get(0).([]model.Return)
+	return returns, args.Error(1)
+}
+
+func (m *MockReturnRepository) GetReturn(ctx context.Context, orderID, returnID int) (*model.Return, error) {
+	args := m.Called(ctx, orderID, returnID)
+	ret, _ := args.Get(0).(*model.Return)
+	return ret, args.Error(1)
+}
+
+func (m *MockReturnRepository) TransitionReturn(ctx context.Context, ret *model.Return, from string) error {
+	args := m.Called(ctx, ret, from)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) RefundedReturns(ctx context.Context, orderID int) (int, float64, error) {
+	args := m.Called(ctx, orderID)
+	return args.Int(0), args.Get(1).(float64), args.Error(2)
+}
+
+// setupReturnEnvironment serves the return routes over the cancellation
+// environment's mocks
+func setupReturnEnvironment() (*cancelEnv, *MockReturnRepository) {
+	env := setupCancelEnvironment()
+	returns := new(MockReturnRepository)
+	env.oc.ReturnRepo = returns
+	oc := env.oc
+	env.router.POST("/orders/:id/returns", oc.RequestReturn)
+	env.router.GET("/orders/:id/returns", oc.GetReturns)
+	staff := env.router.Group("/admin/orders/:id/returns/:returnId", admin.Authorize("secret"))
+	staff.POST("/approve", oc.ApproveReturn)
+	staff.POST("/reject", oc.RejectReturn)
+	staff.POST("/receive", oc.ReceiveReturn)
+	return env, returns
+}
+
+func deliveredOrder() *model.Order {
+	return &model.Order{ID: 7, CustomerID: 3, ProductID: 1, Quantity: 4, TotalPrice: 100, Status: model.StatusDelivered}
+}
+
+// expectReturnNotification waits for the customer to be told about a return step
+func expectReturnNotification(env *cancelEnv, status string) chan struct{} {
+	notified := make(chan struct{})
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, status).Return(nil).Run(func(mock.Arguments) {
+		close(notified)
+	}).Once()
+	return notified
+}
+
+func waitNotified(t *testing.T, notified chan struct{}) {
+	t.Helper()
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("return notification was not sent")
+	}
+}
+
+func TestRequestReturn_CreatesReturnAndNotifies(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	returns.On("CreateReturn", mock.Anything, mock.AnythingOfType("*model.Return"), 4).Return(nil).Run(func(args mock.Arguments) {
+		ret := args.Get(1).(*model.Return)
+		ret.ID, ret.Status = 12, model.ReturnRequested
+	})
+	notified := expectReturnNotification(env, "return_requested")
+
+	w := env.do(http.MethodPost, "/orders/7/returns", `{"quantity": 2, "reason": "damaged", "comment": "Box was crushed"}`)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var ret model.Return
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+	assert.Equal(t, 12, ret.ID)
+	assert.Equal(t, 7, ret.OrderID)
+	assert.Equal(t, 2, ret.Quantity)
+	assert.Equal(t, "damaged", ret.Reason)
+	assert.Equal(t, model.ReturnRequested, ret.Status)
+	waitNotified(t, notified)
+}
+
+func TestRequestReturn_Rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		order  *model.Order
+		body   string
+		create error
+		status int
+		code   problem.Code
+	}{
+		{"order not delivered", &model.Order{ID: 7, Quantity: 4, Status: model.StatusProcessing},
+			`{"quantity": 1, "reason": "damaged"}`, nil, http.StatusConflict, problem.CodeReturnNotAllowed},
+		{"more than is left to return", deliveredOrder(),
+			`{"quantity": 3, "reason": "defective"}`, &controller.ReturnQuantityError{Returnable: 1}, http.StatusConflict, problem.CodeReturnNotAllowed},
+		{"unknown reason", deliveredOrder(),
+			`{"quantity": 1, "reason": "changed_mind"}`, nil, http.StatusBadRequest, problem.CodeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, returns := setupReturnEnvironment()
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(tt.order, nil)
+			returns.On("CreateReturn", mock.Anything, mock.Anything, mock.Anything).Return(tt.create)
+
+			w := env.do(http.MethodPost, "/orders/7/returns", tt.body)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.code, p.Code)
+			if tt.create == nil {
+				returns.AssertNotCalled(t, "CreateReturn", mock.Anything, mock.Anything, mock.Anything)
+			}
+			env.notification.AssertNotCalled(t, "SendOrderStatusUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetReturns_ListsOrderReturns(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	env.repo.On("GetOrderFromDB", mock.Anything, "8").Return(nil, sql.ErrNoRows)
+	returns.On("ListReturns", mock.Anything, 7).Return([]model.Return{
+		{ID: 12, OrderID: 7, Quantity: 1, Status: model.ReturnRejected},
+		{ID: 13, OrderID: 7, Quantity: 2, Status: model.ReturnApproved},
+	}, nil)
+
+	w := env.do(http.MethodGet, "/orders/7/returns", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list []model.Return
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, model.ReturnApproved, list[1].Status)
+
+	w = env.do(http.MethodGet, "/orders/8/returns", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReturnDecisions(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status string
+		note   string
+	}{
+		{"approve without a body", "approve", "", model.ReturnApproved, ""},
+		{"approve with a note", "approve", `{"note": "Ship it to the Berlin warehouse"}`, model.ReturnApproved, "Ship it to the Berlin warehouse"},
+		{"reject", "reject", `{"note": "Outside the return window"}`, model.ReturnRejected, "Outside the return window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, returns := setupReturnEnvironment()
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+			returns.On("GetReturn", mock.Anything, 7, 12).Return(&model.Return{ID: 12, OrderID: 7, Quantity: 2, Status: model.ReturnRequested}, nil)
+			returns.On("TransitionReturn", mock.Anything, mock.AnythingOfType("*model.Return"), model.ReturnRequested).Return(nil)
+			notified := expectReturnNotification(env, "return_"+tt.status)
+
+			w := env.do(http.MethodPost, "/admin/orders/7/returns/12/"+tt.path, tt.body)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var ret model.Return
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+			assert.Equal(t, tt.status, ret.Status)
+			assert.Equal(t, tt.note, ret.Note)
+			waitNotified(t, notified)
+		})
+	}
+}
+
+func TestReturnDecisions_Rejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		ret        *model.Return
+		getErr     error
+		transition error
+		status     int
+		code       problem.Code
+	}{
+		{"reject without a note", "reject", `{"note": "  "}`, &model.Return{ID: 12, Status: model.ReturnRequested}, nil, nil,
+			http.StatusBadRequest, problem.CodeValidation},
+		{"approve a rejected return", "approve", "", &model.Return{ID: 12, Status: model.ReturnRejected}, nil, nil,
+			http.StatusConflict, problem.CodeReturnNotAllowed},
+		{"receive a requested return", "receive", "", &model.Return{ID: 12, Status: model.ReturnRequested}, nil, nil,
+			http.StatusConflict, problem.CodeReturnNotAllowed},
+		{"return of another order", "approve", "", nil, sql.ErrNoRows, nil,
+			http.StatusNotFound, problem.CodeReturnNotFound},
+		{"decided concurrently", "approve", "", &model.Return{ID: 12, Status: model.ReturnRequested}, nil, sql.ErrNoRows,
+			http.StatusConflict, problem.CodeReturnNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, returns := setupReturnEnvironment()
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+			returns.On("GetReturn", mock.Anything, 7, 12).Return(tt.ret, tt.getErr)
+			returns.On("TransitionReturn", mock.Anything, mock.Anything, mock.Anything).Return(tt.transition)
+
+			w := env.do(http.MethodPost, "/admin/orders/7/returns/12/"+tt.path, tt.body)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.code, p.Code)
+			env.notification.AssertNotCalled(t, "SendOrderStatusUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestReturnDecisions_RequireStaff(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+
+	for _, path := range []string{"approve", "reject", "receive"} {
+		w := env.doAs(http.MethodPost, "/admin/orders/7/returns/12/"+path, `{"note": "ok"}`, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+	returns.AssertNotCalled(t, "GetReturn", mock.Anything, mock.Anything, mock.Anything)
+
+	// Without a configured token nobody is staff, whatever header is sent
+	env, returns = setupReturnEnvironment()
+	router := gin.New()
+	routes.SetupRoutes(router, env.oc, "")
+	for _, path := range []string{"approve", "reject", "receive"} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/orders/7/returns/12/"+path, strings.NewReader(`{"note": "ok"}`))
+		req.Header.Set(admin.Header, "")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+	returns.AssertNotCalled(t, "GetReturn", mock.Anything, mock.Anything, mock.Anything)
+	env.inventory.AssertNotCalled(t, "RestockReturn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReceiveReturn_RestocksAndRefunds(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	returns.On("GetReturn", mock.Anything, 7, 12).Return(&model.Return{ID: 12, OrderID: 7, Quantity: 3, Status: model.ReturnApproved}, nil)
+	var statuses []string
+	returns.On("TransitionReturn", mock.Anything, mock.AnythingOfType("*model.Return"), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		statuses = append(statuses, args.Get(2).(string)+"->"+args.Get(1).(*model.Return).Status)
+	})
+	env.inventory.On("RestockReturn", mock.Anything, 12, 7, 1, 3).Return(nil)
+	returns.On("RefundedReturns", mock.Anything, 7).Return(0, 0.0, nil)
+	// Three of the four units come to three quarters of the price
+	env.payment.On("RefundOrder", mock.Anything, 7, 75.0, "return-12").Return(&paymentmodel.Refund{ID: 4, Amount: 75}, nil)
+	received := expectReturnNotification(env, "return_received")
+	refunded := expectReturnNotification(env, "return_refunded")
+
+	w := env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var ret model.Return
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+	assert.Equal(t, model.ReturnRefunded, ret.Status)
+	assert.Equal(t, 75.0, ret.RefundAmount)
+	assert.Equal(t, []string{"approved->received", "received->refunded"}, statuses)
+	waitNotified(t, received)
+	waitNotified(t, refunded)
+	env.inventory.AssertExpectations(t)
+	env.payment.AssertExpectations(t)
+}
+
+func TestReceiveReturn_RetriesFailedRefund(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	ret := &model.Return{ID: 12, OrderID: 7, Quantity: 1, Status: model.ReturnApproved}
+	returns.On("GetReturn", mock.Anything, 7, 12).Return(ret, nil)
+	returns.On("TransitionReturn", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.inventory.On("RestockReturn", mock.Anything, 12, 7, 1, 1).Return(nil)
+	returns.On("RefundedReturns", mock.Anything, 7).Return(0, 0.0, nil)
+	env.payment.On("RefundOrder", mock.Anything, 7, 25.0, "return-12").Return(nil, errors.New("connection refused")).Once()
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, mock.Anything).Return(nil)
+
+	w := env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+	// The return stays received and the payment failure is reported
+	require.Equal(t, http.StatusBadGateway, w.Code, w.Body.String())
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, problem.CodePaymentFailed, p.Code)
+	assert.Equal(t, model.ReturnReceived, ret.Status)
+
+	// Receiving it again restocks and refunds without a second receipt
+	env.payment.On("RefundOrder", mock.Anything, 7, 25.0, "return-12").Return(&paymentmodel.Refund{ID: 4, Amount: 25}, nil)
+	w = env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, model.ReturnRefunded, ret.Status)
+	returns.AssertNumberOfCalls(t, "TransitionReturn", 2)
+	env.inventory.AssertNumberOfCalls(t, "RestockReturn", 2)
+}
+
+func TestReceiveReturn_RefundsWhatIsLeftOfThePrice(t *testing.T) {
+	tests := []struct {
+		name          string
+		total         float64
+		quantity      int
+		returned      int
+		refundedUnits int
+		refunded      float64
+		want          float64
+	}{
+		{name: "first of three units", total: 10, quantity: 3, returned: 1, want: 3.33},
+		{name: "second of three units", total: 10, quantity: 3, returned: 1, refundedUnits: 1, refunded: 3.33, want: 3.34},
+		{name: "last of three units", total: 10, quantity: 3, returned: 1, refundedUnits: 2, refunded: 6.67, want: 3.33},
+		// Rounding each unit on its own would refund 0.03 twice
+		{name: "last unit after rounding up", total: 0.05, quantity: 2, returned: 1, refundedUnits: 1, refunded: 0.03, want: 0.02},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, returns := setupReturnEnvironment()
+			order := deliveredOrder()
+			order.Quantity, order.TotalPrice = tt.quantity, tt.total
+			env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(order, nil)
+			returns.On("GetReturn", mock.Anything, 7, 12).Return(&model.Return{ID: 12, OrderID: 7, Quantity: tt.returned, Status: model.ReturnReceived}, nil)
+			returns.On("TransitionReturn", mock.Anything, mock.Anything, model.ReturnReceived).Return(nil)
+			returns.On("RefundedReturns", mock.Anything, 7).Return(tt.refundedUnits, tt.refunded, nil)
+			env.inventory.On("RestockReturn", mock.Anything, 12, 7, 1, tt.returned).Return(nil)
+			env.payment.On("RefundOrder", mock.Anything, 7, tt.want, "return-12").Return(&paymentmodel.Refund{ID: 4, Amount: tt.want}, nil)
+			env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "return_refunded").Return(nil)
+
+			w := env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			env.payment.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReceiveReturn_RestockFailureKeepsReturnReceived(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	returns.On("GetReturn", mock.Anything, 7, 12).Return(&model.Return{ID: 12, OrderID: 7, Quantity: 1, Status: model.ReturnReceived}, nil)
+	env.inventory.On("RestockReturn", mock.Anything, 12, 7, 1, 1).Return(errors.New("connection refused"))
+
+	w := env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	returns.AssertNotCalled(t, "TransitionReturn", mock.Anything, mock.Anything, mock.Anything)
+	env.payment.AssertNotCalled(t, "RefundOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReceiveReturn_UnpaidOrderRefundsNothing(t *testing.T) {
+	env, returns := setupReturnEnvironment()
+	env.repo.On("GetOrderFromDB", mock.Anything, "7").Return(deliveredOrder(), nil)
+	returns.On("GetReturn", mock.Anything, 7, 12).Return(&model.Return{ID: 12, OrderID: 7, Quantity: 2, Status: model.ReturnReceived}, nil)
+	returns.On("TransitionReturn", mock.Anything, mock.Anything, model.ReturnReceived).Return(nil)
+	env.inventory.On("RestockReturn", mock.Anything, 12, 7, 1, 2).Return(nil)
+	returns.On("RefundedReturns", mock.Anything, 7).Return(0, 0.0, nil)
+	notFound := problem.New(problem.CodePaymentNotFound, "Order 7 has no succeeded payment to refund")
+	env.payment.On("RefundOrder", mock.Anything, 7, 50.0, "return-12").Return(nil, fmt.Errorf("payment service: %w", notFound))
+	env.notification.On("SendOrderStatusUpdate", mock.Anything, 7, 3, "return_refunded").Return(nil)
+
+	w := env.do(http.MethodPost, "/admin/orders/7/returns/12/receive", "")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var ret model.Return
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ret))
+	assert.Equal(t, model.ReturnRefunded, ret.Status)
+	assert.Zero(t, ret.RefundAmount)
+}
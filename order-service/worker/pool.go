@@ -2,13 +2,17 @@ package worker
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"go-microservices/order-service/model"
 )
 
+// active tracks every started pool until its workers exit, so shutdown can wait for them
+var active sync.WaitGroup
+
 // Job represents a task to be processed
 type Job struct {
 	Order model.Order
@@ -30,9 +34,9 @@ type Pool struct {
 	cancel      context.CancelFunc
 }
 
-// NewPool creates a new worker pool
-func NewPool(numWorkers int, queueSize int) *Pool {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewPool creates a new worker pool that stops when ctx is cancelled
+func NewPool(ctx context.Context, numWorkers int, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(ctx)
 	return &Pool{
 		numWorkers:  numWorkers,
 		jobQueue:    make(chan Job, queueSize),
@@ -43,9 +47,10 @@ func NewPool(numWorkers int, queueSize int) *Pool {
 	}
 }
 
-// Start initializes the worker pool
-func (p *Pool) Start(processFunc func(Job) Result) {
+// Start initializes the worker pool. processFunc receives the pool's context.
+func (p *Pool) Start(processFunc func(context.Context, Job) Result) {
 	// Start workers
+	active.Add(1)
 	var wg sync.WaitGroup
 	for i := 0; i < p.numWorkers; i++ {
 		wg.Add(1)
@@ -59,34 +64,41 @@ func (p *Pool) Start(processFunc func(Job) Result) {
 	go func() {
 		wg.Wait()
 		close(p.resultQueue)
+		active.Done()
 		p.done <- true
 	}()
 }
 
 // worker processes jobs from the job queue
-func (p *Pool) worker(id int, processFunc func(Job) Result) {
-	log.Printf("Worker %d starting\n", id)
+func (p *Pool) worker(id int, processFunc func(context.Context, Job) Result) {
+	slog.Debug("Worker starting", "worker", id)
 	for {
 		select {
 		case job, ok := <-p.jobQueue:
 			if !ok {
-				log.Printf("Worker %d shutting down\n", id)
+				slog.Debug("Worker shutting down", "worker", id)
 				return
 			}
 			// Process the job and send the result
-			result := processFunc(job)
+			result := processFunc(p.ctx, job)
 			p.resultQueue <- result
 
 		case <-p.ctx.Done():
-			log.Printf("Worker %d cancelled\n", id)
+			slog.Debug("Worker cancelled", "worker", id)
 			return
 		}
 	}
 }
 
-// Submit adds a job to the queue
-func (p *Pool) Submit(job Job) {
-	p.jobQueue <- job
+// Submit adds a job to the queue. It returns false without queueing the job
+// once the pool has been stopped.
+func (p *Pool) Submit(job Job) bool {
+	select {
+	case p.jobQueue <- job:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
 }
 
 // Results returns the channel for receiving results
@@ -94,22 +106,30 @@ func (p *Pool) Results() <-chan Result {
 	return p.resultQueue
 }
 
-// Stop gracefully shuts down the worker pool
+// Stop gracefully shuts down the worker pool. The job queue is left open so
+// that a concurrent Submit cannot send on a closed channel.
 func (p *Pool) Stop() {
 	p.cancel()
-	close(p.jobQueue)
 	<-p.done
 }
 
-// ProcessBatch handles a batch of orders with timeout
-func ProcessBatch(orders []model.Order, numWorkers int, timeout time.Duration) []Result {
+// ProcessBatch handles a batch of orders, giving up when the timeout passes
+// or ctx is cancelled
+func ProcessBatch(ctx context.Context, orders []model.Order, numWorkers int, timeout time.Duration) []Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	// Create a worker pool with buffer size equal to number of orders
-	pool := NewPool(numWorkers, len(orders))
+	pool := NewPool(ctx, numWorkers, len(orders))
 
 	// Start the pool with the processing function
-	pool.Start(func(job Job) Result {
+	pool.Start(func(ctx context.Context, job Job) Result {
 		// Simulate processing time (replace with actual processing)
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return Result{OrderID: job.Order.ID, Error: ctx.Err()}
+		}
 		return Result{
 			OrderID: job.Order.ID,
 			Error:   nil,
@@ -119,26 +139,47 @@ func ProcessBatch(orders []model.Order, numWorkers int, timeout time.Duration) [
 	// Submit all orders to the pool
 	go func() {
 		for _, order := range orders {
-			pool.Submit(Job{Order: order})
+			if !pool.Submit(Job{Order: order}) {
+				return
+			}
 		}
 	}()
 
-	// Collect results with timeout
+	// Collect results until the batch is done or abandoned
 	results := make([]Result, 0, len(orders))
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
-
-	for i := 0; i < len(orders); i++ {
+collect:
+	for len(results) < len(orders) {
 		select {
-		case result := <-pool.Results():
+		case result, ok := <-pool.Results():
+			if !ok {
+				// Workers have exited because the pool's context ended
+				break collect
+			}
 			results = append(results, result)
-		case <-timer.C:
-			log.Printf("Batch processing timeout after %v\n", timeout)
-			pool.Stop()
-			return results
+		case <-ctx.Done():
+			break collect
 		}
 	}
+	if len(results) < len(orders) {
+		slog.WarnContext(ctx, "Batch processing stopped early", "processed", len(results), "orders", len(orders), "error", ctx.Err())
+	}
 
 	pool.Stop()
 	return results
 }
+
+// Wait blocks until every started pool has finished, or ctx is done
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker pools still running: %w", ctx.Err())
+	}
+}
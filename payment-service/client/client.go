@@ -0,0 +1,80 @@
+// Package client is the typed Go client of the payment service. Its request
+// and response types are the service's own, and the consumers' contract tests
+// run it against the service's handlers, so the two cannot drift apart.
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/apiclient"
+	"go-microservices/pkg/listing"
+)
+
+// Client calls the payment service. Failed calls return the service's
+// *problem.Problem when it answered with an error.
+type Client struct {
+	api *apiclient.Client
+}
+
+// New creates a client for the payment service at baseURL. A nil httpClient
+// selects one that forwards request IDs and trace context.
+func New(baseURL string, httpClient *http.Client) *Client {
+	return &Client{api: apiclient.New(baseURL, httpClient)}
+}
+
+// Create creates a Stripe payment intent for an order. It is not idempotent:
+// repeating it creates a second intent.
+func (c *Client) Create(ctx context.Context, req model.PaymentRequest) (*model.PaymentResponse, error) {
+	var resp model.PaymentResponse
+	if err := c.api.Do(ctx, http.MethodPost, "/payments/", req, &resp, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Get returns the payment with the given ID
+func (c *Client) Get(ctx context.Context, id int) (*model.Payment, error) {
+	var payment model.Payment
+	if err := c.api.Do(ctx, http.MethodGet, fmt.Sprintf("/payments/%d", id), nil, &payment, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// ListByOrder returns an order's payments, newest first. It reads the first
+// page only, which holds listing.MaxLimit payments.
+func (c *Client) ListByOrder(ctx context.Context, orderID int) ([]model.Payment, error) {
+	var payments []model.Payment
+	path := fmt.Sprintf("/payments/order/%d?limit=%d", orderID, listing.MaxLimit)
+	if err := c.api.Do(ctx, http.MethodGet, path, nil, &payments, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// CancelOrder voids an order's unpaid payment intents and refunds its paid
+// ones, returning all of the order's payments. It is safe to repeat.
+func (c *Client) CancelOrder(ctx context.Context, orderID int, reason string) ([]model.Payment, error) {
+	var payments []model.Payment
+	path := fmt.Sprintf("/payments/order/%d/cancel", orderID)
+	req := model.PaymentCancelRequest{Reason: reason}
+	if err := c.api.Do(ctx, http.MethodPost, path, req, &payments, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// Refund refunds part of an order's succeeded payments. It is idempotent per
+// req.Reference: refunding a reference again returns the existing refund. An
+// order without a succeeded payment is a PAYMENT_NOT_FOUND problem.
+func (c *Client) Refund(ctx context.Context, orderID int, req model.RefundRequest) (*model.Refund, error) {
+	var refund model.Refund
+	path := fmt.Sprintf("/payments/order/%d/refunds", orderID)
+	if err := c.api.Do(ctx, http.MethodPost, path, req, &refund, http.StatusCreated, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
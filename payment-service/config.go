@@ -0,0 +1,33 @@
+package main
+
+import "go-microservices/pkg/config"
+
+// Config holds the payment service settings
+type Config struct {
+	HTTP     config.HTTP
+	Database config.Database
+	Admin    config.Admin
+	Service  config.Service
+	Health   config.Health
+	Shutdown config.Shutdown
+	Stripe   StripeConfig
+}
+
+// StripeConfig holds the Stripe API credentials
+type StripeConfig struct {
+	SecretKey string `env:"STRIPE_SECRET_KEY" required:"true" secret:"true"`
+}
+
+// loadConfig reads the payment service settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:     config.HTTP{Port: 8084},
+		Database: config.DefaultDatabase("payment_db"),
+		Health:   config.DefaultHealth(),
+		Shutdown: config.DefaultShutdown(),
+	}
+	// The payment database is published on its own port for local development
+	cfg.Database.Port = 5436
+	config.MustLoad("payment-service", &cfg)
+	return cfg
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/refund"
+)
+
+// CancelOrderPayments settles the payments of a cancelled order: intents that
+// have not been paid are cancelled and paid ones are refunded. Payments that
+// are already settled are left alone, so a failed call can simply be retried.
+func (pc *PaymentController) CancelOrderPayments(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("orderId"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "Order ID must be an integer")
+		return
+	}
+
+	var req model.PaymentCancelRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	rows, err := pc.db.QueryContext(ctx,
+		"SELECT "+paymentListing.Columns+" FROM payments WHERE order_id = $1 ORDER BY id", orderID)
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve payments", err)
+		return
+	}
+	payments := []model.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			problem.Internal(c, "Failed to retrieve payments", err)
+			return
+		}
+		payments = append(payments, payment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		problem.Internal(c, "Failed to retrieve payments", err)
+		return
+	}
+
+	for i, payment := range payments {
+		switch payment.Status {
+		case model.PaymentStatusCanceled, model.PaymentStatusFailed, model.PaymentStatusRefunded:
+			continue
+		}
+
+		status, err := settle(ctx, payment.StripePaymentID, req.Reason)
+		if err != nil {
+			stripeError(c, "Failed to cancel payment", err)
+			return
+		}
+
+		payments[i].Status = status
+		payments[i].UpdatedAt = time.Now()
+		_, err = pc.db.ExecContext(ctx, "UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3",
+			payments[i].Status, payments[i].UpdatedAt, payment.ID)
+		if err != nil {
+			problem.Internal(c, "Failed to update payment", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// settle cancels or refunds a payment intent depending on whether it was paid
+// and returns the payment's new status. Refunds use an idempotency key so
+// that a retry after a lost response does not refund twice.
+func settle(ctx context.Context, intentID, reason string) (string, error) {
+	pi, err := paymentintent.Get(intentID, &stripe.PaymentIntentParams{
+		Params: stripe.Params{Context: ctx},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch pi.Status {
+	case stripe.PaymentIntentStatusCanceled:
+		return model.PaymentStatusCanceled, nil
+	case stripe.PaymentIntentStatusSucceeded:
+		params := &stripe.RefundParams{
+			Params:        stripe.Params{Context: ctx, IdempotencyKey: stripe.String("refund-" + pi.ID)},
+			PaymentIntent: stripe.String(pi.ID),
+		}
+		if reason != "" && reason != string(stripe.PaymentIntentCancellationReasonAbandoned) {
+			params.Reason = stripe.String(reason)
+		}
+		if _, err := refund.New(params); err != nil {
+			return "", err
+		}
+		return model.PaymentStatusRefunded, nil
+	default:
+		params := &stripe.PaymentIntentCancelParams{Params: stripe.Params{Context: ctx}}
+		if reason != "" {
+			params.CancellationReason = stripe.String(reason)
+		}
+		if _, err := paymentintent.Cancel(pi.ID, params); err != nil {
+			return "", err
+		}
+		return model.PaymentStatusCanceled, nil
+	}
+}
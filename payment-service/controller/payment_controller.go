@@ -2,13 +2,19 @@ package controller
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"go-microservices/payment-service/model"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v76"
@@ -19,12 +25,14 @@ type PaymentController struct {
 	db *sql.DB
 }
 
-func NewPaymentController(db *sql.DB) *PaymentController {
+func NewPaymentController(db *sql.DB, stripeKey string) *PaymentController {
 	// Initialize Stripe
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-	if stripe.Key == "" {
-		log.Fatal("STRIPE_SECRET_KEY is required but not set")
-	}	
+	stripe.Key = stripeKey
+	// Trace Stripe API calls as part of the request that made them
+	stripe.SetHTTPClient(&http.Client{
+		Transport: tracing.Transport(http.DefaultTransport),
+		Timeout:   80 * time.Second,
+	})
 	return &PaymentController{
 		db: db,
 	}
@@ -33,16 +41,19 @@ func NewPaymentController(db *sql.DB) *PaymentController {
 // CreatePayment creates a new payment intent with Stripe
 func (pc *PaymentController) CreatePayment(c *gin.Context) {
 	var req model.PaymentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &req) {
 		return
 	}
+	// Currency codes are accepted in either case; Stripe's are lower case
+	req.Currency = strings.ToLower(req.Currency)
 
-	// Convert amount to cents for Stripe (Stripe expects amounts in cents)
-	amountCents := int64(req.Amount * 100)
+	// Convert amount to cents for Stripe (Stripe expects amounts in cents),
+	// rounding so that e.g. 19.99 is not truncated to 1998
+	amountCents := int64(math.Round(req.Amount * 100))
 
 	// Create payment intent with Stripe
 	params := &stripe.PaymentIntentParams{
+		Params:   stripe.Params{Context: c.Request.Context()},
 		Amount:   stripe.Int64(amountCents),
 		Currency: stripe.String(req.Currency),
 		Metadata: map[string]string{
@@ -53,7 +64,7 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment intent: " + err.Error()})
+		stripeError(c, "Failed to create payment intent", err)
 		return
 	}
 
@@ -76,10 +87,10 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 		RETURNING id
 	`
 
-	err = pc.db.QueryRow(query, payment.OrderID, payment.CustomerID, payment.Amount, payment.Currency, 
+	err = pc.db.QueryRowContext(c.Request.Context(), query, payment.OrderID, payment.CustomerID, payment.Amount, payment.Currency, 
 		payment.Status, payment.StripePaymentID, payment.StripeClientSecret, payment.CreatedAt, payment.UpdatedAt).Scan(&payment.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payment: " + err.Error()})
+		problem.Internal(c, "Failed to save payment", err)
 		return
 	}
 
@@ -92,18 +103,43 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// stripeError answers a failed Stripe call. Card errors carry a message meant for
+// the customer and become PAYMENT_DECLINED; anything else is logged and hidden.
+func stripeError(c *gin.Context, msg string, err error) {
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) {
+		problem.Internal(c, msg, err)
+		return
+	}
+
+	switch {
+	case stripeErr.Type == stripe.ErrorTypeCard:
+		p := problem.New(problem.CodePaymentDeclined, stripeErr.Msg)
+		if stripeErr.DeclineCode != "" {
+			p.With("decline_code", stripeErr.DeclineCode)
+		}
+		problem.Write(c, p)
+	case stripeErr.Type == stripe.ErrorTypeInvalidRequest && stripeErr.HTTPStatusCode == http.StatusNotFound:
+		problem.Abort(c, problem.CodePaymentNotFound, "The payment intent does not exist")
+	default:
+		slog.ErrorContext(c.Request.Context(), msg, "error", err, "stripe_request_id", stripeErr.RequestID)
+		problem.Abort(c, problem.CodePaymentFailed, "The payment provider could not process the request")
+	}
+}
+
 // ConfirmPayment confirms a payment and updates the status
 func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 	var req model.PaymentConfirmRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &req) {
 		return
 	}
 
 	// Retrieve payment intent from Stripe
-	pi, err := paymentintent.Get(req.PaymentIntentID, nil)
+	pi, err := paymentintent.Get(req.PaymentIntentID, &stripe.PaymentIntentParams{
+		Params: stripe.Params{Context: c.Request.Context()},
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment intent: " + err.Error()})
+		stripeError(c, "Failed to retrieve payment intent", err)
 		return
 	}
 
@@ -120,20 +156,27 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 		status = model.PaymentStatusFailed
 	}
 
+	// A refunded or cancelled payment is settled: Stripe still reports a
+	// refunded intent as succeeded, which must not make it refundable again
 	query := `
 		UPDATE payments 
 		SET status = $1, payment_method = $2, updated_at = $3
-		WHERE stripe_payment_id = $4
+		WHERE stripe_payment_id = $4 AND status NOT IN ($5, $6)
 		RETURNING id, order_id, customer_id, amount, currency, status, stripe_payment_id, payment_method, created_at, updated_at
 	`
 
 	var payment model.Payment
-	err = pc.db.QueryRow(query, status, string(pi.PaymentMethod.Type), time.Now(), pi.ID).Scan(
+	err = pc.db.QueryRowContext(c.Request.Context(), query, status, string(pi.PaymentMethod.Type), time.Now(), pi.ID,
+		model.PaymentStatusRefunded, model.PaymentStatusCanceled).Scan(
 		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
 		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
 	)
+	if err == sql.ErrNoRows {
+		pc.notConfirmed(c, pi.ID)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		problem.Internal(c, "Failed to update payment", err)
 		return
 	}
 
@@ -145,12 +188,29 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// notConfirmed explains why confirming the payment of a payment intent
+// updated nothing: the payment doesn't exist or is already settled
+func (pc *PaymentController) notConfirmed(c *gin.Context, paymentIntentID string) {
+	var id int
+	var status string
+	err := pc.db.QueryRowContext(c.Request.Context(), "SELECT id, status FROM payments WHERE stripe_payment_id = $1", paymentIntentID).
+		Scan(&id, &status)
+	switch {
+	case err == sql.ErrNoRows:
+		problem.Abort(c, problem.CodePaymentNotFound, "No payment uses this payment intent")
+	case err != nil:
+		problem.Internal(c, "Failed to update payment", err)
+	default:
+		problem.Abort(c, problem.CodeConflict, fmt.Sprintf("Payment %d is %s and can no longer be confirmed", id, status))
+	}
+}
+
 // GetPayment retrieves a payment by ID
 func (pc *PaymentController) GetPayment(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		problem.Abort(c, problem.CodeInvalidID, "Payment ID must be an integer")
 		return
 	}
 
@@ -161,59 +221,77 @@ func (pc *PaymentController) GetPayment(c *gin.Context) {
 	`
 
 	var payment model.Payment
-	err = pc.db.QueryRow(query, id).Scan(
+	err = pc.db.QueryRowContext(c.Request.Context(), query, id).Scan(
 		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
 		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			problem.Abort(c, problem.CodePaymentNotFound, fmt.Sprintf("Payment %s does not exist", idParam))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment: " + err.Error()})
+		problem.Internal(c, "Failed to retrieve payment", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, payment)
 }
 
-// GetPaymentsByOrder retrieves all payments for an order
+// paymentListing describes how an order's payments are filtered, sorted and paged
+var paymentListing = &listing.Spec[model.Payment]{
+	Table: "payments",
+	Columns: `id, order_id, customer_id, amount, currency, status, stripe_payment_id,
+		COALESCE(payment_method, '') as payment_method, created_at, updated_at`,
+	Key:   "id",
+	KeyOf: func(p model.Payment) int64 { return int64(p.ID) },
+	Filters: []listing.Filter{
+		{Param: "status", Column: "status", Op: listing.Eq, Kind: listing.String},
+	},
+	Sorts: map[string]listing.Field[model.Payment]{
+		"id":         {Column: "id", Kind: listing.Int, Value: func(p model.Payment) interface{} { return p.ID }},
+		"created_at": {Column: "created_at", Kind: listing.Time, Value: func(p model.Payment) interface{} { return p.CreatedAt }},
+		"amount":     {Column: "amount", Kind: listing.Float, Value: func(p model.Payment) interface{} { return p.Amount }},
+	},
+	DefaultSort: "-created_at",
+}
+
+// PaymentListParams describes the query parameters GetPaymentsByOrder accepts
+func PaymentListParams() []listing.Param {
+	return paymentListing.Params()
+}
+
+// scanPayment reads a payment row selected with paymentListing's columns
+func scanPayment(rows *sql.Rows) (model.Payment, error) {
+	var payment model.Payment
+	err := rows.Scan(
+		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+	return payment, err
+}
+
+// GetPaymentsByOrder retrieves a page of payments for an order, newest first unless sorted otherwise
 func (pc *PaymentController) GetPaymentsByOrder(c *gin.Context) {
 	orderIDParam := c.Param("orderId")
 	orderID, err := strconv.Atoi(orderIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		problem.Abort(c, problem.CodeInvalidID, "Order ID must be an integer")
 		return
 	}
 
-	query := `
-		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,
-		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
-		FROM payments WHERE order_id = $1 ORDER BY created_at DESC
-	`
+	req, p := paymentListing.Parse(c.Request.URL.Query())
+	if p != nil {
+		problem.Write(c, p)
+		return
+	}
+	req.Where("order_id", orderID)
 
-	rows, err := pc.db.Query(query, orderID)
+	page, err := listing.List(c.Request.Context(), pc.db, req, scanPayment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments: " + err.Error()})
+		problem.Internal(c, "Failed to retrieve payments", err)
 		return
 	}
-	defer rows.Close()
-
-	var payments []model.Payment
-	for rows.Next() {
-		var payment model.Payment
-		err := rows.Scan(
-			&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
-			&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan payment: " + err.Error()})
-			return
-		}
-		payments = append(payments, payment)
-	}
-
-	c.JSON(http.StatusOK, payments)
+	listing.Respond(c, page)
 }
 
 // HealthCheck returns the health status of the payment service
@@ -224,12 +302,3 @@ func (pc *PaymentController) HealthCheck(c *gin.Context) {
 		"time":    time.Now().UTC(),
 	})
 }
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
\ No newline at end of file
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/refund"
+)
+
+// refundColumns are the columns scanRefund reads
+const refundColumns = "id, payment_id, order_id, amount, currency, COALESCE(reason, ''), reference, COALESCE(stripe_refund_id, ''), created_at"
+
+// scanRefund reads a refund row selected with refundColumns
+func scanRefund(row *sql.Row) (*model.Refund, error) {
+	var r model.Refund
+	err := row.Scan(&r.ID, &r.PaymentID, &r.OrderID, &r.Amount, &r.Currency, &r.Reason, &r.Reference, &r.StripeRefundID, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// refundByReference returns the refund made for reference
+func (pc *PaymentController) refundByReference(ctx context.Context, reference string) (*model.Refund, error) {
+	return scanRefund(pc.db.QueryRowContext(ctx,
+		"SELECT "+refundColumns+" FROM refunds WHERE reference = $1", reference))
+}
+
+// cents converts an amount to the smallest currency unit Stripe counts in
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// RefundOrder refunds part of an order's succeeded payments, from the first
+// payment with enough left to refund. A reference is refunded once: refunding
+// it again returns its refund with 200 instead of 201, so callers can retry.
+// A payment refunded in full becomes refunded.
+func (pc *PaymentController) RefundOrder(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("orderId"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "Order ID must be an integer")
+		return
+	}
+
+	var req model.RefundRequest
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	existing, err := pc.refundByReference(ctx, req.Reference)
+	if err == nil {
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	if err != sql.ErrNoRows {
+		problem.Internal(c, "Failed to refund payment", err)
+		return
+	}
+
+	tx, err := pc.db.BeginTx(ctx, nil)
+	if err != nil {
+		problem.Internal(c, "Failed to refund payment", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the order's paid payments so that concurrent refunds can't give
+	// back more than was paid
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+paymentListing.Columns+" FROM payments WHERE order_id = $1 AND status = $2 ORDER BY id FOR UPDATE",
+		orderID, model.PaymentStatusSucceeded)
+	if err != nil {
+		problem.Internal(c, "Failed to retrieve payments", err)
+		return
+	}
+	var payments []model.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			problem.Internal(c, "Failed to retrieve payments", err)
+			return
+		}
+		payments = append(payments, payment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		problem.Internal(c, "Failed to retrieve payments", err)
+		return
+	}
+	if len(payments) == 0 {
+		problem.Abort(c, problem.CodePaymentNotFound, fmt.Sprintf("Order %d has no succeeded payment to refund", orderID))
+		return
+	}
+
+	var payment model.Payment
+	var remaining int64
+	for _, p := range payments {
+		var refunded float64
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1", p.ID).Scan(&refunded)
+		if err != nil {
+			problem.Internal(c, "Failed to retrieve refunds", err)
+			return
+		}
+		if left := cents(p.Amount) - cents(refunded); left >= cents(req.Amount) {
+			payment, remaining = p, left
+			break
+		}
+	}
+	if payment.ID == 0 {
+		problem.Abort(c, problem.CodeConflict, fmt.Sprintf("No payment of order %d has %.2f left to refund", orderID, req.Amount))
+		return
+	}
+
+	params := &stripe.RefundParams{
+		Params:        stripe.Params{Context: ctx, IdempotencyKey: stripe.String("refund-" + req.Reference)},
+		PaymentIntent: stripe.String(payment.StripePaymentID),
+		Amount:        stripe.Int64(cents(req.Amount)),
+	}
+	if req.Reason != "" {
+		params.Reason = stripe.String(req.Reason)
+	}
+	stripeRefund, err := refund.New(params)
+	if err != nil {
+		stripeError(c, "Failed to refund payment", err)
+		return
+	}
+
+	result, err := scanRefund(tx.QueryRowContext(ctx,
+		`INSERT INTO refunds (payment_id, order_id, amount, currency, reason, reference, stripe_refund_id, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NOW())
+		RETURNING `+refundColumns,
+		payment.ID, orderID, req.Amount, payment.Currency, req.Reason, req.Reference, stripeRefund.ID))
+	if err != nil {
+		problem.Internal(c, "Failed to save refund", err)
+		return
+	}
+	if remaining == cents(req.Amount) {
+		_, err = tx.ExecContext(ctx, "UPDATE payments SET status = $1, updated_at = NOW() WHERE id = $2",
+			model.PaymentStatusRefunded, payment.ID)
+		if err != nil {
+			problem.Internal(c, "Failed to update payment", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		problem.Internal(c, "Failed to save refund", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
@@ -1,83 +1,61 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
-	"os"
+	"embed"
+	"log/slog"
+
+	"go-microservices/pkg/config"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/tracing"
 
 	_ "github.com/lib/pq"
 )
 
+// migrations holds the versioned up/down SQL files for this service's schema
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
 var db *sql.DB
 
 // GetDB returns the database connection
-func GetDB() *sql.DB {
+func GetDB(cfg config.Database) *sql.DB {
 	if db != nil {
 		return db
 	}
 
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5436") // Different port for payment service
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "payment_db")
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
 	var err error
-	db, err = sql.Open("postgres", psqlInfo)
+	db, err = tracing.OpenDB("postgres", cfg.DSN())
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		logging.Fatal("Failed to open database", "error", err)
 	}
 
 	err = db.Ping()
 	if err != nil {
-		log.Fatal("Failed to ping database: ", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 
-	log.Println("Successfully connected to payment database")
+	slog.Info("Successfully connected to payment database")
 	return db
 }
 
-// InitSchema creates the necessary tables
-func InitSchema(database *sql.DB) {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS payments (
-		id SERIAL PRIMARY KEY,
-		order_id INTEGER NOT NULL,
-		customer_id INTEGER NOT NULL,
-		amount DECIMAL(10, 2) NOT NULL,
-		currency VARCHAR(3) NOT NULL DEFAULT 'USD',
-		status VARCHAR(50) NOT NULL,
-		stripe_payment_id VARCHAR(255),
-		stripe_client_secret VARCHAR(255),
-		payment_method VARCHAR(50),
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
-	CREATE INDEX IF NOT EXISTS idx_payments_customer_id ON payments(customer_id);
-	CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
-	CREATE INDEX IF NOT EXISTS idx_payments_stripe_payment_id ON payments(stripe_payment_id);
-	`
-
-	_, err := database.Exec(createTableSQL)
+// NewMigrator returns the migrator for the embedded payments schema migrations
+func NewMigrator(database *sql.DB) *migrate.Migrator {
+	m, err := migrate.New(database, "payment-service", migrations)
 	if err != nil {
-		log.Fatal("Failed to create payments table: ", err)
+		logging.Fatal("Failed to load migrations", "error", err)
 	}
-
-	log.Println("Payment database schema initialized successfully")
+	return m
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Migrate applies pending schema migrations
+func Migrate(database *sql.DB) {
+	if err := NewMigrator(database).Up(context.Background()); err != nil {
+		logging.Fatal("Failed to migrate database", "error", err)
 	}
-	return value
-}
\ No newline at end of file
+
+	slog.Info("Database schema is up to date")
+}
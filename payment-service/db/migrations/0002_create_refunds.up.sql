@@ -0,0 +1,16 @@
+-- Partial refunds of succeeded payments, e.g. for returned items. Reference
+-- identifies what is refunded so that a refund is made once.
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES payments(id),
+    order_id INTEGER NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(50),
+    reference VARCHAR(100) NOT NULL UNIQUE,
+    stripe_refund_id VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
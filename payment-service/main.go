@@ -1,39 +1,88 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 
 	"go-microservices/payment-service/controller"
 	"go-microservices/payment-service/db"
 	"go-microservices/payment-service/routes"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/lifecycle"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	logging.Init("payment-service")
+	cfg := loadConfig()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "payment-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
 	// Initialize database connection
-	database := db.GetDB()
-	defer database.Close()
+	database := db.GetDB(cfg.Database)
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// "migrate <command>" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := migrate.Main(context.Background(), db.NewMigrator(database), os.Args[2:])
+		database.Close()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		db.Migrate(database)
+	} else {
+		slog.Info("Skipping schema migrations", "reason", "DB_AUTO_MIGRATE=false")
+	}
 
 	// Create payment controller
-	paymentController := controller.NewPaymentController(database)
+	paymentController := controller.NewPaymentController(database, cfg.Stripe.SecretKey)
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware(), tracing.Middleware("payment-service"), logging.Middleware(), logging.AccessLog())
+	router.NoRoute(problem.NoRoute)
+
+	// Add log level admin endpoint
+	logging.RegisterAdminRoutes(router, cfg.Admin.Token)
+
+	// Add liveness and readiness probes
+	checker := health.NewChecker("payment-service", cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: health.SQL(database)})
+	checker.RegisterRoutes(router)
 
 	// Add prometheus metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Setup routes
-	routes.SetupRoutes(router, paymentController)
+	routes.SetupRoutes(router, paymentController, cfg.Service.Token)
+
+	// Serve the OpenAPI document and its reference page
+	openapi.Register(router, routes.Spec())
 
 	// Start server
-	log.Println("Payment Service starting on port 8084...")
-	if err := router.Run(":8084"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	slog.Info("Payment Service starting", "port", cfg.HTTP.Port)
+	server := lifecycle.NewServer(cfg.HTTP.Addr(), router, cfg.Shutdown)
+	server.OnDrain(func() { checker.SetDraining(true) })
+	server.OnShutdown("database", lifecycle.Closer(database.Close))
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(); err != nil {
+		logging.Fatal("Server shutdown failed", "error", err)
 	}
 }
\ No newline at end of file
@@ -21,15 +21,42 @@ type Payment struct {
 
 // PaymentRequest represents a payment creation request
 type PaymentRequest struct {
-	OrderID    int     `json:"order_id" binding:"required"`
-	CustomerID int     `json:"customer_id" binding:"required"`
-	Amount     float64 `json:"amount" binding:"required,min=0.01"`
-	Currency   string  `json:"currency" binding:"required"`
+	OrderID    int     `json:"order_id" binding:"required,gt=0"`
+	CustomerID int     `json:"customer_id" binding:"required,gt=0"`
+	Amount     float64 `json:"amount" binding:"required,min=0.01,max=999999.99"` // Stripe's largest charge
+	Currency   string  `json:"currency" binding:"required,currency"`
 }
 
 // PaymentConfirmRequest represents a payment confirmation request
 type PaymentConfirmRequest struct {
-	PaymentIntentID string `json:"payment_intent_id" binding:"required"`
+	PaymentIntentID string `json:"payment_intent_id" binding:"required,startswith=pi_"`
+}
+
+// PaymentCancelRequest settles the payments of a cancelled order. Reason is
+// passed to Stripe; abandoned applies to cancellations only, not refunds.
+type PaymentCancelRequest struct {
+	Reason string `json:"reason" binding:"omitempty,oneof=duplicate fraudulent requested_by_customer abandoned"`
+}
+
+// RefundRequest refunds part of an order's payments. Reference names what is
+// refunded, e.g. an order return; a reference is refunded once.
+type RefundRequest struct {
+	Amount    float64 `json:"amount" binding:"required,min=0.01,max=999999.99"`
+	Reference string  `json:"reference" binding:"required,notblank,max=100"`
+	Reason    string  `json:"reason" binding:"omitempty,oneof=duplicate fraudulent requested_by_customer"`
+}
+
+// Refund is money given back from a succeeded payment
+type Refund struct {
+	ID             int       `json:"id"`
+	PaymentID      int       `json:"payment_id"`
+	OrderID        int       `json:"order_id"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	Reason         string    `json:"reason,omitempty"`
+	Reference      string    `json:"reference"`
+	StripeRefundID string    `json:"stripe_refund_id"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // PaymentResponse represents a payment response
@@ -45,4 +72,5 @@ const (
 	PaymentStatusSucceeded = "succeeded"
 	PaymentStatusFailed    = "failed"
 	PaymentStatusCanceled  = "canceled"
+	PaymentStatusRefunded  = "refunded"
 )
\ No newline at end of file
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/servicetoken"
+)
+
+// Spec documents every route the payment service serves
+func Spec() *openapi.Document {
+	status := struct {
+		Status  string    `json:"status"`
+		Service string    `json:"service"`
+		Time    time.Time `json:"time"`
+	}{}
+	stripeErrors := []int{http.StatusBadRequest, http.StatusPaymentRequired, http.StatusNotFound, http.StatusBadGateway}
+	service := []openapi.Parameter{servicetoken.Param()}
+
+	return openapi.New("Payment Service API", "1.0", "Payments for orders, taken through Stripe payment intents.").
+		Tag("payments", "Payment intents and their status").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "POST", Path: "/payments/", ID: "createPayment", Summary: "Create a Stripe payment intent for an order", Tag: "payments",
+				Body: model.PaymentRequest{}, Status: http.StatusCreated, Response: model.PaymentResponse{}, Errors: stripeErrors},
+			openapi.Route{Method: "POST", Path: "/payments/confirm", ID: "confirmPayment", Summary: "Update a payment from its Stripe payment intent", Tag: "payments",
+				Body: model.PaymentConfirmRequest{}, Response: model.PaymentResponse{}, Errors: append(stripeErrors, http.StatusConflict)},
+			openapi.Route{Method: "GET", Path: "/payments/:id", ID: "getPayment", Summary: "Get a payment", Tag: "payments",
+				Response: model.Payment{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+			openapi.Route{Method: "GET", Path: "/payments/order/:orderId", ID: "listOrderPayments", Summary: "List an order's payments", Tag: "payments",
+				Params: openapi.ListParams(controller.PaymentListParams()), Response: []model.Payment{}, Headers: openapi.ListHeaders,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "POST", Path: "/payments/order/:orderId/cancel", ID: "cancelOrderPayments", Summary: "Void or refund an order's payments", Tag: "payments",
+				Params: service, Body: model.PaymentCancelRequest{}, Response: []model.Payment{},
+				Errors: append(stripeErrors, http.StatusUnauthorized)},
+			openapi.Route{Method: "POST", Path: "/payments/order/:orderId/refunds", ID: "refundOrderPayment", Summary: "Refund part of an order's payments", Tag: "payments",
+				Params: service, Body: model.RefundRequest{}, Status: http.StatusCreated, Response: model.Refund{},
+				Responses: map[int]interface{}{http.StatusOK: model.Refund{}},
+				Errors:    append(stripeErrors, http.StatusUnauthorized, http.StatusConflict)},
+			openapi.Route{Method: "GET", Path: "/health", ID: "getHealth", Summary: "Service status", Tag: "operations",
+				Response: status},
+			openapi.Metrics,
+		).
+		Add(health.Routes()...).
+		Add(logging.AdminRoutes()...)
+}
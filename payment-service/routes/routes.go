@@ -2,15 +2,19 @@ package routes
 
 import (
 	"go-microservices/payment-service/controller"
+	"go-microservices/pkg/servicetoken"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures the payment service routes
-func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentController) {
+// SetupRoutes configures the payment service routes. Cancelling and refunding
+// an order's payments require serviceToken: only the order service does that.
+func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentController, serviceToken string) {
 	// Health check
 	router.GET("/health", paymentController.HealthCheck)
 
+	service := servicetoken.Authorize(serviceToken)
+
 	// Payment routes
 	paymentRoutes := router.Group("/payments")
 	{
@@ -18,5 +22,7 @@ func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentContro
 		paymentRoutes.POST("/confirm", paymentController.ConfirmPayment)    // Confirm payment
 		paymentRoutes.GET("/:id", paymentController.GetPayment)            // Get payment by ID
 		paymentRoutes.GET("/order/:orderId", paymentController.GetPaymentsByOrder) // Get payments by order ID
+		paymentRoutes.POST("/order/:orderId/cancel", service, paymentController.CancelOrderPayments) // Void or refund an order's payments
+		paymentRoutes.POST("/order/:orderId/refunds", service, paymentController.RefundOrder) // Refund part of an order's payments
 	}
 }
\ No newline at end of file
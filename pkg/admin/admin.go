@@ -0,0 +1,36 @@
+// Package admin guards the routes operators use, such as changing the log
+// level or purging an order's data
+package admin
+
+import (
+	"crypto/subtle"
+
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the admin token
+const Header = "X-Admin-Token"
+
+// Authorize rejects requests that don't send token in Header. Without a token
+// the routes are closed: every request is rejected until ADMIN_TOKEN is set.
+func Authorize(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			problem.Abort(c, problem.CodeUnauthorized, "Admin routes are disabled; set ADMIN_TOKEN to enable them")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(Header)), []byte(token)) != 1 {
+			problem.Abort(c, problem.CodeUnauthorized, "Invalid admin token")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Param documents the admin token header of a route Authorize guards
+func Param() openapi.Parameter {
+	return openapi.RequestHeader(Header, "Admin token, as set with ADMIN_TOKEN")
+}
@@ -0,0 +1,84 @@
+// Package apiclient is the HTTP plumbing shared by the services' typed
+// clients: JSON request and response bodies, request ID and trace
+// propagation, and error responses decoded as problems
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
+)
+
+// DefaultTimeout bounds a call made with the default HTTP client
+const DefaultTimeout = 10 * time.Second
+
+// Client calls one service. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// New creates a client for the service at baseURL. A nil httpClient selects
+// one that forwards the request ID and trace context of each call's context.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout, Transport: DefaultTransport()}
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: httpClient}
+}
+
+// DefaultTransport returns the transport of the default HTTP client: it
+// forwards the request ID and trace context of each call's context
+func DefaultTransport() http.RoundTripper {
+	return requestid.Transport(tracing.Transport(http.DefaultTransport))
+}
+
+// Do sends a request to path with body, if not nil, encoded as JSON. A
+// response with one of the want statuses is decoded into out, if not nil; any
+// other status is returned as the *problem.Problem the service answered with.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}, want ...int) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %s request: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create %s %s request: %w", method, path, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(want, resp.StatusCode) {
+		return problem.Decode(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package conditional implements HTTP conditional requests (RFC 9110 section
+// 13): validators on responses, 304 Not Modified for GETs whose copy is still
+// current, and If-Match preconditions for optimistic concurrency on writes
+package conditional
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag returns the strong entity tag of a resource at the given version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Version returns the version named by an entity tag from ETag
+func Version(etag string) (int, bool) {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(etag[1 : len(etag)-1])
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// SetValidators writes the ETag and Last-Modified headers. Responses are
+// marked no-cache, so clients may keep them but must revalidate before reuse.
+func SetValidators(c *gin.Context, etag string, lastModified time.Time) {
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("Cache-Control", "no-cache")
+}
+
+// NotModified answers a GET or HEAD with 304 Not Modified when the client's
+// copy is current and reports whether it did. If-None-Match takes precedence
+// over If-Modified-Since, as the RFC requires. Call it after SetValidators.
+func NotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		if !matchesWeak(header, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// IfMatch returns the versions listed in the request's If-Match header. ok is
+// false when the write is unconditional: there is no header, or it is "*",
+// which any existing resource satisfies. Tags that ETag did not produce,
+// including weak ones, never match and are left out.
+func IfMatch(r *http.Request) (versions []int, ok bool) {
+	header := strings.TrimSpace(strings.Join(r.Header.Values("If-Match"), ","))
+	if header == "" || header == "*" {
+		return nil, false
+	}
+	for _, tag := range parseTags(header) {
+		if version, valid := Version(tag); valid {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true
+}
+
+// matchesWeak reports whether an If-None-Match header lists etag, comparing
+// weakly as the RFC requires for that header
+func matchesWeak(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range parseTags(header) {
+		if strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTags splits a comma-separated list of entity tags. Commas inside the
+// quotes belong to the tag.
+func parseTags(header string) []string {
+	var tags []string
+	for header = strings.TrimSpace(header); header != ""; header = strings.TrimSpace(header) {
+		weak := strings.HasPrefix(header, "W/")
+		rest := strings.TrimPrefix(header, "W/")
+		if !strings.HasPrefix(rest, `"`) {
+			// Not an entity tag; skip to the next list element
+			if i := strings.IndexByte(header, ','); i >= 0 {
+				header = header[i+1:]
+				continue
+			}
+			break
+		}
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			break
+		}
+		tag := rest[:end+2]
+		if weak {
+			tag = "W/" + tag
+		}
+		tags = append(tags, tag)
+		header = strings.TrimPrefix(strings.TrimSpace(rest[end+2:]), ",")
+	}
+	return tags
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HTTP holds the listening port of a service
+type HTTP struct {
+	Port int `env:"PORT"`
+}
+
+// Validate checks that the port is usable
+func (h *HTTP) Validate() error {
+	if h.Port < 1 || h.Port > 65535 {
+		return fmt.Errorf("PORT must be between 1 and 65535, got %d", h.Port)
+	}
+	return nil
+}
+
+// Addr returns the address to listen on
+func (h HTTP) Addr() string {
+	return fmt.Sprintf(":%d", h.Port)
+}
+
+// Database holds PostgreSQL connection settings. There is deliberately no
+// default password: set DB_PASSWORD or DB_PASSWORD_FILE.
+type Database struct {
+	Host        string `env:"DB_HOST" required:"true"`
+	Port        int    `env:"DB_PORT"`
+	User        string `env:"DB_USER" required:"true"`
+	Password    string `env:"DB_PASSWORD" required:"true" secret:"true"`
+	Name        string `env:"DB_NAME" required:"true"`
+	SSLMode     string `env:"DB_SSLMODE" oneof:"disable allow prefer require verify-ca verify-full"`
+	AutoMigrate bool   `env:"DB_AUTO_MIGRATE"`
+}
+
+// DefaultDatabase returns local development settings for the named database
+func DefaultDatabase(name string) Database {
+	return Database{
+		Host:        "localhost",
+		Port:        5432,
+		User:        "postgres",
+		Name:        name,
+		SSLMode:     "disable",
+		AutoMigrate: true,
+	}
+}
+
+// Validate checks that the port is usable
+func (d *Database) Validate() error {
+	if d.Port < 1 || d.Port > 65535 {
+		return fmt.Errorf("DB_PORT must be between 1 and 65535, got %d", d.Port)
+	}
+	return nil
+}
+
+// DSN returns the lib/pq connection string
+func (d Database) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, quoteDSN(d.Password), d.Name, d.SSLMode)
+}
+
+// Health holds readiness check settings
+type Health struct {
+	Timeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT"`
+	CacheTTL time.Duration `env:"HEALTH_CACHE_TTL"`
+}
+
+// DefaultHealth returns the default readiness check settings
+func DefaultHealth() Health {
+	return Health{Timeout: time.Second, CacheTTL: 2 * time.Second}
+}
+
+// Validate checks that dependency probes have a deadline
+func (h *Health) Validate() error {
+	if h.Timeout <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_TIMEOUT must be positive, got %s", h.Timeout)
+	}
+	if h.CacheTTL < 0 {
+		return fmt.Errorf("HEALTH_CACHE_TTL must not be negative, got %s", h.CacheTTL)
+	}
+	return nil
+}
+
+// Shutdown holds graceful shutdown settings
+type Shutdown struct {
+	DrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY"`
+	Timeout    time.Duration `env:"SHUTDOWN_TIMEOUT"`
+}
+
+// DefaultShutdown returns the default graceful shutdown settings
+func DefaultShutdown() Shutdown {
+	return Shutdown{DrainDelay: 5 * time.Second, Timeout: 20 * time.Second}
+}
+
+// Validate checks the shutdown durations
+func (s *Shutdown) Validate() error {
+	if s.DrainDelay < 0 {
+		return fmt.Errorf("SHUTDOWN_DRAIN_DELAY must not be negative, got %s", s.DrainDelay)
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT must be positive, got %s", s.Timeout)
+	}
+	return nil
+}
+
+// Admin holds the token guarding administrative endpoints; while it is empty
+// those endpoints reject every request
+type Admin struct {
+	Token string `env:"ADMIN_TOKEN" secret:"true"`
+}
+
+// Service holds the token the order, inventory and payment services share to
+// call each other's internal endpoints, such as reserving stock or refunding
+// a payment
+type Service struct {
+	Token string `env:"SERVICE_TOKEN" required:"true" secret:"true"`
+}
+
+// ValidateURL checks that value is an absolute http(s) URL, for service endpoints
+func ValidateURL(key, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", key, value)
+	}
+	return nil
+}
+
+// quoteDSN quotes a connection string value so passwords may contain spaces or quotes
+func quoteDSN(value string) string {
+	escaped := ""
+	for _, r := range value {
+		if r == '\\' || r == '\'' {
+			escaped += `\`
+		}
+		escaped += string(r)
+	}
+	return "'" + escaped + "'"
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config structs describe settings with field tags:
+//
+//	env:"DB_HOST"        name of the environment variable (and config file key)
+//	required:"true"      the setting must end up non-empty
+//	secret:"true"        the value is redacted by Print
+//	oneof:"a b c"        the value must be one of the listed words
+//
+// Fields without an env tag that hold a struct are walked recursively, so
+// services can embed the shared blocks in this package. Such a field may
+// carry a prefix:"PAYMENT_" tag, which is put in front of every key inside it,
+// so one struct type can describe several sets of settings. Defaults are
+// whatever the struct holds before Load is called.
+
+// Validator is implemented by config structs with rules beyond the field tags
+type Validator interface {
+	Validate() error
+}
+
+// PrefixedValidator is implemented by config structs used under a prefix tag;
+// prefix is the one in effect, for naming keys in the errors
+type PrefixedValidator interface {
+	ValidatePrefixed(prefix string) error
+}
+
+// Lookup returns a raw value by key, like os.LookupEnv
+type Lookup func(key string) (string, bool)
+
+// Loader reads settings from the environment and an optional config file.
+// Environment variables take precedence over the file, which takes
+// precedence over the defaults already present in the struct.
+type Loader struct {
+	// Env looks up environment variables; defaults to os.LookupEnv
+	Env Lookup
+	// File is an optional KEY=VALUE or JSON file using the same keys as the environment
+	File string
+	// ReadFile reads the config file and *_FILE secrets; defaults to os.ReadFile
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Load fills cfg, which must be a pointer to a struct, and validates it
+func (l Loader) Load(cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: Load needs a pointer to a struct, got %T", cfg)
+	}
+	if l.Env == nil {
+		l.Env = os.LookupEnv
+	}
+	if l.ReadFile == nil {
+		l.ReadFile = os.ReadFile
+	}
+
+	file := map[string]string{}
+	if l.File != "" {
+		var err error
+		if file, err = l.readConfigFile(); err != nil {
+			return err
+		}
+	}
+	sources := []Lookup{l.Env, func(key string) (string, bool) {
+		value, ok := file[key]
+		return value, ok
+	}}
+
+	var problems []error
+	for _, f := range fields(v.Elem(), "") {
+		raw, ok, err := l.resolve(sources, f.key)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if ok {
+			if err := setValue(f.value, raw); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", f.key, err))
+				continue
+			}
+		}
+		problems = append(problems, f.check()...)
+	}
+	problems = append(problems, validate(v, "")...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidationError lists every problem found while loading a configuration
+type ValidationError struct {
+	Problems []error
+}
+
+// Error joins the problems into one line, so it reads well in structured logs
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns the individual problems for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// resolve returns the first value set for key, reading KEY_FILE when KEY itself is absent
+func (l Loader) resolve(sources []Lookup, key string) (string, bool, error) {
+	for _, lookup := range sources {
+		value, hasValue := lookup(key)
+		hasValue = hasValue && value != ""
+		path, hasFile := lookup(key + "_FILE")
+		hasFile = hasFile && path != ""
+
+		switch {
+		case hasValue && hasFile:
+			return "", false, fmt.Errorf("%s: set either %s or %s_FILE, not both", key, key, key)
+		case hasValue:
+			return value, true, nil
+		case hasFile:
+			data, err := l.ReadFile(path)
+			if err != nil {
+				return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+			}
+			return strings.TrimRight(string(data), "\r\n"), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// readConfigFile parses the config file as JSON when it ends in .json, or as KEY=VALUE lines
+func (l Loader) readConfigFile() (map[string]string, error) {
+	data, err := l.ReadFile(l.File)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	values := make(map[string]string)
+	if strings.EqualFold(filepath.Ext(l.File), ".json") {
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", l.File, err)
+		}
+		for key, value := range raw {
+			switch value := value.(type) {
+			case string:
+				values[key] = value
+			case float64, bool:
+				values[key] = fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("config file %s: %s must be a string, number or boolean", l.File, key)
+			}
+		}
+		return values, nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("config file %s:%d: expected KEY=VALUE", l.File, i+1)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return values, nil
+}
+
+// field is a tagged, settable config value
+type field struct {
+	key      string
+	value    reflect.Value
+	required bool
+	secret   bool
+	oneof    []string
+}
+
+// check applies the required and oneof tag rules
+func (f field) check() []error {
+	if f.required && f.value.IsZero() {
+		return []error{fmt.Errorf("%s is required", f.key)}
+	}
+	if len(f.oneof) > 0 && f.value.Kind() == reflect.String {
+		current := f.value.String()
+		for _, allowed := range f.oneof {
+			if current == allowed {
+				return nil
+			}
+		}
+		return []error{fmt.Errorf("%s must be one of %s, got %q", f.key, strings.Join(f.oneof, ", "), current)}
+	}
+	return nil
+}
+
+// fields lists the tagged fields of a struct in declaration order, descending
+// into nested structs. prefix is put in front of every key.
+func fields(v reflect.Value, prefix string) []field {
+	var result []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Tag.Get("env")
+		if key == "" {
+			if sf.Type.Kind() == reflect.Struct {
+				result = append(result, fields(v.Field(i), prefix+sf.Tag.Get("prefix"))...)
+			}
+			continue
+		}
+		result = append(result, field{
+			key:      prefix + key,
+			value:    v.Field(i),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+			oneof:    strings.Fields(sf.Tag.Get("oneof")),
+		})
+	}
+	return result
+}
+
+// validate calls Validate on v and every nested struct that implements
+// Validator or PrefixedValidator
+func validate(v reflect.Value, prefix string) []error {
+	var problems []error
+	if validator, ok := v.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			problems = append(problems, flatten(err)...)
+		}
+	}
+	if validator, ok := v.Interface().(PrefixedValidator); ok {
+		if err := validator.ValidatePrefixed(prefix); err != nil {
+			problems = append(problems, flatten(err)...)
+		}
+	}
+
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		sf := elem.Type().Field(i)
+		if sf.IsExported() && sf.Tag.Get("env") == "" && sf.Type.Kind() == reflect.Struct {
+			problems = append(problems, validate(elem.Field(i).Addr(), prefix+sf.Tag.Get("prefix"))...)
+		}
+	}
+	return problems
+}
+
+// flatten splits errors built with errors.Join into their parts
+func flatten(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parses raw into v according to its type
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// formatValue renders a field value the way it would be written in the environment
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"go-microservices/pkg/logging"
+)
+
+// redacted replaces secret values in printed configuration
+const redacted = "[REDACTED]"
+
+// Print writes the effective configuration as KEY=VALUE lines, hiding secrets
+func Print(w io.Writer, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config: Print needs a struct, got %T", cfg)
+	}
+
+	for _, f := range fields(v, "") {
+		value := formatValue(f.value)
+		if f.secret && value != "" {
+			value = redacted
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", f.key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustLoad loads cfg for the named service and exits on invalid settings.
+// The config file comes from --config <path> or CONFIG_FILE; with
+// --print-config the redacted configuration is printed and the process exits.
+func MustLoad(service string, cfg any) {
+	file, printOnly := parseArgs(os.Args[1:])
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+
+	if err := (Loader{File: file}).Load(cfg); err != nil {
+		if printOnly {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", service, err)
+			os.Exit(1)
+		}
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
+	if printOnly {
+		if err := Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", service, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// parseArgs finds --config and --print-config among other command line arguments
+func parseArgs(args []string) (file string, printOnly bool) {
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(args[i], "-")
+		switch {
+		case arg == "-print-config" || arg == "print-config":
+			printOnly = true
+		case arg == "-config" || arg == "config":
+			if i+1 < len(args) {
+				file = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(arg, "-config=") || strings.HasPrefix(arg, "config="):
+			_, file, _ = strings.Cut(arg, "=")
+		}
+	}
+	return file, printOnly
+}
@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-microservices/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Overall readiness states reported by /readyz
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+)
+
+// Dependency states reported for each check
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Probe checks one dependency, returning an error when it is unusable
+type Probe func(ctx context.Context) error
+
+// Check is a dependency probed by /readyz. A critical dependency that is down
+// makes the service not ready; any other dependency only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    Probe
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of a service and each of its dependencies
+type Report struct {
+	Status    string            `json:"status"`
+	Service   string            `json:"service"`
+	Draining  bool              `json:"draining,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Checker runs readiness checks and caches the report, so frequent probes
+// from several orchestrators don't turn into a storm of dependency calls
+type Checker struct {
+	service  string
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	checks   []Check
+	last     *Report
+	expires  time.Time
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker whose probes each get timeout and whose report is reused for cacheTTL
+func NewChecker(service string, timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{service: service, timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Add registers a dependency check
+func (h *Checker) Add(check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check)
+	h.last = nil
+}
+
+// SetDraining marks the service as shutting down, so it reports not ready
+// without probing and load balancers stop sending it traffic
+func (h *Checker) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+// Check returns the cached report, or probes every dependency concurrently when it has expired.
+// Callers arriving while a check runs wait for it rather than starting another.
+func (h *Checker) Check(ctx context.Context) Report {
+	if h.draining.Load() {
+		return Report{
+			Status:    StatusNotReady,
+			Service:   h.service,
+			Draining:  true,
+			CheckedAt: time.Now().UTC(),
+			Checks:    map[string]Result{},
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if h.last != nil && now.Before(h.expires) {
+		return *h.last
+	}
+
+	report := Report{
+		Status:    StatusReady,
+		Service:   h.service,
+		CheckedAt: now.UTC(),
+		Checks:    make(map[string]Result, len(h.checks)),
+	}
+
+	results := make([]Result, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range h.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusUp {
+			continue
+		}
+		if check.Critical {
+			report.Status = StatusNotReady
+		} else if report.Status == StatusReady {
+			report.Status = StatusDegraded
+		}
+	}
+
+	h.last = &report
+	h.expires = now.Add(h.cacheTTL)
+	return report
+}
+
+// run probes one dependency within the checker's timeout. The probe is
+// detached from the caller's cancellation because its result is shared.
+func (h *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := Result{
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out after " + h.timeout.String()
+		}
+	}
+	return result
+}
+
+// RegisterRoutes adds GET /livez, which only reports that the process is
+// serving, and GET /readyz, which returns 503 when the service is not ready
+func (h *Checker) RegisterRoutes(router gin.IRoutes) {
+	router.GET("/livez", Live)
+	router.GET("/readyz", h.Ready)
+}
+
+// Routes documents the routes RegisterRoutes adds
+func Routes() []openapi.Route {
+	return []openapi.Route{
+		{Method: "GET", Path: "/livez", ID: "getLiveness", Summary: "Liveness probe", Tag: "operations",
+			Response: map[string]string{}},
+		{Method: "GET", Path: "/readyz", ID: "getReadiness", Summary: "Readiness of the service and each dependency", Tag: "operations",
+			Response: Report{}, Responses: map[int]interface{}{http.StatusServiceUnavailable: Report{}}},
+	}
+}
+
+// Live reports that the process is up and able to serve requests
+func Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Ready reports the readiness of the service and each dependency
+func (h *Checker) Ready(c *gin.Context) {
+	report := h.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status == StatusNotReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
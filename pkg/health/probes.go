@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SQL returns a probe that pings a database
+func SQL(db *sql.DB) Probe {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return fmt.Errorf("database not initialized")
+		}
+		return db.PingContext(ctx)
+	}
+}
+
+// HTTP returns a probe that expects a 2xx response to GET url
+func HTTP(client *http.Client, url string) Probe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Any returns a probe that succeeds when at least one of probes succeeds,
+// for a dependency served by several interchangeable instances
+func Any(probes ...Probe) Probe {
+	return func(ctx context.Context) error {
+		var err error
+		for _, probe := range probes {
+			if err = probe(ctx); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+// Package keyspace builds versioned cache keys, so a change to a cached type
+// never decodes entries written in its old shape
+package keyspace
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Keyspace builds the cache keys for one kind of value. Keys carry a version
+// derived from the shape of the cached type, so changing the type moves it to
+// new keys instead of decoding entries written in the old shape.
+type Keyspace struct {
+	prefix string
+}
+
+// New returns the keyspace for values of sample's type, e.g.
+// New("order", model.Order{}) gives keys like "order:v1a2b3c4d:42"
+func New(name string, sample interface{}) Keyspace {
+	h := fnv.New32a()
+	writeShape(h, reflect.TypeOf(sample), map[reflect.Type]bool{})
+	return Keyspace{prefix: fmt.Sprintf("%s:v%08x:", name, h.Sum32())}
+}
+
+// Key returns the key for the value with the given ID
+func (k Keyspace) Key(id string) string {
+	return k.prefix + id
+}
+
+// writeShape writes the parts of t that affect its JSON encoding
+func writeShape(w io.Writer, t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		fmt.Fprintf(w, "%s(", t.Kind())
+		if t.Kind() == reflect.Map {
+			fmt.Fprintf(w, "%s)", t.Key())
+		}
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] || t.PkgPath() == "time" {
+		fmt.Fprint(w, t.String())
+		return
+	}
+	seen[t] = true
+
+	fmt.Fprint(w, "{")
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" {
+			name = field.Name
+		}
+		fmt.Fprintf(w, "%s:%s:", name, field.Tag.Get("json"))
+		writeShape(w, field.Type, seen)
+		fmt.Fprint(w, ";")
+	}
+	fmt.Fprint(w, "}")
+}
@@ -0,0 +1,134 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-microservices/pkg/config"
+)
+
+// hook is a named step run during shutdown
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Server runs an HTTP server until SIGINT or SIGTERM and then shuts down in stages:
+//
+//  1. drain: the OnDrain callbacks run (readiness starts failing) and the server
+//     keeps serving for the drain delay so load balancers stop routing to it
+//  2. the listener closes and in-flight requests finish, up to the shutdown timeout;
+//     requests still running at the deadline have their contexts cancelled
+//  3. the OnShutdown hooks run in the order they were registered
+type Server struct {
+	http       *http.Server
+	cfg        config.Shutdown
+	cancelBase context.CancelFunc
+	onDrain    []func()
+	hooks      []hook
+}
+
+// NewServer creates a server for handler listening on addr
+func NewServer(addr string, handler http.Handler, cfg config.Shutdown) *Server {
+	base, cancel := context.WithCancel(context.Background())
+	return &Server{
+		http: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return base },
+		},
+		cfg:        cfg,
+		cancelBase: cancel,
+	}
+}
+
+// OnDrain registers a callback run as soon as shutdown begins
+func (s *Server) OnDrain(fn func()) {
+	s.onDrain = append(s.onDrain, fn)
+}
+
+// OnShutdown registers a step run after the HTTP server has stopped. Steps run
+// in registration order, so register consumers before the connections they use.
+func (s *Server) OnShutdown(name string, stop func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, hook{name: name, stop: stop})
+}
+
+// Run listens on the server's address and serves until SIGINT or SIGTERM.
+// A second signal during shutdown terminates the process immediately.
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then shuts down
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		s.cancelBase()
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutdown started, draining", "drain_delay", s.cfg.DrainDelay.String(), "timeout", s.cfg.Timeout.String())
+	for _, fn := range s.onDrain {
+		fn()
+	}
+	time.Sleep(s.cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests did not finish before the shutdown deadline", "error", err)
+		errs = append(errs, fmt.Errorf("http: %w", err))
+		s.http.Close()
+	}
+	s.cancelBase()
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("http: %w", err))
+	}
+
+	for _, h := range s.hooks {
+		start := time.Now()
+		if err := h.stop(shutdownCtx); err != nil {
+			slog.Warn("Shutdown step failed", "step", h.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		slog.Info("Shutdown step completed", "step", h.name, "duration_ms", time.Since(start).Milliseconds())
+	}
+
+	slog.Info("Shutdown complete")
+	return errors.Join(errs...)
+}
+
+// Closer adapts a Close method without a context to OnShutdown
+func Closer(close func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return close()
+	}
+}
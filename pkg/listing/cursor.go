@@ -0,0 +1,69 @@
+package listing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// errCursor is returned for cursors that cannot be used with the request
+var errCursor = errors.New("is not a valid cursor for this list")
+
+// cursor is the position after which a page starts. It records the sort and
+// filters it was issued for so it cannot be replayed against a different list.
+type cursor struct {
+	Sort        string      `json:"s"`
+	Fingerprint string      `json:"f"`
+	Value       interface{} `json:"v,omitempty"`
+	Key         int64       `json:"k"`
+
+	// value is Value converted back to the sort field's type
+	value interface{}
+}
+
+// encodeCursor returns the opaque form of c sent to clients
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor and converts its sort value to kind
+func decodeCursor(raw string, kind Kind) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errCursor
+	}
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, errCursor
+	}
+	if c.Value != nil {
+		if c.value, err = parseValue(fmt.Sprint(c.Value), kind); err != nil {
+			return nil, errCursor
+		}
+	}
+	return &c, nil
+}
+
+// fingerprint hashes the filter parameters so a cursor can be checked against them
+func fingerprint(filters []Filter, values url.Values) string {
+	params := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if v := values.Get(f.Param); v != "" {
+			params = append(params, f.Param+"="+v)
+		}
+	}
+	sort.Strings(params)
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(params, "&")))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -0,0 +1,40 @@
+package listing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers describing a page. Bodies stay plain JSON arrays so
+// clients that ignore paging keep working.
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderLink       = "Link"
+)
+
+// SetHeaders writes the page's Link and X-Total-Count headers. The next link
+// is relative to the request URI, so it stays valid behind the gateway's
+// path prefix.
+func SetHeaders[T any](c *gin.Context, page Page[T]) {
+	setHeaders(c.Writer.Header(), c.Request, page.Next, page.Total)
+}
+
+func setHeaders(header http.Header, r *http.Request, next string, total *int64) {
+	if next != "" {
+		query := r.URL.Query()
+		query.Set("cursor", next)
+		header.Add(HeaderLink, fmt.Sprintf(`<?%s>; rel="next"`, query.Encode()))
+	}
+	if total != nil {
+		header.Set(HeaderTotalCount, strconv.FormatInt(*total, 10))
+	}
+}
+
+// Respond writes the page's headers and its items as a JSON array
+func Respond[T any](c *gin.Context, page Page[T]) {
+	SetHeaders(c, page)
+	c.JSON(http.StatusOK, page.Items)
+}
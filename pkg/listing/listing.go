@@ -0,0 +1,234 @@
+// Package listing builds paginated, filtered and sorted list queries so every
+// service's list endpoints accept the same parameters and behave the same way:
+//
+//	limit          page size, 1 to the spec's maximum
+//	cursor         opaque position returned in the previous page's Link header
+//	sort           a whitelisted field, prefixed with "-" for descending order
+//	include_total  "true" to count all matching rows into X-Total-Count
+//
+// plus the filters each resource declares. Pages use keyset pagination on
+// the sort field and the row's unique key, so they stay stable while rows are
+// inserted and cost the same however deep the client pages.
+package listing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Default page sizes, used when a spec leaves them zero
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Kind is the type of a filter or sort value
+type Kind int
+
+// Value kinds
+const (
+	Int Kind = iota
+	Float
+	String
+	Time
+)
+
+// Op compares a column with a filter value
+type Op string
+
+// Filter operators. In takes a comma-separated list of values.
+const (
+	Eq  Op = "="
+	Gte Op = ">="
+	Lte Op = "<="
+	Lt  Op = "<"
+	In  Op = "IN"
+)
+
+// Filter is a query parameter that narrows the list
+type Filter struct {
+	Param  string
+	Column string
+	Op     Op
+	Kind   Kind
+	// OneOf restricts string values, e.g. the statuses a resource can have
+	OneOf []string
+}
+
+// Field is a sortable column. Value returns the column's value for an item,
+// which the next page's cursor starts after.
+type Field[T any] struct {
+	Column string
+	Kind   Kind
+	Value  func(T) interface{}
+}
+
+// Spec describes how one resource is listed
+type Spec[T any] struct {
+	Table   string
+	Columns string
+	// Key is a unique, non-null integer column that breaks ties between rows
+	// with the same sort value, and KeyOf returns it for an item
+	Key   string
+	KeyOf func(T) int64
+
+	Filters []Filter
+	Sorts   map[string]Field[T]
+	// DefaultSort is a Sorts name, prefixed with "-" for descending order
+	DefaultSort string
+
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Querier runs list queries; *sql.DB and *sql.Tx implement it
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items []T
+	// Next is the cursor of the following page, empty on the last page
+	Next string
+	// Total counts every matching row when include_total was requested
+	Total *int64
+}
+
+// Request is a parsed list request, ready to be run
+type Request[T any] struct {
+	spec  *Spec[T]
+	Limit int
+
+	sort         string
+	desc         bool
+	after        *cursor
+	includeTotal bool
+	fingerprint  string
+
+	where []string
+	args  []interface{}
+}
+
+// Where adds a condition every row must meet, such as the parent resource of a
+// nested list. column is compared for equality with value.
+func (r *Request[T]) Where(column string, value interface{}) {
+	r.args = append(r.args, value)
+	r.where = append(r.where, fmt.Sprintf("%s = $%d", column, len(r.args)))
+}
+
+// whereClause returns the filter conditions, plus the cursor position when withCursor is set
+func (r *Request[T]) whereClause(withCursor bool) (string, []interface{}) {
+	conditions := append([]string(nil), r.where...)
+	args := append([]interface{}(nil), r.args...)
+
+	if withCursor && r.after != nil {
+		field := r.spec.Sorts[r.sort]
+		comparison := ">"
+		if r.desc {
+			comparison = "<"
+		}
+		if field.Column == r.spec.Key {
+			args = append(args, r.after.Key)
+			conditions = append(conditions, fmt.Sprintf("%s %s $%d", r.spec.Key, comparison, len(args)))
+		} else {
+			args = append(args, r.after.value, r.after.Key)
+			conditions = append(conditions, fmt.Sprintf("(%s, %s) %s ($%d, $%d)",
+				field.Column, r.spec.Key, comparison, len(args)-1, len(args)))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// SQL returns the page query. It fetches one row more than the limit to learn
+// whether another page follows.
+func (r *Request[T]) SQL() (string, []interface{}) {
+	where, args := r.whereClause(true)
+	field := r.spec.Sorts[r.sort]
+	direction := "ASC"
+	if r.desc {
+		direction = "DESC"
+	}
+
+	order := fmt.Sprintf("%s %s", field.Column, direction)
+	if field.Column != r.spec.Key {
+		order += fmt.Sprintf(", %s %s", r.spec.Key, direction)
+	}
+	args = append(args, r.Limit+1)
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT $%d",
+		r.spec.Columns, r.spec.Table, where, order, len(args)), args
+}
+
+// CountSQL returns the query counting every row matching the filters
+func (r *Request[T]) CountSQL() (string, []interface{}) {
+	where, args := r.whereClause(false)
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s%s", r.spec.Table, where), args
+}
+
+// Page turns the rows fetched by SQL into a page, trimming the extra row and
+// setting the cursor of the next page
+func (r *Request[T]) Page(items []T) Page[T] {
+	page := Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) > r.Limit {
+		page.Items = items[:r.Limit]
+		last := page.Items[r.Limit-1]
+		page.Next = encodeCursor(cursor{
+			Sort:        r.sortParam(),
+			Fingerprint: r.fingerprint,
+			Value:       r.spec.Sorts[r.sort].Value(last),
+			Key:         r.spec.KeyOf(last),
+		})
+	}
+	return page
+}
+
+// sortParam returns the sort as written in the query string
+func (r *Request[T]) sortParam() string {
+	if r.desc {
+		return "-" + r.sort
+	}
+	return r.sort
+}
+
+// List runs the request against db, scanning each row with scan
+func List[T any](ctx context.Context, db Querier, r *Request[T], scan func(*sql.Rows) (T, error)) (Page[T], error) {
+	query, args := r.SQL()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	page := r.Page(items)
+	if r.includeTotal {
+		var total int64
+		countQuery, countArgs := r.CountSQL()
+		if err := db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+			return Page[T]{}, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
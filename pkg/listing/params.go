@@ -0,0 +1,44 @@
+package listing
+
+import "fmt"
+
+// Param describes a query parameter a list endpoint accepts, for API
+// documentation
+type Param struct {
+	Name        string
+	Kind        Kind
+	OneOf       []string
+	Description string
+}
+
+// opDescriptions says how each operator compares a filter value
+var opDescriptions = map[Op]string{
+	Eq:  "equal to",
+	Gte: "at or above",
+	Lte: "at or below",
+	Lt:  "below",
+	In:  "one of a comma-separated list of",
+}
+
+// Params describes the query parameters of lists made with s: the paging and
+// sorting parameters every list accepts, then the spec's filters
+func (s *Spec[T]) Params() []Param {
+	var sorts []string
+	for _, name := range s.sortNames() {
+		sorts = append(sorts, name, "-"+name)
+	}
+	params := []Param{
+		{Name: "limit", Kind: Int, Description: fmt.Sprintf("Page size, 1 to %d (default %d)", s.maxLimit(), s.defaultLimit())},
+		{Name: "cursor", Kind: String, Description: "Position returned in the previous page's Link header"},
+		{Name: "sort", Kind: String, OneOf: sorts, Description: fmt.Sprintf("Sort field, prefixed with - for descending order (default %s)", s.DefaultSort)},
+		{Name: "include_total", Kind: String, OneOf: []string{"true", "false"}, Description: "Count all matching items into X-Total-Count"},
+	}
+	for _, f := range s.Filters {
+		description := fmt.Sprintf("Only items whose %s is %s the value", f.Column, opDescriptions[f.Op])
+		if f.Kind == Time {
+			description += " (an RFC 3339 timestamp or a YYYY-MM-DD date)"
+		}
+		params = append(params, Param{Name: f.Param, Kind: f.Kind, OneOf: f.OneOf, Description: description})
+	}
+	return params
+}
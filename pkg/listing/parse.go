@@ -0,0 +1,197 @@
+package listing
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/pkg/problem"
+
+	"github.com/lib/pq"
+)
+
+// kindNames names lists of each kind in error messages
+var kindNames = map[Kind]string{Int: "integers", Float: "numbers", String: "strings", Time: "timestamps"}
+
+// dateLayout is accepted by time filters alongside RFC 3339
+const dateLayout = "2006-01-02"
+
+// Parse reads a list request from query parameters. Invalid parameters are
+// reported together as a validation problem.
+func (s *Spec[T]) Parse(values url.Values) (*Request[T], *problem.Problem) {
+	r := &Request[T]{spec: s, Limit: s.defaultLimit()}
+	var fieldErrs []problem.FieldError
+	invalid := func(field, rule, message string) {
+		fieldErrs = append(fieldErrs, problem.FieldError{Field: field, Rule: rule, Message: message})
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		switch {
+		case err != nil:
+			invalid("limit", "type", "must be an integer")
+		case limit < 1:
+			invalid("limit", "min", "must be at least 1")
+		case limit > s.maxLimit():
+			invalid("limit", "max", fmt.Sprintf("must be at most %d", s.maxLimit()))
+		default:
+			r.Limit = limit
+		}
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = s.DefaultSort
+	}
+	r.sort, r.desc = strings.TrimPrefix(sortParam, "-"), strings.HasPrefix(sortParam, "-")
+	if _, ok := s.Sorts[r.sort]; !ok {
+		invalid("sort", "oneof", "must be one of "+strings.Join(s.sortNames(), ", "))
+	}
+
+	if raw := values.Get("include_total"); raw != "" {
+		total, err := strconv.ParseBool(raw)
+		if err != nil {
+			invalid("include_total", "type", "must be a boolean")
+		}
+		r.includeTotal = total
+	}
+
+	for _, f := range s.Filters {
+		raw := values.Get(f.Param)
+		if raw == "" {
+			continue
+		}
+		if err := r.addFilter(f, raw); err != nil {
+			invalid(f.Param, "type", err.Error())
+		}
+	}
+
+	r.fingerprint = fingerprint(s.Filters, values)
+	if raw := values.Get("cursor"); raw != "" && len(fieldErrs) == 0 {
+		after, err := decodeCursor(raw, s.Sorts[r.sort].Kind)
+		if err == nil && (after.Sort != sortParam || after.Fingerprint != r.fingerprint ||
+			after.value == nil && s.Sorts[r.sort].Column != s.Key) {
+			err = errCursor
+		}
+		if err != nil {
+			invalid("cursor", "cursor", err.Error())
+		}
+		r.after = after
+	}
+
+	if len(fieldErrs) > 0 {
+		p := problem.New(problem.CodeValidation, "One or more query parameters are invalid")
+		p.Errors = fieldErrs
+		return nil, p
+	}
+	return r, nil
+}
+
+// addFilter parses a filter's value and adds its condition
+func (r *Request[T]) addFilter(f Filter, raw string) error {
+	if f.Op == In {
+		var ints pq.Int64Array
+		var floats pq.Float64Array
+		var strs pq.StringArray
+		for _, part := range strings.Split(raw, ",") {
+			value, err := f.parse(strings.TrimSpace(part))
+			if err != nil {
+				return errors.New("must be a comma-separated list of " + kindNames[f.Kind])
+			}
+			switch v := value.(type) {
+			case int64:
+				ints = append(ints, v)
+			case float64:
+				floats = append(floats, v)
+			default:
+				strs = append(strs, fmt.Sprint(v))
+			}
+		}
+		switch f.Kind {
+		case Int:
+			r.args = append(r.args, ints)
+		case Float:
+			r.args = append(r.args, floats)
+		default:
+			r.args = append(r.args, strs)
+		}
+		r.where = append(r.where, fmt.Sprintf("%s = ANY($%d)", f.Column, len(r.args)))
+		return nil
+	}
+
+	value, err := f.parse(raw)
+	if err != nil {
+		return err
+	}
+	r.args = append(r.args, value)
+	r.where = append(r.where, fmt.Sprintf("%s %s $%d", f.Column, f.Op, len(r.args)))
+	return nil
+}
+
+// parse converts one filter value, checking it against OneOf
+func (f Filter) parse(raw string) (interface{}, error) {
+	if len(f.OneOf) > 0 {
+		for _, allowed := range f.OneOf {
+			if raw == allowed {
+				return raw, nil
+			}
+		}
+		return nil, errors.New("must be one of " + strings.Join(f.OneOf, ", "))
+	}
+	return parseValue(raw, f.Kind)
+}
+
+// parseValue converts raw to the Go type used for kind
+func parseValue(raw string, kind Kind) (interface{}, error) {
+	switch kind {
+	case Int:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return v, nil
+	case Float:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return v, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(dateLayout, raw); err == nil {
+			return t, nil
+		}
+		return nil, errors.New("must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	return raw, nil
+}
+
+// sortNames returns the accepted sort fields
+func (s *Spec[T]) sortNames() []string {
+	names := make([]string, 0, len(s.Sorts))
+	for name := range s.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Spec[T]) defaultLimit() int {
+	if s.DefaultLimit > 0 {
+		return s.DefaultLimit
+	}
+	return DefaultLimit
+}
+
+func (s *Spec[T]) maxLimit() int {
+	if s.MaxLimit > 0 {
+		return s.MaxLimit
+	}
+	return MaxLimit
+}
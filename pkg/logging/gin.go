@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware adds the matched route and the caller's user ID to the request
+// context, so every log line written while handling the request carries them
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attrs := []slog.Attr{slog.String("route", c.FullPath())}
+		if userID := c.GetHeader("X-User-ID"); userID != "" {
+			attrs = append(attrs, slog.String("user_id", userID))
+		}
+		c.Request = c.Request.WithContext(WithAttrs(c.Request.Context(), attrs...))
+		c.Next()
+	}
+}
+
+// AccessLog writes one log line per request, replacing gin's text logger
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.Default().LogAttrs(c.Request.Context(), StatusLevel(status), "request", attrs...)
+	}
+}
+
+// StatusLevel returns the level for logging a response with the given status
+func StatusLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// RegisterAdminRoutes adds GET and PUT /admin/log-level for reading and
+// changing the level at runtime. Requests must send token in the
+// X-Admin-Token header; without a token the routes reject every request.
+func RegisterAdminRoutes(router gin.IRoutes, token string) {
+	authorize := admin.Authorize(token)
+	router.GET("/admin/log-level", authorize, getLevel)
+	router.PUT("/admin/log-level", authorize, putLevel)
+}
+
+// LogLevel is the body of the log level admin routes
+type LogLevel struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// AdminRoutes documents the routes RegisterAdminRoutes adds
+func AdminRoutes() []openapi.Route {
+	token := []openapi.Parameter{admin.Param()}
+	return []openapi.Route{
+		{Method: "GET", Path: "/admin/log-level", ID: "getLogLevel", Summary: "Current log level", Tag: "operations",
+			Params: token, Response: LogLevel{}, Errors: []int{http.StatusUnauthorized}},
+		{Method: "PUT", Path: "/admin/log-level", ID: "setLogLevel", Summary: "Change the log level", Tag: "operations",
+			Params: token, Body: LogLevel{}, Response: LogLevel{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	}
+}
+
+// getLevel returns the current level
+func getLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, LogLevel{Level: Level().String()})
+}
+
+// putLevel changes the level, e.g. {"level": "debug"}
+func putLevel(c *gin.Context) {
+	var req LogLevel
+	if !problem.BindJSON(c, &req) {
+		return
+	}
+	previous := Level()
+	if err := SetLevel(req.Level); err != nil {
+		problem.Abort(c, problem.CodeBadRequest, err.Error())
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Log level changed", "from", previous.String(), "to", Level().String())
+	c.JSON(http.StatusOK, LogLevel{Level: Level().String()})
+}
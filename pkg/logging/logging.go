@@ -0,0 +1,97 @@
+// Package logging configures structured slog logging shared by the gateway
+// and services: JSON or text output, a level adjustable at runtime,
+// request-scoped fields and redaction of secrets
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Output formats supported by LOG_FORMAT
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// level is shared by every logger created by Init so it can be changed at runtime
+var level = new(slog.LevelVar)
+
+// Options configures a handler
+type Options struct {
+	// Format is FormatJSON (default) or FormatText
+	Format string
+	// Level is the minimum level logged; defaults to the shared runtime level
+	Level slog.Leveler
+}
+
+// NewHandler returns a handler writing to w that adds request-scoped fields
+// from the context and redacts sensitive attributes
+func NewHandler(w io.Writer, opts Options) slog.Handler {
+	leveler := opts.Level
+	if leveler == nil {
+		leveler = level
+	}
+	handlerOpts := &slog.HandlerOptions{
+		Level:       leveler,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if opts.Format == FormatText {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	}
+	return &contextHandler{Handler: handler}
+}
+
+// Init configures the default logger for the named service from LOG_FORMAT
+// ("json" or "text", default "json") and LOG_LEVEL (default "info"). Output
+// from the standard log package is routed through the same logger.
+func Init(service string) *slog.Logger {
+	if err := SetLevel(getEnv("LOG_LEVEL", "info")); err != nil {
+		fmt.Fprintf(os.Stderr, "%v, using info\n", err)
+	}
+
+	logger := slog.New(NewHandler(os.Stdout, Options{
+		Format: strings.ToLower(getEnv("LOG_FORMAT", FormatJSON)),
+	})).With(slog.String("service", service))
+
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Level returns the current runtime level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the runtime level of every logger created by Init.
+// It accepts debug, info, warn or error, optionally with an offset like "debug-4".
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Fatal logs an error and exits, for failures during startup
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the value of sensitive attributes
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute or field names whose values are never logged.
+// Names containing "secret", "password" or "token" are redacted as well.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"api_key":       true,
+	"card_number":   true,
+	"cvc":           true,
+}
+
+// isSensitive reports whether a key names a secret
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	return strings.Contains(key, "secret") || strings.Contains(key, "password") || strings.Contains(key, "token")
+}
+
+// redactAttr hides sensitive attributes, including fields of structs and maps
+// logged as a single value, such as a payment with a stripe_client_secret
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if isSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindAny {
+		attr.Value = redactValue(attr.Value)
+	}
+	return attr
+}
+
+// redactValue scrubs sensitive fields from a composite value by way of its
+// JSON form. Errors and scalar values are returned unchanged.
+func redactValue(value slog.Value) slog.Value {
+	v := value.Any()
+	if _, ok := v.(error); ok {
+		return value
+	}
+	if !isComposite(v) {
+		return value
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return value
+	}
+	if !scrub(decoded) {
+		return value
+	}
+	return slog.AnyValue(decoded)
+}
+
+// isComposite reports whether v may contain named fields
+func isComposite(v interface{}) bool {
+	switch v.(type) {
+	case string, []byte, bool, int, int64, float64, nil:
+		return false
+	}
+	return true
+}
+
+// scrub redacts sensitive keys in decoded JSON in place and reports whether
+// anything was redacted
+func scrub(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitive(key) {
+				v[key] = redacted
+				changed = true
+			} else if scrub(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if scrub(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
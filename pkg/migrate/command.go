@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage describes the arguments accepted by Run
+const Usage = `usage: migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [n]      roll back the last n applied migrations (default 1)
+  status        list migrations and whether each is applied
+  to <version>  migrate up or down to the given version (0 rolls back everything)`
+
+// ErrUsage is returned by Run when the arguments are invalid
+var ErrUsage = errors.New(Usage)
+
+// Run executes a migrate command line such as ["down", "2"] and writes its output to w
+func Run(ctx context.Context, m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+		if err := m.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return ErrUsage
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("down: invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		if err := m.Down(ctx, steps); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("to: invalid version %q", args[1])
+		}
+		if err := m.To(ctx, version); err != nil {
+			return err
+		}
+	case "status":
+		if len(args) != 1 {
+			return ErrUsage
+		}
+	default:
+		return ErrUsage
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return WriteStatus(w, statuses)
+}
+
+// WriteStatus prints migration statuses as an aligned table
+func WriteStatus(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
+
+// Main runs a migrate command for a service binary and returns its exit code
+func Main(ctx context.Context, m *Migrator, args []string) int {
+	if err := Run(ctx, m, args, os.Stdout); err != nil {
+		if errors.Is(err, ErrUsage) {
+			fmt.Fprintln(os.Stderr, Usage)
+			return 2
+		}
+		slog.ErrorContext(ctx, "Migration command failed", "error", err)
+		return 1
+	}
+	return 0
+}
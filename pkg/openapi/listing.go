@@ -0,0 +1,25 @@
+package openapi
+
+import "go-microservices/pkg/listing"
+
+// ListHeaders are the response headers of a list endpoint
+var ListHeaders = map[string]string{
+	listing.HeaderLink:       `Link to the next page, rel="next", when there is one`,
+	listing.HeaderTotalCount: "Number of matching items, when include_total=true",
+}
+
+// ListParams documents a list endpoint's query parameters
+func ListParams(params []listing.Param) []Parameter {
+	parameters := make([]Parameter, 0, len(params))
+	for _, param := range params {
+		schema := &Schema{Type: "string", Enum: param.OneOf}
+		switch param.Kind {
+		case listing.Int:
+			schema = &Schema{Type: "integer"}
+		case listing.Float:
+			schema = &Schema{Type: "number"}
+		}
+		parameters = append(parameters, Parameter{Name: param.Name, In: "query", Description: param.Description, Schema: schema})
+	}
+	return parameters
+}
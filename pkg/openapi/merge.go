@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// schemaRef prefixes every reference to a component schema
+const schemaRef = `"#/components/schemas/`
+
+// Mount adds the operations of src whose paths start with one of include to
+// d, at prefix followed by their path. src's schemas are copied under names
+// qualified with namespace, e.g. "inventory.Inventory", so services' types
+// with the same name don't clash, along with the tags of those operations.
+func (d *Document) Mount(src *Document, prefix, namespace string, include ...string) error {
+	selected := map[string]PathItem{}
+	used := map[string]bool{}
+	for path, item := range src.Paths {
+		for _, p := range include {
+			if strings.HasPrefix(path, p) {
+				selected[prefix+path] = item
+				for _, op := range item {
+					for _, tag := range op.Tags {
+						used[tag] = true
+					}
+				}
+				break
+			}
+		}
+	}
+	paths := map[string]PathItem{}
+	if err := requalify(selected, &paths, namespace); err != nil {
+		return err
+	}
+	schemas := map[string]*Schema{}
+	if err := requalify(src.Components.Schemas, &schemas, namespace); err != nil {
+		return err
+	}
+
+	for path, item := range paths {
+		d.Paths[path] = item
+	}
+	if d.Components.Schemas == nil {
+		d.Components.Schemas = map[string]*Schema{}
+	}
+	for name, schema := range schemas {
+		d.Components.Schemas[namespace+"."+name] = schema
+	}
+	for _, tag := range src.Tags {
+		if used[tag.Name] && !d.hasTag(tag.Name) {
+			d.Tags = append(d.Tags, tag)
+		}
+	}
+	return nil
+}
+
+// requalify deep-copies v into out, qualifying its schema references with namespace
+func requalify(v, out interface{}, namespace string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = []byte(strings.ReplaceAll(string(data), schemaRef, schemaRef+namespace+"."))
+	return json.Unmarshal(data, out)
+}
+
+func (d *Document) hasTag(name string) bool {
+	for _, tag := range d.Tags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+// Package openapi builds OpenAPI 3 documents from code. Each service lists its
+// routes next to the handlers that serve them, and the request and response
+// schemas are derived from the Go types those handlers bind and write, so the
+// spec changes with the models instead of drifting away from them.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-microservices/pkg/problem"
+)
+
+// Version is the OpenAPI version of the documents this package builds
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	// names maps each Go type with a component schema to its name
+	names map[typeKey]string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations in the UI
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path, keyed by lower-case method
+type PathItem map[string]*Operation
+
+// Operation is one method on one path
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response is one possible answer of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Components holds the schemas operations refer to by name
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Content types of responses
+const (
+	JSON        = "application/json"
+	ProblemJSON = "application/problem+json"
+	Text        = "text/plain"
+	HTML        = "text/html"
+)
+
+// Route describes one operation for Document.Add
+type Route struct {
+	// Method and Path are as registered with gin, e.g. "/orders/:id". Path
+	// parameters are documented from Path: integers when named id or
+	// ending in Id, strings otherwise.
+	Method  string
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	// Params are the query and header parameters
+	Params []Parameter
+
+	// Body is a value of the type the handler binds, nil if it reads none
+	Body interface{}
+	// Status is the success status, 200 when zero
+	Status int
+	// Response is a value of the type written on success, nil for no body
+	Response interface{}
+	// ContentType of the success response, JSON when empty
+	ContentType string
+	// Headers are the success response's headers and their descriptions
+	Headers map[string]string
+	// Errors are the other statuses the operation answers with. Statuses of
+	// 400 and above are documented as problems, others as having no body.
+	// Every operation also documents a default problem response for
+	// unexpected errors.
+	Errors []int
+	// Responses are other statuses answered with a JSON body, mapped to a
+	// value of its type, e.g. a readiness report sent with 503
+	Responses map[int]interface{}
+}
+
+// New creates an empty document
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   map[string]PathItem{},
+		names:   map[typeKey]string{},
+	}
+}
+
+// Tag adds a tag to the document
+func (d *Document) Tag(name, description string) *Document {
+	d.Tags = append(d.Tags, Tag{Name: name, Description: description})
+	return d
+}
+
+// Add documents routes
+func (d *Document) Add(routes ...Route) *Document {
+	for _, route := range routes {
+		d.add(route)
+	}
+	return d
+}
+
+func (d *Document) add(route Route) {
+	path, params := convertPath(route.Path)
+	op := &Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Parameters:  append(params, route.Params...),
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{JSON: {Schema: d.Schema(route.Body)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = JSON
+		}
+		success.Content = map[string]MediaType{contentType: {Schema: d.Schema(route.Response)}}
+	}
+	for name, description := range route.Headers {
+		if success.Headers == nil {
+			success.Headers = map[string]Header{}
+		}
+		success.Headers[name] = Header{Description: description, Schema: &Schema{Type: "string"}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	for _, code := range route.Errors {
+		response := &Response{Description: http.StatusText(code)}
+		if code >= 400 {
+			response.Content = map[string]MediaType{ProblemJSON: {Schema: d.problem()}}
+		}
+		op.Responses[strconv.Itoa(code)] = response
+	}
+	op.Responses["default"] = &Response{
+		Description: "Unexpected error",
+		Content:     map[string]MediaType{ProblemJSON: {Schema: d.problem()}},
+	}
+	for code, body := range route.Responses {
+		op.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     map[string]MediaType{JSON: {Schema: d.Schema(body)}},
+		}
+	}
+
+	item := d.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(route.Method)] = op
+}
+
+// problemType is the type of problem documents
+var problemType = reflect.TypeOf(problem.Problem{})
+
+// problem returns the schema of problem documents, whose code is one of the
+// catalogue's
+func (d *Document) problem() *Schema {
+	name := d.component(problemType)
+	code := d.Components.Schemas[name].Properties["code"]
+	if code.Enum == nil {
+		for _, entry := range problem.Catalogue() {
+			code.Enum = append(code.Enum, string(entry.Code))
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ginParam matches the parameters of a gin path
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// convertPath turns a gin path into an OpenAPI path and its parameters
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+	for _, match := range ginParam.FindAllStringSubmatch(path, -1) {
+		name := match[1]
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "Id") {
+			schema = &Schema{Type: "integer"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return ginParam.ReplaceAllString(path, "{$1}"), params
+}
+
+// Message is the body of responses that only confirm an action
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Metrics documents the Prometheus metrics endpoint
+var Metrics = Route{Method: "GET", Path: "/metrics", ID: "getMetrics", Summary: "Prometheus metrics", Tag: "operations",
+	Response: "", ContentType: Text}
+
+// Query returns an optional query parameter
+func Query(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+// RequestHeader returns an optional request header parameter
+func RequestHeader(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// Operations returns "METHOD path" for every operation, sorted
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
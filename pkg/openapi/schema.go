@@ -0,0 +1,255 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/pkg/validation"
+)
+
+// Schema is a JSON schema as OpenAPI 3.0 extends it
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// typeKey identifies a Go type across packages
+type typeKey struct {
+	pkg  string
+	name string
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Schema returns the schema of v's type. Named struct types become component
+// schemas and are referred to by name; anything else is described inline.
+// Properties are named by their json tags, and binding tags become
+// constraints: required, min, max, gt, gte, lt, lte, len, oneof, email, url,
+// startswith and the shared rules and aliases of package validation. A type
+// whose value marshals itself with MarshalJSON is described as an object with
+// unknown properties.
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Pointer {
+		schema := d.schemaOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+
+	if t.Implements(marshalerType) && t != timeType {
+		// Its fields say nothing about the JSON it writes
+		return &Schema{Type: "object"}
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawJSONType:
+		return &Schema{}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.objectOf(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + d.component(t)}
+	}
+	// Interfaces and anything else accept any value
+	return &Schema{}
+}
+
+// component returns the name of t's component schema, adding it if needed.
+// Types from different packages that share a name are told apart by package.
+func (d *Document) component(t reflect.Type) string {
+	key := typeKey{pkg: t.PkgPath(), name: t.Name()}
+	if name, ok := d.names[key]; ok {
+		return name
+	}
+	if d.Components.Schemas == nil {
+		d.Components.Schemas = map[string]*Schema{}
+	}
+
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; taken {
+		// e.g. payment_service.model.Payment
+		segments := strings.Split(t.PkgPath(), "/")
+		if len(segments) > 2 {
+			segments = segments[len(segments)-2:]
+		}
+		name = strings.ReplaceAll(strings.Join(segments, "."), "-", "_") + "." + name
+	}
+	d.names[key] = name
+	// Reserve the name first, so recursive types refer to it
+	d.Components.Schemas[name] = &Schema{}
+	*d.Components.Schemas[name] = *d.objectOf(t)
+	return name
+}
+
+// objectOf describes a struct's fields, including those of embedded structs
+func (d *Document) objectOf(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(schema, t)
+	return schema
+}
+
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		if constrain(property, field.Tag.Get("binding")) && !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain applies a binding tag's rules to a property and reports whether
+// the property is required. Rules for nested values (after dive) are ignored.
+func constrain(property *Schema, binding string) bool {
+	if property.Ref != "" {
+		// A $ref can't carry sibling keywords in OpenAPI 3.0
+		return strings.Contains(binding, "required")
+	}
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		if tags, ok := validation.Aliases[name]; ok {
+			required = constrain(property, tags) || required
+			continue
+		}
+		if pattern := validation.Pattern(name); pattern != "" {
+			property.Pattern = pattern
+			continue
+		}
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "oneof":
+			property.Enum = strings.Fields(value)
+		case "min", "gte":
+			bound(property, value, &property.Minimum, &property.MinLength, &property.MinItems)
+		case "max", "lte":
+			bound(property, value, &property.Maximum, &property.MaxLength, &property.MaxItems)
+		case "gt":
+			bound(property, value, &property.Minimum, nil, nil)
+			property.ExclusiveMinimum = property.Minimum != nil
+		case "lt":
+			bound(property, value, &property.Maximum, nil, nil)
+			property.ExclusiveMaximum = property.Maximum != nil
+		case "len":
+			bound(property, value, nil, &property.MinLength, &property.MinItems)
+			bound(property, value, nil, &property.MaxLength, &property.MaxItems)
+		case "email":
+			property.Format = "email"
+		case "url":
+			property.Format = "uri"
+		case "startswith":
+			property.Pattern = "^" + regexp.QuoteMeta(value)
+		}
+	}
+	return required
+}
+
+// bound sets the limit that applies to the property's type: a value for
+// numbers, a length for strings or a count for arrays
+func bound(property *Schema, value string, number **float64, length, items **int) {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	switch property.Type {
+	case "integer", "number":
+		if number != nil {
+			*number = &limit
+		}
+	case "string":
+		if length != nil {
+			n := int(limit)
+			*length = &n
+		}
+	case "array":
+		if items != nil {
+			n := int(limit)
+			*items = &n
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsPage renders the document next to it with Redoc
+//
+//go:embed docs.html
+var docsPage []byte
+
+// Register serves doc at GET /openapi.json and a Redoc page rendering it at
+// GET /docs, and documents both routes
+func Register(router gin.IRoutes, doc *Document) {
+	doc.Add(
+		Route{Method: "GET", Path: "/openapi.json", ID: "getOpenAPI", Summary: "This OpenAPI document", Tag: "operations",
+			Response: map[string]interface{}{}},
+		Route{Method: "GET", Path: "/docs", ID: "getDocs", Summary: "API reference rendered from /openapi.json", Tag: "operations",
+			Response: "", ContentType: HTML},
+	)
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	})
+}
+
+// Missing returns "METHOD path" for each route the document lacks. HEAD is
+// covered by GET. A catch-all route, such as a proxy's /products/*path, is
+// covered by any operation beneath its prefix.
+func (d *Document) Missing(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range routes {
+		if !d.covers(route.Method, route.Path) {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	return missing
+}
+
+func (d *Document) covers(method, ginPath string) bool {
+	if i := strings.Index(ginPath, "*"); i >= 0 {
+		prefix := ginPath[:i]
+		for path := range d.Paths {
+			if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	path, _ := convertPath(ginPath)
+	item := d.Paths[path]
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	return item[strings.ToLower(method)] != nil
+}
+
+// Unrouted returns "METHOD path" for each operation of the document that
+// none of routes serves, the converse of Missing
+func (d *Document) Unrouted(routes gin.RoutesInfo) []string {
+	var unrouted []string
+	for _, op := range d.Operations() {
+		method, path, _ := strings.Cut(op, " ")
+		if !served(routes, method, path) {
+			unrouted = append(unrouted, op)
+		}
+	}
+	return unrouted
+}
+
+// served reports whether one of routes serves method on an OpenAPI path
+func served(routes gin.RoutesInfo, method, path string) bool {
+	for _, route := range routes {
+		if route.Method != method {
+			continue
+		}
+		if i := strings.Index(route.Path, "*"); i >= 0 {
+			prefix := route.Path[:i]
+			if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+				return true
+			}
+			continue
+		}
+		if converted, _ := convertPath(route.Path); converted == path {
+			return true
+		}
+	}
+	return false
+}
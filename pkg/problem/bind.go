@@ -0,0 +1,204 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go-microservices/pkg/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// BindJSON decodes and validates the request body into obj. On failure it
+// writes a validation problem and returns false.
+func BindJSON(c *gin.Context, obj interface{}) bool {
+	validation.Register()
+	if err := bindJSON(c, obj); err != nil {
+		Write(c, FromBindError(err))
+		return false
+	}
+	return true
+}
+
+// BindQuery decodes and validates the query string into obj. On failure it
+// writes a validation problem and returns false.
+func BindQuery(c *gin.Context, obj interface{}) bool {
+	validation.Register()
+	if err := c.ShouldBindQuery(obj); err != nil {
+		Write(c, FromBindError(err))
+		return false
+	}
+	return true
+}
+
+// bindJSON is c.ShouldBindJSON, except that each element of a list body is
+// validated and its errors are kept with its index, which gin drops
+func bindJSON(c *gin.Context, obj interface{}) error {
+	list := reflect.ValueOf(obj)
+	if list.Kind() != reflect.Pointer || list.Elem().Kind() != reflect.Slice {
+		return c.ShouldBindJSON(obj)
+	}
+	if c.Request.Body == nil {
+		return io.EOF
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		return err
+	}
+
+	errs := ElementErrors{}
+	for i := 0; i < list.Elem().Len(); i++ {
+		if err := binding.Validator.ValidateStruct(list.Elem().Index(i).Interface()); err != nil {
+			errs[i] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ElementErrors are the validation errors of a list body, keyed by the
+// index of the invalid element
+type ElementErrors map[int]error
+
+func (e ElementErrors) Error() string {
+	var lines []string
+	for _, i := range e.indexes() {
+		lines = append(lines, fmt.Sprintf("[%d]: %s", i, e[i]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e ElementErrors) indexes() []int {
+	indexes := make([]int, 0, len(e))
+	for i := range e {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// FromBindError converts a binding error into a problem with one entry per
+// invalid field. Decoder messages are replaced so internals don't leak.
+func FromBindError(err error) *Problem {
+	var validationErrs validator.ValidationErrors
+	var elementErrs ElementErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		p := New(CodeValidation, "One or more fields are invalid")
+		p.Errors = fieldErrors("", validationErrs)
+		return p
+	case errors.As(err, &elementErrs):
+		p := New(CodeValidation, "One or more items are invalid")
+		for _, i := range elementErrs.indexes() {
+			prefix := fmt.Sprintf("[%d].", i)
+			if errors.As(elementErrs[i], &validationErrs) {
+				p.Errors = append(p.Errors, fieldErrors(prefix, validationErrs)...)
+			} else {
+				p.Errors = append(p.Errors, FieldError{Field: fmt.Sprintf("[%d]", i), Message: "is invalid"})
+			}
+		}
+		return p
+	case errors.As(err, &typeErr):
+		p := New(CodeValidation, "One or more fields are invalid")
+		p.Errors = []FieldError{{Field: typeErr.Field, Rule: "type", Message: "must be " + jsonType(typeErr.Type)}}
+		return p
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return New(CodeBadRequest, "The request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return New(CodeBadRequest, "The request body is empty")
+	}
+	return New(CodeBadRequest, "The request could not be decoded")
+}
+
+// fieldErrors describes each failed rule, naming fields by their path after prefix
+func fieldErrors(prefix string, errs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   prefix + fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: ruleMessage(fe),
+		})
+	}
+	return fields
+}
+
+// fieldPath returns the field's path without the top-level struct name, e.g. "items[0].quantity"
+func fieldPath(fe validator.FieldError) string {
+	path := fe.Namespace()
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		return path[i+1:]
+	}
+	return fe.Field()
+}
+
+// ruleMessage returns a readable message for a failed validation rule. The
+// rule an alias stands for is described, e.g. oneof for order_status.
+func ruleMessage(fe validator.FieldError) string {
+	if message, ok := validation.Message(fe.ActualTag()); ok {
+		return message
+	}
+	switch fe.ActualTag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return limit(fe, "at least")
+	case "max", "lte":
+		return limit(fe, "at most")
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email address"
+	case "len":
+		return "must have length " + fe.Param()
+	case "url":
+		return "must be a valid URL"
+	case "startswith":
+		return "must start with " + fe.Param()
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
+
+// limit describes a bound, which is on the length of strings and lists
+func limit(fe validator.FieldError, bound string) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return "must be " + bound + " " + fe.Param() + " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "must have " + bound + " " + fe.Param() + " items"
+	}
+	return "must be " + bound + " " + fe.Param()
+}
+
+// jsonType names a Go type the way a JSON client would think of it, e.g. "an integer"
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,44 @@
+package problem
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// maxBody bounds how much of an error response is read
+const maxBody = 64 << 10
+
+// IsProblem reports whether a response declares a problem document
+func IsProblem(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == ContentType
+}
+
+// Decode reads an error response into a problem. Problem documents are kept
+// as sent; legacy {"error": "..."} bodies and other payloads get the code for
+// the response status. It does not close the body.
+func Decode(resp *http.Response) *Problem {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+
+	if IsProblem(resp.Header) {
+		var p Problem
+		if json.Unmarshal(data, &p) == nil && p.Code != "" {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			return &p
+		}
+	}
+
+	p := New(CodeForStatus(resp.StatusCode), "")
+	p.Status = resp.StatusCode
+	var legacy struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &legacy) == nil && legacy.Error != "" {
+		p.Detail = legacy.Error
+	}
+	return p
+}
@@ -0,0 +1,40 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// internalDetail is the only detail clients see for unexpected errors; the
+// request ID in the response finds the logged cause
+const internalDetail = "An unexpected error occurred. Quote the request ID when reporting it."
+
+// Internal logs err with the request's context and writes a sanitized 500.
+// msg describes what failed, e.g. "Failed to create order". A cancelled or
+// timed-out request is reported as such rather than as an internal error.
+func Internal(c *gin.Context, msg string, err error) {
+	ctx := c.Request.Context()
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		slog.WarnContext(ctx, msg, "error", err)
+		Abort(c, CodeUnavailable, "The request timed out")
+		return
+	}
+	slog.ErrorContext(ctx, msg, "error", err)
+	Abort(c, CodeInternal, internalDetail)
+}
+
+// Recovery turns a panic in a handler into a logged, sanitized 500 problem
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered interface{}) {
+		Internal(c, "Handler panicked", fmt.Errorf("panic: %v", recovered))
+	})
+}
+
+// NoRoute answers requests for unknown paths
+func NoRoute(c *gin.Context) {
+	Abort(c, CodeNotFound, "No route for "+c.Request.Method+" "+c.Request.URL.Path)
+}
@@ -0,0 +1,253 @@
+// Package problem writes API errors as RFC 7807 application/problem+json
+// documents carrying a stable, machine-readable error code
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"go-microservices/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem documents
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code in a problem's type URI
+const TypeBase = "urn:go-microservices:problem:"
+
+// Code is a stable error identifier clients can branch on; messages may change, codes do not
+type Code string
+
+// Error codes shared by the services and the gateway
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeValidation       Code = "VALIDATION_FAILED"
+	CodeInvalidID        Code = "INVALID_ID"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeConflict         Code = "CONFLICT"
+	CodePrecondition     Code = "PRECONDITION_FAILED"
+	CodeInternal         Code = "INTERNAL_ERROR"
+	CodeUnavailable      Code = "SERVICE_UNAVAILABLE"
+	CodeUpstreamError    Code = "UPSTREAM_ERROR"
+	CodeUpstreamTimeout  Code = "UPSTREAM_TIMEOUT"
+
+	CodeOrderNotFound        Code = "ORDER_NOT_FOUND"
+	CodeProductNotFound      Code = "PRODUCT_NOT_FOUND"
+	CodeInventoryNotFound    Code = "INVENTORY_NOT_FOUND"
+	CodeNotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+	CodePaymentNotFound      Code = "PAYMENT_NOT_FOUND"
+	CodeReservationNotFound  Code = "RESERVATION_NOT_FOUND"
+	CodeInsufficientStock    Code = "INSUFFICIENT_STOCK"
+	CodeOrderNotCancellable  Code = "ORDER_NOT_CANCELLABLE"
+	CodeReturnNotFound       Code = "RETURN_NOT_FOUND"
+	CodeReturnNotAllowed     Code = "RETURN_NOT_ALLOWED"
+	CodePaymentDeclined      Code = "PAYMENT_DECLINED"
+	CodePaymentFailed        Code = "PAYMENT_FAILED"
+)
+
+// Entry describes an error code in the catalogue
+type Entry struct {
+	Code   Code   `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+// catalogue maps each code to its HTTP status and title
+var catalogue = map[Code]Entry{
+	CodeBadRequest:       {Status: http.StatusBadRequest, Title: "Bad request"},
+	CodeValidation:       {Status: http.StatusBadRequest, Title: "Validation failed"},
+	CodeInvalidID:        {Status: http.StatusBadRequest, Title: "Invalid ID"},
+	CodeUnauthorized:     {Status: http.StatusUnauthorized, Title: "Unauthorized"},
+	CodeNotFound:         {Status: http.StatusNotFound, Title: "Not found"},
+	CodeMethodNotAllowed: {Status: http.StatusMethodNotAllowed, Title: "Method not allowed"},
+	CodeConflict:         {Status: http.StatusConflict, Title: "Conflict"},
+	CodePrecondition:     {Status: http.StatusPreconditionFailed, Title: "Precondition failed"},
+	CodeInternal:         {Status: http.StatusInternalServerError, Title: "Internal error"},
+	CodeUnavailable:      {Status: http.StatusServiceUnavailable, Title: "Service unavailable"},
+	CodeUpstreamError:    {Status: http.StatusBadGateway, Title: "Upstream error"},
+	CodeUpstreamTimeout:  {Status: http.StatusGatewayTimeout, Title: "Upstream timed out"},
+
+	CodeOrderNotFound:        {Status: http.StatusNotFound, Title: "Order not found"},
+	CodeProductNotFound:      {Status: http.StatusNotFound, Title: "Product not found"},
+	CodeInventoryNotFound:    {Status: http.StatusNotFound, Title: "Inventory item not found"},
+	CodeNotificationNotFound: {Status: http.StatusNotFound, Title: "Notification not found"},
+	CodePaymentNotFound:      {Status: http.StatusNotFound, Title: "Payment not found"},
+	CodeReservationNotFound:  {Status: http.StatusNotFound, Title: "Reservation not found"},
+	CodeInsufficientStock:    {Status: http.StatusConflict, Title: "Insufficient stock"},
+	CodeOrderNotCancellable:  {Status: http.StatusConflict, Title: "Order cannot be cancelled"},
+	CodeReturnNotFound:       {Status: http.StatusNotFound, Title: "Return not found"},
+	CodeReturnNotAllowed:     {Status: http.StatusConflict, Title: "Return not allowed"},
+	CodePaymentDeclined:      {Status: http.StatusPaymentRequired, Title: "Payment declined"},
+	CodePaymentFailed:        {Status: http.StatusBadGateway, Title: "Payment failed"},
+}
+
+// Catalogue returns every error code, sorted by code
+func Catalogue() []Entry {
+	entries := make([]Entry, 0, len(catalogue))
+	for code, entry := range catalogue {
+		entry.Code = code
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// CodeForStatus returns the generic code for an HTTP status, used when an error has no code of its own
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return CodeBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePrecondition
+	case http.StatusPaymentRequired:
+		return CodePaymentDeclined
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeUpstreamTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem document. It is also an error, so clients
+// can return the problem a service answered with.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	// Extensions are extra members written alongside the standard ones
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// New creates a problem for code, taking its status and title from the catalogue
+func New(code Code, detail string) *Problem {
+	entry, ok := catalogue[code]
+	if !ok {
+		entry = catalogue[CodeInternal]
+	}
+	return &Problem{
+		Type:   TypeBase + string(code),
+		Title:  entry.Title,
+		Status: entry.Status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Error returns the detail, or the title when there is none
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// With adds an extension member and returns the problem
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// MarshalJSON writes the standard members and the extensions as one object
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	data, err := json.Marshal((*plain)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]json.RawMessage, len(p.Extensions))
+	for key, value := range p.Extensions {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		members[key] = raw
+	}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// standardMembers are the members decoded into Problem's fields
+var standardMembers = []string{"type", "title", "status", "detail", "instance", "code", "request_id", "errors"}
+
+// UnmarshalJSON reads the standard members and keeps any others as extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	type plain Problem
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, name := range standardMembers {
+		delete(members, name)
+	}
+	if len(members) > 0 {
+		p.Extensions = members
+	}
+	return nil
+}
+
+// Write sends p as the response and aborts the handler chain. The instance
+// and request ID are filled in from the request when not already set.
+func Write(c *gin.Context, p *Problem) {
+	c.Abort()
+	Render(c.Writer, c.Request, p)
+}
+
+// Render writes p to w, for handlers outside gin such as reverse proxy error handlers
+func Render(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = requestid.FromContext(r.Context())
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Abort writes a problem for code with the given detail
+func Abort(c *gin.Context, code Code, detail string) {
+	Write(c, New(code, detail))
+}
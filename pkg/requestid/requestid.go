@@ -0,0 +1,100 @@
+// Package requestid assigns and propagates the X-Request-ID used to correlate
+// log lines for one request across the gateway, services and queued events
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header carrying the request ID
+const Header = "X-Request-ID"
+
+// Key is the gin context key holding the request ID
+const Key = "request_id"
+
+// maxLength bounds request IDs accepted from callers
+const maxLength = 128
+
+type contextKey struct{}
+
+// WithID returns a context carrying the request ID
+func WithID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or ""
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New generates a request ID
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether an ID sent by a caller is safe to reuse in headers and logs
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware accepts the caller's X-Request-ID or assigns a new one, and makes
+// it available to handlers, outgoing calls and the response
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !Valid(id) {
+			id = New()
+		}
+
+		c.Request.Header.Set(Header, id)
+		c.Request = c.Request.WithContext(WithID(c.Request.Context(), id))
+		c.Set(Key, id)
+		c.Header(Header, id)
+
+		c.Next()
+	}
+}
+
+// transport forwards the request ID of each request's context
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so that outgoing requests carry the request ID of their
+// context in the X-Request-ID header
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// RoundTrip sets the header on a copy of the request and sends it
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := FromContext(req.Context())
+	if id == "" || req.Header.Get(Header) != "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, id)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,58 @@
+// Package servicetoken guards the routes only other services may call, such
+// as reserving stock or refunding a payment. The services share one token,
+// SERVICE_TOKEN, which callers send in Header.
+package servicetoken
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"go-microservices/pkg/apiclient"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the service token
+const Header = "X-Service-Token"
+
+// Authorize rejects requests that don't send token in Header. Without a token
+// every request is rejected.
+func Authorize(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader(Header)), []byte(token)) != 1 {
+			problem.Abort(c, problem.CodeUnauthorized, "This route is only for other services")
+			return
+		}
+		c.Next()
+	}
+}
+
+// transport sends a service token with every request
+type transport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, t.token)
+	return t.next.RoundTrip(req)
+}
+
+// Transport sends token in Header with every request next makes
+func Transport(token string, next http.RoundTripper) http.RoundTripper {
+	return &transport{token: token, next: next}
+}
+
+// HTTPClient returns the typed clients' default HTTP client, sending token
+// with every request
+func HTTPClient(token string) *http.Client {
+	return &http.Client{Timeout: apiclient.DefaultTimeout, Transport: Transport(token, apiclient.DefaultTransport())}
+}
+
+// Param documents the service token header of a route Authorize guards
+func Param() openapi.Parameter {
+	return openapi.RequestHeader(Header, "Service token, as set with SERVICE_TOKEN")
+}
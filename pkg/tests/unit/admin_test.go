@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"matching token", "secret", "secret", http.StatusOK},
+		{"wrong token", "secret", "guess", http.StatusUnauthorized},
+		{"prefix of the token", "secret", "sec", http.StatusUnauthorized},
+		{"no header", "secret", "", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusUnauthorized},
+		{"no token configured, header sent", "", "anything", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.DELETE("/admin/things/:id", admin.Authorize(tt.token), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/things/1", nil)
+			if tt.header != "" {
+				req.Header.Set(admin.Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				var p problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, problem.CodeUnauthorized, p.Code)
+			}
+		})
+	}
+}
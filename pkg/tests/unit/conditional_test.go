@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/conditional"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// conditionalGet serves a resource at version 3, last modified at modified
+func conditionalGet(t *testing.T, modified time.Time, headers map[string]string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/products/1", func(c *gin.Context) {
+		etag := conditional.ETag(3)
+		conditional.SetValidators(c, etag, modified)
+		if conditional.NotModified(c, etag, modified) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+
+	req := httptest.NewRequest("GET", "/products/1", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestConditional_GetSetsValidators(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+	w := conditionalGet(t, modified, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Wed, 01 May 2024 12:30:15 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+}
+
+func TestConditional_NotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": `"3"`}, http.StatusNotModified},
+		{"etag in list", map[string]string{"If-None-Match": `"1", W/"3"`}, http.StatusNotModified},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"stale etag", map[string]string{"If-None-Match": `"2"`}, http.StatusOK},
+		{"etag wins over date", map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": "Thu, 02 May 2024 00:00:00 GMT"}, http.StatusOK},
+		{"unchanged since", map[string]string{"If-Modified-Since": "Wed, 01 May 2024 12:30:15 GMT"}, http.StatusNotModified},
+		{"changed since", map[string]string{"If-Modified-Since": "Wed, 01 May 2024 12:30:14 GMT"}, http.StatusOK},
+		{"unparseable date", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := conditionalGet(t, modified, tt.headers)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestConditional_IfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		versions []int
+		ok       bool
+	}{
+		{"", nil, false},
+		{"*", nil, false},
+		{`"3"`, []int{3}, true},
+		{`"3", "4"`, []int{3, 4}, true},
+		{`W/"3"`, nil, true},
+		{`"a,b", "5"`, []int{5}, true},
+		{`"0", bogus, "7"`, []int{7}, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/products/1", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		versions, ok := conditional.IfMatch(req)
+		assert.Equal(t, tt.ok, ok, tt.header)
+		assert.Equal(t, tt.versions, versions, tt.header)
+	}
+}
@@ -0,0 +1,180 @@
+package unit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	HTTP     config.HTTP
+	Database config.Database
+	Timeout  time.Duration `env:"TEST_TIMEOUT"`
+	Hosts    []string      `env:"TEST_HOSTS"`
+	Mode     string        `env:"TEST_MODE" oneof:"fast safe"`
+}
+
+// env returns a Lookup backed by a map
+func env(values map[string]string) config.Lookup {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+// files returns a ReadFile backed by a map
+func files(contents map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		data, ok := contents[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(data), nil
+	}
+}
+
+func defaultTestConfig() testConfig {
+	return testConfig{
+		HTTP:     config.HTTP{Port: 8081},
+		Database: config.DefaultDatabase("orders_db"),
+		Timeout:  time.Second,
+		Mode:     "safe",
+	}
+}
+
+func TestConfig_EnvOverridesFileAndDefaults(t *testing.T) {
+	cfg := defaultTestConfig()
+	loader := config.Loader{
+		Env: env(map[string]string{
+			"DB_PASSWORD":  "from-env",
+			"TEST_TIMEOUT": "250ms",
+			"TEST_HOSTS":   "a, b,,c",
+		}),
+		File: "/etc/order.env",
+		ReadFile: files(map[string]string{
+			"/etc/order.env": "# comment\nPORT=9000\nDB_PASSWORD=from-file\nDB_HOST='db.internal'\n",
+		}),
+	}
+
+	require.NoError(t, loader.Load(&cfg))
+	assert.Equal(t, 9000, cfg.HTTP.Port)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, "from-env", cfg.Database.Password)
+	assert.Equal(t, "orders_db", cfg.Database.Name)
+	assert.Equal(t, 250*time.Millisecond, cfg.Timeout)
+	assert.Equal(t, []string{"a", "b", "c"}, cfg.Hosts)
+}
+
+func TestConfig_JSONFile(t *testing.T) {
+	cfg := defaultTestConfig()
+	loader := config.Loader{
+		Env:  env(nil),
+		File: "config.json",
+		ReadFile: files(map[string]string{
+			"config.json": `{"PORT": 9100, "DB_PASSWORD": "pw", "DB_AUTO_MIGRATE": false}`,
+		}),
+	}
+
+	require.NoError(t, loader.Load(&cfg))
+	assert.Equal(t, 9100, cfg.HTTP.Port)
+	assert.False(t, cfg.Database.AutoMigrate)
+}
+
+func TestConfig_SecretFiles(t *testing.T) {
+	cfg := defaultTestConfig()
+	loader := config.Loader{
+		Env:      env(map[string]string{"DB_PASSWORD_FILE": "/run/secrets/db"}),
+		ReadFile: files(map[string]string{"/run/secrets/db": "s3cr3t\n"}),
+	}
+
+	require.NoError(t, loader.Load(&cfg))
+	assert.Equal(t, "s3cr3t", cfg.Database.Password)
+
+	cfg = defaultTestConfig()
+	loader.Env = env(map[string]string{"DB_PASSWORD": "a", "DB_PASSWORD_FILE": "/run/secrets/db"})
+	err := loader.Load(&cfg)
+	assert.ErrorContains(t, err, "set either DB_PASSWORD or DB_PASSWORD_FILE")
+}
+
+func TestConfig_ReportsEveryProblem(t *testing.T) {
+	cfg := defaultTestConfig()
+	loader := config.Loader{Env: env(map[string]string{
+		"PORT":         "70000",
+		"TEST_TIMEOUT": "soon",
+		"TEST_MODE":    "reckless",
+	})}
+
+	err := loader.Load(&cfg)
+	var validationErr *config.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Len(t, validationErr.Problems, 4)
+	assert.ErrorContains(t, err, "DB_PASSWORD is required")
+	assert.ErrorContains(t, err, `TEST_TIMEOUT: invalid duration "soon"`)
+	assert.ErrorContains(t, err, "TEST_MODE must be one of fast, safe")
+	assert.ErrorContains(t, err, "PORT must be between 1 and 65535")
+}
+
+func TestConfig_PrintRedactsSecrets(t *testing.T) {
+	cfg := defaultTestConfig()
+	cfg.Database.Password = "hunter2"
+	cfg.Hosts = []string{"a", "b"}
+
+	var buf bytes.Buffer
+	require.NoError(t, config.Print(&buf, &cfg))
+
+	output := buf.String()
+	assert.Contains(t, output, "PORT=8081\n")
+	assert.Contains(t, output, "DB_PASSWORD=[REDACTED]\n")
+	assert.Contains(t, output, "TEST_TIMEOUT=1s\n")
+	assert.Contains(t, output, "TEST_HOSTS=a,b\n")
+	assert.NotContains(t, output, "hunter2")
+}
+
+func TestConfig_DatabaseDSNQuotesPassword(t *testing.T) {
+	db := config.DefaultDatabase("orders_db")
+	db.Password = `it's secret`
+
+	assert.Equal(t, `host=localhost port=5432 user=postgres password='it\'s secret' dbname=orders_db sslmode=disable`, db.DSN())
+}
+
+// testPolicy is a block of settings used under several prefixes
+type testPolicy struct {
+	Timeout time.Duration `env:"TIMEOUT"`
+	Retries int           `env:"RETRIES"`
+}
+
+func (p *testPolicy) ValidatePrefixed(prefix string) error {
+	if p.Retries < 0 {
+		return errors.New(prefix + "RETRIES must not be negative")
+	}
+	return nil
+}
+
+func TestConfig_PrefixedBlocks(t *testing.T) {
+	var cfg struct {
+		Payment   testPolicy `prefix:"PAYMENT_"`
+		Inventory testPolicy `prefix:"INVENTORY_"`
+	}
+	cfg.Payment.Timeout = 5 * time.Second
+	cfg.Inventory.Timeout = 2 * time.Second
+
+	loader := config.Loader{Env: env(map[string]string{
+		"PAYMENT_TIMEOUT":   "10s",
+		"INVENTORY_RETRIES": "-1",
+	})}
+	err := loader.Load(&cfg)
+	assert.EqualError(t, err, "invalid configuration: INVENTORY_RETRIES must not be negative")
+	assert.Equal(t, 10*time.Second, cfg.Payment.Timeout)
+	assert.Equal(t, 2*time.Second, cfg.Inventory.Timeout)
+
+	var buf bytes.Buffer
+	require.NoError(t, config.Print(&buf, &cfg))
+	assert.Equal(t, "PAYMENT_TIMEOUT=10s\nPAYMENT_RETRIES=0\nINVENTORY_TIMEOUT=2s\nINVENTORY_RETRIES=-1\n", buf.String())
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func up(context.Context) error   { return nil }
+func down(context.Context) error { return errors.New("connection refused") }
+
+// readyz serves /readyz for the checker and decodes the report
+func readyz(t *testing.T, checker *health.Checker) (int, health.Report) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	checker.RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestHealth_ReadyWhenAllDependenciesUp(t *testing.T) {
+	checker := health.NewChecker("order-service", time.Second, 0)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: up})
+	checker.Add(health.Check{Name: "redis", Probe: up})
+
+	code, report := readyz(t, checker)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusReady, report.Status)
+	assert.Equal(t, "order-service", report.Service)
+	assert.Equal(t, health.StatusUp, report.Checks["postgres"].Status)
+	assert.True(t, report.Checks["postgres"].Critical)
+}
+
+func TestHealth_OptionalDependencyDownIsDegraded(t *testing.T) {
+	checker := health.NewChecker("order-service", time.Second, 0)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: up})
+	checker.Add(health.Check{Name: "redis", Probe: down})
+
+	code, report := readyz(t, checker)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusDown, report.Checks["redis"].Status)
+	assert.Equal(t, "connection refused", report.Checks["redis"].Error)
+}
+
+func TestHealth_CriticalDependencyDownIsNotReady(t *testing.T) {
+	checker := health.NewChecker("order-service", 20*time.Millisecond, 0)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	checker.Add(health.Check{Name: "redis", Probe: down})
+
+	code, report := readyz(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusNotReady, report.Status)
+	assert.Equal(t, "timed out after 20ms", report.Checks["postgres"].Error)
+	assert.GreaterOrEqual(t, report.Checks["postgres"].LatencyMS, float64(20))
+}
+
+func TestHealth_CachesReportAndCoalescesProbes(t *testing.T) {
+	var calls atomic.Int32
+	checker := health.NewChecker("order-service", time.Second, time.Minute)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Check(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHealth_LivezDoesNotProbeDependencies(t *testing.T) {
+	checker := health.NewChecker("order-service", time.Second, 0)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: down})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	checker.RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"alive"}`, w.Body.String())
+}
+
+func TestHealth_HTTPProbe(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/livez", r.URL.Path)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, health.HTTP(http.DefaultClient, healthy.URL+"/livez")(ctx))
+	assert.ErrorContains(t, health.HTTP(http.DefaultClient, failing.URL+"/livez")(ctx), "returned 500")
+	assert.NoError(t, health.Any(health.HTTP(http.DefaultClient, failing.URL+"/livez"), health.HTTP(http.DefaultClient, healthy.URL+"/livez"))(ctx))
+}
+
+func TestHealth_DrainingIsNotReadyWithoutProbing(t *testing.T) {
+	var calls atomic.Int32
+	checker := health.NewChecker("order-service", time.Second, 0)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+	checker.SetDraining(true)
+
+	code, report := readyz(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusNotReady, report.Status)
+	assert.True(t, report.Draining)
+	assert.Equal(t, int32(0), calls.Load())
+}
@@ -0,0 +1,40 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/keyspace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyspace_VersionFollowsTypeShape(t *testing.T) {
+	type orderV1 struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	type orderV1Copy struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	type orderRenamedField struct {
+		ID    int    `json:"id"`
+		State string `json:"state"`
+	}
+	type orderNewField struct {
+		ID        int       `json:"id"`
+		Status    string    `json:"status"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	key := keyspace.New("order", orderV1{}).Key("42")
+	assert.True(t, strings.HasPrefix(key, "order:v"), key)
+	assert.True(t, strings.HasSuffix(key, ":42"), key)
+
+	assert.Equal(t, key, keyspace.New("order", orderV1Copy{}).Key("42"))
+	assert.NotEqual(t, key, keyspace.New("order", orderRenamedField{}).Key("42"))
+	assert.NotEqual(t, key, keyspace.New("order", orderNewField{}).Key("42"))
+	assert.NotEqual(t, key, keyspace.New("product", orderV1{}).Key("42"))
+}
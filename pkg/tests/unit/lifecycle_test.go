@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/config"
+	"go-microservices/pkg/lifecycle"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve starts server on a random port, returning its URL and a channel with the result of Serve
+func serve(t *testing.T, ctx context.Context, server *lifecycle.Server) (string, <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Serve(ctx, ln)
+	}()
+	return "http://" + ln.Addr().String(), result
+}
+
+func TestLifecycle_InFlightRequestCompletesDuringShutdown(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := lifecycle.NewServer("", handler, config.Shutdown{Timeout: time.Second})
+	url, result := serve(t, ctx, server)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	got := <-responses
+	require.NoError(t, got.err)
+	assert.Equal(t, "done", got.body)
+	assert.NoError(t, <-result)
+}
+
+func TestLifecycle_DrainsThenRunsHooksInOrder(t *testing.T) {
+	var steps []string
+	ctx, cancel := context.WithCancel(context.Background())
+	server := lifecycle.NewServer("", http.NotFoundHandler(), config.Shutdown{DrainDelay: 10 * time.Millisecond, Timeout: time.Second})
+	server.OnDrain(func() { steps = append(steps, "drain") })
+	server.OnShutdown("consumers", func(context.Context) error {
+		steps = append(steps, "consumers")
+		return nil
+	})
+	server.OnShutdown("redis", lifecycle.Closer(func() error {
+		steps = append(steps, "redis")
+		return errors.New("already closed")
+	}))
+	server.OnShutdown("database", func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		steps = append(steps, "database")
+		return nil
+	})
+
+	_, result := serve(t, ctx, server)
+	cancel()
+
+	err := <-result
+	assert.ErrorContains(t, err, "redis: already closed")
+	assert.Equal(t, []string{"drain", "consumers", "redis", "database"}, steps)
+}
+
+func TestLifecycle_RequestContextCancelledAfterDeadline(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := lifecycle.NewServer("", handler, config.Shutdown{Timeout: 20 * time.Millisecond})
+	url, result := serve(t, ctx, server)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	assert.ErrorContains(t, <-result, "http: context deadline exceeded")
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled after the shutdown deadline")
+	}
+}
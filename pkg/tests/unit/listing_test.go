@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listedOrder struct {
+	ID        int
+	Status    string
+	CreatedAt time.Time
+}
+
+var testOrderListing = &listing.Spec[listedOrder]{
+	Table:   "orders",
+	Columns: "id, status, created_at",
+	Key:     "id",
+	KeyOf:   func(o listedOrder) int64 { return int64(o.ID) },
+	Filters: []listing.Filter{
+		{Param: "status", Column: "status", Op: listing.Eq, Kind: listing.String, OneOf: []string{"pending", "shipped"}},
+		{Param: "customer_id", Column: "customer_id", Op: listing.Eq, Kind: listing.Int},
+		{Param: "created_from", Column: "created_at", Op: listing.Gte, Kind: listing.Time},
+		{Param: "ids", Column: "id", Op: listing.In, Kind: listing.Int},
+	},
+	Sorts: map[string]listing.Field[listedOrder]{
+		"id":         {Column: "id", Kind: listing.Int, Value: func(o listedOrder) interface{} { return o.ID }},
+		"created_at": {Column: "created_at", Kind: listing.Time, Value: func(o listedOrder) interface{} { return o.CreatedAt }},
+	},
+	DefaultSort: "-created_at",
+	MaxLimit:    50,
+}
+
+// parseListing parses a raw query string with testOrderListing
+func parseListing(t *testing.T, rawQuery string) (*listing.Request[listedOrder], *problem.Problem) {
+	values, err := url.ParseQuery(rawQuery)
+	require.NoError(t, err)
+	return testOrderListing.Parse(values)
+}
+
+func TestListing_BuildsFilteredQuery(t *testing.T) {
+	req, p := parseListing(t, "status=pending&customer_id=7&created_from=2024-01-01&ids=1,2&limit=10")
+	require.Nil(t, p)
+
+	query, args := req.SQL()
+	assert.Equal(t, "SELECT id, status, created_at FROM orders WHERE status = $1 AND customer_id = $2 AND created_at >= $3 AND id = ANY($4) ORDER BY created_at DESC, id DESC LIMIT $5", query)
+	assert.Equal(t, []interface{}{"pending", int64(7), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), pq.Int64Array{1, 2}, 11}, args)
+
+	query, args = req.CountSQL()
+	assert.Equal(t, "SELECT COUNT(*) FROM orders WHERE status = $1 AND customer_id = $2 AND created_at >= $3 AND id = ANY($4)", query)
+	assert.Len(t, args, 4)
+}
+
+func TestListing_CursorContinuesAfterLastRow(t *testing.T) {
+	req, p := parseListing(t, "status=pending&limit=2")
+	require.Nil(t, p)
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	page := req.Page([]listedOrder{
+		{ID: 9, CreatedAt: created.Add(time.Hour)},
+		{ID: 8, CreatedAt: created},
+		{ID: 7, CreatedAt: created},
+	})
+	assert.Len(t, page.Items, 2)
+	require.NotEmpty(t, page.Next)
+
+	next, p := parseListing(t, "status=pending&limit=2&cursor="+page.Next)
+	require.Nil(t, p)
+	query, args := next.SQL()
+	assert.Equal(t, "SELECT id, status, created_at FROM orders WHERE status = $1 AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4", query)
+	assert.Equal(t, []interface{}{"pending", created, int64(8), 3}, args)
+
+	last := next.Page([]listedOrder{{ID: 7, CreatedAt: created}})
+	assert.Empty(t, last.Next)
+}
+
+func TestListing_RejectsCursorFromAnotherList(t *testing.T) {
+	req, _ := parseListing(t, "status=pending&limit=1")
+	page := req.Page([]listedOrder{{ID: 2}, {ID: 1}})
+
+	for _, rawQuery := range []string{
+		"status=shipped&limit=1&cursor=" + page.Next,
+		"status=pending&sort=id&cursor=" + page.Next,
+		"cursor=not-a-cursor",
+	} {
+		_, p := parseListing(t, rawQuery)
+		require.NotNil(t, p, rawQuery)
+		assert.Equal(t, []problem.FieldError{{Field: "cursor", Rule: "cursor", Message: "is not a valid cursor for this list"}}, p.Errors)
+	}
+}
+
+func TestListing_InvalidParametersAreFieldErrors(t *testing.T) {
+	_, p := parseListing(t, "limit=500&sort=price&status=lost&customer_id=abc&created_from=yesterday&ids=1,x&include_total=maybe")
+	require.NotNil(t, p)
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "limit", Rule: "max", Message: "must be at most 50"},
+		{Field: "sort", Rule: "oneof", Message: "must be one of created_at, id"},
+		{Field: "include_total", Rule: "type", Message: "must be a boolean"},
+		{Field: "status", Rule: "type", Message: "must be one of pending, shipped"},
+		{Field: "customer_id", Rule: "type", Message: "must be an integer"},
+		{Field: "created_from", Rule: "type", Message: "must be an RFC 3339 timestamp or a YYYY-MM-DD date"},
+		{Field: "ids", Rule: "type", Message: "must be a comma-separated list of integers"},
+	}, p.Errors)
+}
+
+func TestListing_RespondSetsPageHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/orders", func(c *gin.Context) {
+		req, p := testOrderListing.Parse(c.Request.URL.Query())
+		if p != nil {
+			problem.Write(c, p)
+			return
+		}
+		page := req.Page([]listedOrder{{ID: 3}, {ID: 2}})
+		total := int64(2)
+		page.Total = &total
+		listing.Respond(c, page)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orders?limit=1&sort=id", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(listing.HeaderTotalCount))
+	link := w.Header().Get(listing.HeaderLink)
+	assert.True(t, strings.HasPrefix(link, "<?cursor="), link)
+	assert.True(t, strings.HasSuffix(link, `&limit=1&sort=id>; rel="next"`), link)
+	assert.JSONEq(t, `[{"ID":3,"Status":"","CreatedAt":"0001-01-01T00:00:00Z"}]`, w.Body.String())
+}
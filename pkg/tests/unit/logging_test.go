@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-microservices/pkg/admin"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeLine decodes a single JSON log line
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	return entry
+}
+
+func TestLogging_AddsRequestScopedFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(&buf, logging.Options{Level: slog.LevelInfo}))
+
+	ctx := requestid.WithID(context.Background(), "req-1")
+	ctx = logging.WithAttrs(ctx, slog.String("route", "/orders/:id"), slog.String("user_id", "7"))
+	logger.InfoContext(ctx, "hello")
+
+	entry := decodeLine(t, &buf)
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "/orders/:id", entry["route"])
+	assert.Equal(t, "7", entry["user_id"])
+}
+
+func TestLogging_RedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(&buf, logging.Options{Level: slog.LevelInfo}))
+
+	payment := struct {
+		ID           int    `json:"id"`
+		ClientSecret string `json:"stripe_client_secret"`
+	}{ID: 1, ClientSecret: "pi_123_secret_456"}
+	logger.Info("payment created", "payment", payment, "password", "hunter2", "order_id", 5)
+
+	line := buf.String()
+	assert.NotContains(t, line, "pi_123_secret_456")
+	assert.NotContains(t, line, "hunter2")
+
+	entry := decodeLine(t, &buf)
+	assert.Equal(t, "[REDACTED]", entry["payment"].(map[string]interface{})["stripe_client_secret"])
+	assert.Equal(t, float64(1), entry["payment"].(map[string]interface{})["id"])
+	assert.Equal(t, float64(5), entry["order_id"])
+}
+
+func TestLogging_LevelCanBeChangedAtRuntime(t *testing.T) {
+	require.NoError(t, logging.SetLevel("info"))
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(&buf, logging.Options{}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	logging.RegisterAdminRoutes(router, "secret")
+
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String())
+
+	req := httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(admin.Header, "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"DEBUG"}`, w.Body.String())
+
+	logger.Debug("shown")
+	assert.Contains(t, buf.String(), "shown")
+
+	req = httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"loud"}`))
+	req.Header.Set(admin.Header, "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	require.NoError(t, logging.SetLevel("info"))
+}
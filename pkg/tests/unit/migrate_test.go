@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go-microservices/pkg/migrate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPairsAndSortsMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"migrations/0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"migrations/0001_create_t.up.sql":     {Data: []byte("CREATE TABLE t (id INT);")},
+		"migrations/0001_create_t.down.sql":   {Data: []byte("DROP TABLE t;")},
+	}
+
+	migrations, err := migrate.Load(fsys, migrate.Dir)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_t", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE t (id INT);", migrations[0].Up)
+	assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+}
+
+func TestLoadRejectsInvalidMigrations(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_create_t.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"bad file name": {
+			"migrations/create_t.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"conflicting names": {
+			"migrations/0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"migrations/0001_create_u.down.sql": {Data: []byte("DROP TABLE u;")},
+		},
+		"zero version": {
+			"migrations/0000_create_t.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"migrations/0000_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := migrate.Load(fsys, migrate.Dir)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestServiceMigrationsLoad(t *testing.T) {
+	services := map[string]int{
+		"product-service":      3,
+		"order-service":        5,
+		"inventory-service":    4,
+		"notification-service": 2,
+		"payment-service":      2,
+	}
+
+	for service, count := range services {
+		t.Run(service, func(t *testing.T) {
+			migrations, err := migrate.Load(os.DirFS("../../../"+service+"/db"), migrate.Dir)
+			require.NoError(t, err)
+			assert.Len(t, migrations, count)
+		})
+	}
+}
+
+func TestLockKeyIsStablePerService(t *testing.T) {
+	assert.Equal(t, migrate.LockKey("order-service"), migrate.LockKey("order-service"))
+	assert.NotEqual(t, migrate.LockKey("order-service"), migrate.LockKey("payment-service"))
+}
+
+func TestRunRejectsInvalidArguments(t *testing.T) {
+	m, err := migrate.New(nil, "test", fstest.MapFS{
+		"migrations/0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"migrations/0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+	})
+	require.NoError(t, err)
+
+	for _, args := range [][]string{nil, {"sideways"}, {"to"}, {"up", "now"}} {
+		err := migrate.Run(context.Background(), m, args, &bytes.Buffer{})
+		assert.ErrorIs(t, err, migrate.ErrUsage, "args %v", args)
+	}
+
+	err = migrate.Run(context.Background(), m, []string{"down", "zero"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid step count")
+
+	err = migrate.Run(context.Background(), m, []string{"to", "7"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "unknown migration version 7")
+}
+
+func TestWriteStatus(t *testing.T) {
+	appliedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+
+	err := migrate.WriteStatus(&buf, []migrate.Status{
+		{Version: 1, Name: "create_orders", Applied: true, AppliedAt: &appliedAt},
+		{Version: 2, Name: "add_orders_created_at"},
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "0001")
+	assert.Contains(t, lines[1], "2024-05-01T12:00:00Z")
+	assert.Contains(t, lines[2], "add_orders_created_at")
+	assert.Contains(t, lines[2], "pending")
+}
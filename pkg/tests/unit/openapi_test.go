@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type specAudit struct {
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type specItem struct {
+	specAudit
+	ID       int               `json:"id"`
+	Name     string            `json:"name" binding:"required,min=2,max=40"`
+	Price    float64           `json:"price" binding:"required,gt=0"`
+	Status   string            `json:"status" binding:"omitempty,oneof=draft live"`
+	Email    string            `json:"email,omitempty" binding:"required,email"`
+	Tags     []string          `json:"tags" binding:"max=5,dive,min=1"`
+	Labels   map[string]string `json:"labels"`
+	Parent   *specItem         `json:"parent,omitempty"`
+	internal string
+	Skipped  string `json:"-"`
+}
+
+func TestSchema_DerivesPropertiesFromTags(t *testing.T) {
+	doc := openapi.New("test", "1", "")
+
+	ref := doc.Schema(specItem{})
+	require.Equal(t, "#/components/schemas/specItem", ref.Ref)
+	item := doc.Components.Schemas["specItem"]
+	require.NotNil(t, item)
+
+	// Embedded fields are flattened; unexported and "-" fields are left out
+	assert.ElementsMatch(t, []string{"created_at", "deleted_at", "id", "name", "price", "status", "email", "tags", "labels", "parent"},
+		keys(item.Properties))
+	// omitempty makes a required binding optional, since the field may be absent
+	assert.Equal(t, []string{"name", "price"}, item.Required)
+
+	assert.Equal(t, "date-time", item.Properties["created_at"].Format)
+	assert.True(t, item.Properties["deleted_at"].Nullable)
+	assert.Equal(t, "integer", item.Properties["id"].Type)
+	assert.Equal(t, 2, *item.Properties["name"].MinLength)
+	assert.Equal(t, 40, *item.Properties["name"].MaxLength)
+	assert.Equal(t, 0.0, *item.Properties["price"].Minimum)
+	assert.True(t, item.Properties["price"].ExclusiveMinimum)
+	assert.Equal(t, []string{"draft", "live"}, item.Properties["status"].Enum)
+	assert.Equal(t, "email", item.Properties["email"].Format)
+	assert.Equal(t, 5, *item.Properties["tags"].MaxItems)
+	assert.Nil(t, item.Properties["tags"].MinItems, "rules after dive apply to the elements")
+	assert.Equal(t, "string", item.Properties["labels"].AdditionalProperties.Type)
+	// Recursive types refer to their own component
+	assert.Equal(t, "#/components/schemas/specItem", item.Properties["parent"].Ref)
+}
+
+func TestSchema_QualifiesClashingNames(t *testing.T) {
+	doc := openapi.New("test", "1", "")
+
+	doc.Schema(listing.Param{})
+	doc.Schema(openapi.Parameter{})
+	doc.Schema(listing.Param{})
+
+	assert.Contains(t, doc.Components.Schemas, "Param")
+	assert.Contains(t, doc.Components.Schemas, "Parameter")
+	assert.Len(t, doc.Components.Schemas, 3, "Parameter refers to Schema")
+
+	type Param struct{}
+	assert.Equal(t, "#/components/schemas/tests.unit.Param", doc.Schema(Param{}).Ref)
+}
+
+func TestAdd_DocumentsOperation(t *testing.T) {
+	doc := openapi.New("test", "1", "").Add(openapi.Route{
+		Method: "POST", Path: "/items/:id/children/:childName", ID: "addChild",
+		Params: []openapi.Parameter{openapi.Query("dry_run", "boolean", "")},
+		Body:   specItem{}, Status: http.StatusCreated, Response: specItem{},
+		Headers: map[string]string{"Location": "URL of the child"},
+		Errors:  []int{http.StatusNotModified, http.StatusConflict},
+	})
+
+	op := doc.Paths["/items/{id}/children/{childName}"]["post"]
+	require.NotNil(t, op)
+	require.Len(t, op.Parameters, 3)
+	assert.Equal(t, "integer", op.Parameters[0].Schema.Type)
+	assert.Equal(t, "string", op.Parameters[1].Schema.Type)
+	assert.Equal(t, "query", op.Parameters[2].In)
+
+	assert.Contains(t, op.RequestBody.Content, openapi.JSON)
+	assert.Contains(t, op.Responses["201"].Content, openapi.JSON)
+	assert.Contains(t, op.Responses["201"].Headers, "Location")
+	assert.Empty(t, op.Responses["304"].Content)
+	assert.Contains(t, op.Responses["409"].Content, openapi.ProblemJSON)
+	assert.Contains(t, op.Responses["default"].Content, openapi.ProblemJSON)
+
+	// Problems list the catalogue's codes
+	code := doc.Components.Schemas["Problem"].Properties["code"]
+	assert.Contains(t, code.Enum, string(problem.CodeNotFound))
+}
+
+func TestMount_QualifiesSchemasAndPaths(t *testing.T) {
+	service := openapi.New("service", "1", "").
+		Tag("items", "Items").
+		Tag("operations", "Probes").
+		Add(
+			openapi.Route{Method: "GET", Path: "/items/:id", Tag: "items", Response: specItem{}},
+			openapi.Route{Method: "GET", Path: "/livez", Tag: "operations", Response: map[string]string{}},
+		)
+	gateway := openapi.New("gateway", "1", "")
+
+	require.NoError(t, gateway.Mount(service, "/api/v1", "catalog", "/items"))
+
+	assert.Equal(t, []string{"GET /api/v1/items/{id}"}, gateway.Operations())
+	assert.Equal(t, []openapi.Tag{{Name: "items", Description: "Items"}}, gateway.Tags)
+	assert.Contains(t, gateway.Components.Schemas, "catalog.specItem")
+	op := gateway.Paths["/api/v1/items/{id}"]["get"]
+	assert.Equal(t, "#/components/schemas/catalog.specItem", op.Responses["200"].Content[openapi.JSON].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/catalog.specItem",
+		gateway.Components.Schemas["catalog.specItem"].Properties["parent"].Ref)
+
+	// The service's document is left as it was
+	assert.Equal(t, "#/components/schemas/specItem", service.Paths["/items/{id}"]["get"].Responses["200"].Content[openapi.JSON].Schema.Ref)
+}
+
+func TestMissing_ComparesRoutesWithDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := func(c *gin.Context) {}
+	router := gin.New()
+	router.GET("/items/:id", handler)
+	router.HEAD("/items/:id", handler)
+	router.DELETE("/items/:id", handler)
+	router.Any("/proxy/*path", handler)
+
+	doc := openapi.New("test", "1", "").Add(
+		openapi.Route{Method: "GET", Path: "/items/:id"},
+		openapi.Route{Method: "PUT", Path: "/items/:id"},
+		openapi.Route{Method: "POST", Path: "/proxy/things"},
+	)
+
+	assert.Equal(t, []string{"DELETE /items/:id"}, doc.Missing(router.Routes()))
+	assert.Equal(t, []string{"PUT /items/{id}"}, doc.Unrouted(router.Routes()))
+}
+
+func keys[V any](m map[string]V) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
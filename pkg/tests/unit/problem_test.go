@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// problemRouter returns a router with the request ID middleware and problem handlers
+func problemRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware())
+	router.NoRoute(problem.NoRoute)
+	return router
+}
+
+// decodeProblem checks the content type and decodes the response body
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem.Problem {
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	return p
+}
+
+func TestProblem_ValidationListsEachField(t *testing.T) {
+	router := problemRouter()
+	router.POST("/orders", func(c *gin.Context) {
+		var req struct {
+			ProductID int    `json:"product_id" binding:"required"`
+			Quantity  int    `json:"quantity" binding:"required,min=1"`
+			Currency  string `json:"currency" binding:"oneof=usd eur"`
+		}
+		if !problem.BindJSON(c, &req) {
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"quantity":-1,"currency":"gbp"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, "/orders", p.Instance)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "product_id", Rule: "required", Message: "is required"},
+		{Field: "quantity", Rule: "min", Message: "must be at least 1"},
+		{Field: "currency", Rule: "oneof", Message: "must be one of usd, eur"},
+	}, p.Errors)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"product_id":"one"}`)))
+	p = decodeProblem(t, w)
+	assert.Equal(t, []problem.FieldError{{Field: "product_id", Rule: "type", Message: "must be an integer"}}, p.Errors)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"product_id":`)))
+	p = decodeProblem(t, w)
+	assert.Equal(t, problem.CodeBadRequest, p.Code)
+	assert.Equal(t, "The request body is not valid JSON", p.Detail)
+}
+
+func TestProblem_InternalErrorsAreSanitized(t *testing.T) {
+	router := problemRouter()
+	router.GET("/orders", func(c *gin.Context) {
+		problem.Internal(c, "Failed to list orders", errors.New(`pq: relation "orders" does not exist`))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	for _, path := range []string{"/orders", "/panic"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(requestid.Header, "req-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		p := decodeProblem(t, w)
+		assert.Equal(t, problem.CodeInternal, p.Code)
+		assert.Equal(t, "req-123", p.RequestID)
+		assert.NotContains(t, w.Body.String(), "pq:")
+		assert.NotContains(t, w.Body.String(), "nil map")
+	}
+}
+
+func TestProblem_NoRoute(t *testing.T) {
+	w := httptest.NewRecorder()
+	problemRouter().ServeHTTP(w, httptest.NewRequest("GET", "/nowhere", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.CodeNotFound, decodeProblem(t, w).Code)
+}
+
+func TestProblem_ExtensionsRoundTrip(t *testing.T) {
+	data, err := json.Marshal(problem.New(problem.CodeInsufficientStock, "Product 7 does not have 3 units in stock").With("product_id", 7))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "urn:go-microservices:problem:INSUFFICIENT_STOCK",
+		"title": "Insufficient stock",
+		"status": 409,
+		"detail": "Product 7 does not have 3 units in stock",
+		"code": "INSUFFICIENT_STOCK",
+		"product_id": 7
+	}`, string(data))
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(data, &p))
+	assert.Equal(t, problem.CodeInsufficientStock, p.Code)
+	assert.Equal(t, map[string]interface{}{"product_id": float64(7)}, p.Extensions)
+}
+
+func TestProblem_DecodeLegacyError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error":"Order not found"}`)),
+	}
+
+	p := problem.Decode(resp)
+	assert.Equal(t, problem.CodeNotFound, p.Code)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, "Order not found", p.Detail)
+}
@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/servicetoken"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceTokenAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"matching token", "secret", "secret", http.StatusOK},
+		{"wrong token", "secret", "guess", http.StatusUnauthorized},
+		{"no header", "secret", "", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.DELETE("/internal/things/:id", servicetoken.Authorize(tt.token), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodDelete, "/internal/things/1", nil)
+			if tt.header != "" {
+				req.Header.Set(servicetoken.Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				var p problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, problem.CodeUnauthorized, p.Code)
+			}
+		})
+	}
+}
+
+func TestServiceTokenClient(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(servicetoken.Header)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := servicetoken.HTTPClient("secret").Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "secret", header)
+	assert.Empty(t, req.Header.Get(servicetoken.Header), "the caller's request is left unchanged")
+}
@@ -0,0 +1,101 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validatedItem struct {
+	SKU      string  `json:"sku" binding:"required,sku"`
+	Name     string  `json:"name" binding:"required,notblank,max=5"`
+	Currency string  `json:"currency" binding:"required,currency"`
+	Status   string  `json:"status" binding:"omitempty,order_status"`
+	Price    float64 `json:"price" binding:"required,gt=0"`
+}
+
+// bind posts body to a handler binding it into a new T and returns the problem, if any
+func bind[T any](t *testing.T, body string) (int, problem.Problem) {
+	router := problemRouter()
+	router.POST("/items", func(c *gin.Context) {
+		var v T
+		if !problem.BindJSON(c, &v) {
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/items", strings.NewReader(body)))
+	if w.Code == http.StatusNoContent {
+		return w.Code, problem.Problem{}
+	}
+	return w.Code, decodeProblem(t, w)
+}
+
+func TestValidation_SharedRules(t *testing.T) {
+	code, _ := bind[validatedItem](t, `{"sku":"WID-001","name":"Cog","currency":"usd","status":"shipped","price":1.5}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = bind[validatedItem](t, `{"sku":"w.1_b","name":"Cog","currency":"EUR","price":1}`)
+	assert.Equal(t, http.StatusNoContent, code, "currency codes and SKUs are case-insensitive")
+
+	code, p := bind[validatedItem](t, `{"sku":"-bad sku","name":"   ","currency":"xyz","status":"lost","price":0}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "sku", Rule: "sku", Message: "must be 1 to 64 letters, digits, dots, dashes or underscores, starting with a letter or digit"},
+		{Field: "name", Rule: "notblank", Message: "must not be blank"},
+		{Field: "currency", Rule: "currency", Message: "must be an ISO 4217 currency code, e.g. usd"},
+		{Field: "status", Rule: "order_status", Message: "must be one of pending, processing, shipped, delivered, completed, cancelled"},
+		{Field: "price", Rule: "required", Message: "is required"},
+	}, p.Errors)
+
+	_, p = bind[validatedItem](t, `{"sku":"A1","name":"Cogwheel","currency":"usd","price":-2}`)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "name", Rule: "max", Message: "must be at most 5 characters long"},
+		{Field: "price", Rule: "gt", Message: "must be greater than 0"},
+	}, p.Errors)
+}
+
+func TestValidation_ListBodyNamesEachElement(t *testing.T) {
+	code, _ := bind[[]validatedItem](t, `[{"sku":"A1","name":"Cog","currency":"usd","price":1}]`)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	code, p := bind[[]validatedItem](t, `[
+		{"sku":"A1","name":"Cog","currency":"usd","price":1},
+		{"sku":"A2","name":"Cog","currency":"usd"},
+		{"sku":"A3","name":"Cog","currency":"usd","price":1},
+		{"name":"Cog","currency":"usd","price":1}
+	]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "[1].price", Rule: "required", Message: "is required"},
+		{Field: "[3].sku", Rule: "required", Message: "is required"},
+	}, p.Errors)
+
+	_, p = bind[[]validatedItem](t, `[{"sku":`)
+	assert.Equal(t, problem.CodeBadRequest, p.Code)
+	_, p = bind[[]validatedItem](t, ``)
+	assert.Equal(t, "The request body is empty", p.Detail)
+}
+
+func TestValidation_RulesAreDocumented(t *testing.T) {
+	doc := openapi.New("test", "1", "")
+	doc.Schema(validatedItem{})
+
+	item := doc.Components.Schemas["validatedItem"]
+	require.NotNil(t, item)
+	assert.NotEmpty(t, item.Properties["sku"].Pattern)
+	assert.Equal(t, `^[A-Za-z]{3}$`, item.Properties["currency"].Pattern)
+	assert.Equal(t, []string{"pending", "processing", "shipped", "delivered", "completed", "cancelled"}, item.Properties["status"].Enum)
+	assert.Equal(t, []string{"sku", "name", "currency", "price"}, item.Required)
+}
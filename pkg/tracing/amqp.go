@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const amqpTracerName = "go-microservices/pkg/tracing/amqp"
+
+// AMQPHeaders carries trace context in the headers of an AMQP message
+type AMQPHeaders amqp.Table
+
+// Get returns the header value for key, or "" when it is missing or not a string
+func (h AMQPHeaders) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+// Set stores a header value
+func (h AMQPHeaders) Set(key, value string) {
+	h[key] = value
+}
+
+// Keys lists the header names
+func (h AMQPHeaders) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartPublish starts a producer span for a message sent to exchange with
+// routingKey and writes its context into headers, which must not be nil
+func StartPublish(ctx context.Context, exchange, routingKey string, headers amqp.Table) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(amqpTracerName).Start(ctx, "publish "+exchange,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, AMQPHeaders(headers))
+	return ctx, span
+}
+
+// StartConsume continues the trace carried in a delivery's headers with a
+// consumer span for the queue it was received from
+func StartConsume(ctx context.Context, queue string, delivery amqp.Delivery) (context.Context, trace.Span) {
+	if delivery.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, AMQPHeaders(delivery.Headers))
+	}
+	return otel.Tracer(amqpTracerName).Start(ctx, "process "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", delivery.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", delivery.RoutingKey),
+		),
+	)
+}
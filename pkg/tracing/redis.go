@@ -0,0 +1,11 @@
+package tracing
+
+import (
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// InstrumentRedis records a span for every command sent by client
+func InstrumentRedis(client redis.UniversalClient) error {
+	return redisotel.InstrumentTracing(client)
+}
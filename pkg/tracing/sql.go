@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"database/sql"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OpenDB opens a database whose queries are recorded as spans. Queries run
+// with a request context become children of that request's span.
+func OpenDB(driverName, dataSourceName string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dataSourceName,
+		otelsql.WithAttributes(attribute.String("db.system", driverSystem(driverName))),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+		}),
+	)
+}
+
+// driverSystem maps a database/sql driver name to its db.system value
+func driverSystem(driverName string) string {
+	if driverName == "postgres" {
+		return "postgresql"
+	}
+	return driverName
+}
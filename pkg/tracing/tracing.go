@@ -0,0 +1,116 @@
+// Package tracing sets up OpenTelemetry tracing shared by the gateway and services
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters supported by OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+// Init installs a global tracer provider and W3C trace-context propagator for
+// the named service. Spans are exported according to OTEL_TRACES_EXPORTER:
+// "otlp" sends them over HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, "stdout" prints
+// them, and "none" only propagates context. When unset, "otlp" is used if an
+// endpoint is configured and "none" otherwise.
+// The returned function flushes pending spans and must be called on exit.
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, exporterName())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// exporterName returns the configured exporter
+func exporterName() string {
+	if name := os.Getenv("OTEL_TRACES_EXPORTER"); name != "" {
+		return name
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return ExporterOTLP
+	}
+	return ExporterNone
+}
+
+// newExporter creates the span exporter with the given name, or nil for none
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterOTLP:
+		// Endpoint, headers and TLS are read from the standard OTEL_EXPORTER_OTLP_* variables
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", name)
+	}
+}
+
+// Tracer returns a tracer for instrumenting the named package
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Middleware starts a server span for every request, continuing any trace
+// passed in by the caller. Metrics scrapes and health checks are not traced.
+func Middleware(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		switch r.URL.Path {
+		case "/metrics", "/health", "/livez", "/readyz":
+			return false
+		}
+		return true
+	}))
+}
+
+// Transport wraps base so that every outgoing request gets a client span and
+// carries the trace context of its request context in W3C headers
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return "HTTP " + r.Method + " " + r.URL.Host
+	}))
+}
@@ -0,0 +1,119 @@
+// Package validation holds the request validation rules shared by the
+// services. Register adds them to gin's validator once; problem.BindJSON and
+// problem.BindQuery call it, so models only declare the rules in their
+// binding tags, e.g. `binding:"required,currency"`.
+package validation
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// OrderStatuses are the statuses an order can have
+var OrderStatuses = []string{"pending", "processing", "shipped", "delivered", "completed", "cancelled"}
+
+// OrderCreated is the status announced for a newly placed order. Notifications
+// carry it besides the order statuses.
+const OrderCreated = "created"
+
+// ReturnEvents are the steps of an order return the customer is notified of;
+// notifications carry them as statuses too
+var ReturnEvents = []string{"return_requested", "return_approved", "return_rejected", "return_received", "return_refunded"}
+
+// Aliases name rule lists several models share
+var Aliases = map[string]string{
+	"order_status":        "oneof=" + strings.Join(OrderStatuses, " "),
+	"notification_status": "oneof=" + OrderCreated + " " + strings.Join(OrderStatuses, " ") + " " + strings.Join(ReturnEvents, " "),
+}
+
+// rule is a custom validation rule for strings
+type rule struct {
+	valid   func(string) bool
+	message string
+	// pattern is a regular expression valid values match, for documentation
+	pattern string
+}
+
+var (
+	skuPattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+	// iso4217 checks currency codes against the validator's own list
+	iso4217 = validator.New()
+)
+
+var rules = map[string]rule{
+	"currency": {
+		valid: func(code string) bool {
+			return currencyPattern.MatchString(code) && iso4217.Var(strings.ToUpper(code), "iso4217") == nil
+		},
+		message: "must be an ISO 4217 currency code, e.g. usd",
+		pattern: currencyPattern.String(),
+	},
+	"sku": {
+		valid:   skuPattern.MatchString,
+		message: "must be 1 to 64 letters, digits, dots, dashes or underscores, starting with a letter or digit",
+		pattern: skuPattern.String(),
+	},
+	"notblank": {
+		valid:   func(s string) bool { return strings.TrimSpace(s) != "" },
+		message: "must not be blank",
+		pattern: `\S`,
+	},
+}
+
+var registerOnce sync.Once
+
+// Register adds the custom rules and aliases to gin's validator and makes
+// its errors name fields as they appear in the request. It is safe to call
+// more than once.
+func Register() {
+	registerOnce.Do(func() {
+		engine, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		engine.RegisterTagNameFunc(fieldName)
+		for tag, r := range rules {
+			valid := r.valid
+			engine.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+				return fl.Field().Kind() == reflect.String && valid(fl.Field().String())
+			})
+		}
+		for alias, tags := range Aliases {
+			engine.RegisterAlias(alias, tags)
+		}
+	})
+}
+
+// fieldName names a field by its json tag, or its form tag for query strings
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// Message returns what a value failing a custom rule must be, e.g. "must not
+// be blank", and whether tag is a custom rule
+func Message(tag string) (string, bool) {
+	r, ok := rules[tag]
+	return r.message, ok
+}
+
+// Pattern returns a regular expression the values a custom rule accepts
+// match, or "" if tag is not a custom rule
+func Pattern(tag string) string {
+	return rules[tag].pattern
+}
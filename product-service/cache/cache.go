@@ -0,0 +1,219 @@
+// Package cache keeps recently read products and product list pages in Redis,
+// so repeated lookups don't each query Postgres
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"go-microservices/pkg/keyspace"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/tracing"
+	"go-microservices/product-service/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// productKeys names cached products; the keys change whenever model.Product does
+var productKeys = keyspace.New("product", model.Product{})
+
+// pageKeys names cached list pages, under the list generation they were read in
+var pageKeys = keyspace.New("products", listing.Page[model.Product]{})
+
+// generationKey holds the list generation, bumped by every change to a product
+const generationKey = "products:generation"
+
+// Key returns the cache key of a product
+func Key(id int) string {
+	return productKeys.Key(strconv.Itoa(id))
+}
+
+// floorKey holds the lowest version of a product that may be cached, written
+// by Invalidate
+func floorKey(id int) string {
+	return Key(id) + ":floor"
+}
+
+// Deleted is the version to invalidate a deleted product with; no version of
+// it is cached again
+const Deleted = math.MaxInt32
+
+// setScript caches a product unless Invalidate has since recorded a newer
+// version of it. KEYS: product, floor. ARGV: value, version, TTL in ms.
+var setScript = redis.NewScript(`
+local floor = redis.call('GET', KEYS[2])
+if floor and tonumber(ARGV[2]) < tonumber(floor) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+return 1
+`)
+
+// invalidateScript drops a cached product and keeps older versions out of
+// the cache. KEYS: product, floor, list generation. ARGV: version, TTL in ms.
+var invalidateScript = redis.NewScript(`
+redis.call('DEL', KEYS[1])
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+redis.call('INCR', KEYS[3])
+return 1
+`)
+
+// Products is a read-through cache of products. It never fails a request:
+// Redis errors are logged and treated as misses, so products are then read
+// from Postgres. A nil *Products caches nothing.
+type Products struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// Open connects to Redis at addr (host:port). The returned cache is usable
+// even when the connection check fails, so the service keeps running while
+// Redis is down and starts using it once it is back.
+func Open(addr, password string, ttl, timeout time.Duration) (*Products, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     password,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	})
+	products := &Products{client: client, ttl: ttl}
+
+	// Record a span for every Redis command
+	if err := tracing.InstrumentRedis(client); err != nil {
+		return products, fmt.Errorf("failed to instrument Redis: %w", err)
+	}
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return products, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return products, nil
+}
+
+// Get returns the cached product with the given ID
+func (p *Products) Get(ctx context.Context, id int) (model.Product, bool) {
+	var product model.Product
+	if p == nil {
+		return product, false
+	}
+	data, err := p.client.Get(ctx, Key(id)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			slog.WarnContext(ctx, "Failed to read cached product", "product_id", id, "error", err)
+		}
+		return product, false
+	}
+	if err := json.Unmarshal(data, &product); err != nil {
+		return product, false
+	}
+	return product, true
+}
+
+// Set caches product until the TTL passes or it is invalidated. A product
+// read before a change but set after its Invalidate is older than the version
+// Invalidate recorded, and is not cached.
+func (p *Products) Set(ctx context.Context, product model.Product) {
+	if p == nil {
+		return
+	}
+	data, err := json.Marshal(product)
+	if err != nil {
+		return
+	}
+	keys := []string{Key(product.ID), floorKey(product.ID)}
+	if err := setScript.Run(ctx, p.client, keys, data, product.Version, p.ttl.Milliseconds()).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to cache product", "product_id", product.ID, "error", err)
+	}
+}
+
+// Invalidate drops the cached copy of a product and the cached list pages
+// after the product changes to version, or is deleted (Deleted). Call it once
+// the change is committed. Until the TTL passes, reads that started before
+// the change can't cache an older version: Set checks the version recorded
+// here.
+func (p *Products) Invalidate(ctx context.Context, id, version int) {
+	if p == nil {
+		return
+	}
+	keys := []string{Key(id), floorKey(id), generationKey}
+	if err := invalidateScript.Run(ctx, p.client, keys, version, p.ttl.Milliseconds()).Err(); err != nil {
+		// The entries expire on their own within the TTL
+		slog.ErrorContext(ctx, "Failed to invalidate cached product", "product_id", id, "ttl", p.ttl, "error", err)
+	}
+}
+
+// Generation returns the list generation, which every change to a product
+// bumps. Read it before querying a page, and pass it to GetPage and SetPage:
+// a page read while a product changes is then stored under the generation
+// that change retired. ok is false when Redis can't be reached.
+func (p *Products) Generation(ctx context.Context) (generation int64, ok bool) {
+	if p == nil {
+		return 0, false
+	}
+	generation, err := p.client.Get(ctx, generationKey).Int64()
+	if err != nil && err != redis.Nil {
+		slog.WarnContext(ctx, "Failed to read product list generation", "error", err)
+		return 0, false
+	}
+	return generation, true
+}
+
+// pageKey returns the cache key of the page for query in generation
+func pageKey(generation int64, query string) string {
+	return pageKeys.Key(strconv.FormatInt(generation, 10) + ":" + query)
+}
+
+// GetPage returns the cached page for query, a normalized query string, in
+// generation
+func (p *Products) GetPage(ctx context.Context, generation int64, query string) (listing.Page[model.Product], bool) {
+	var page listing.Page[model.Product]
+	if p == nil {
+		return page, false
+	}
+	data, err := p.client.Get(ctx, pageKey(generation, query)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			slog.WarnContext(ctx, "Failed to read cached product page", "error", err)
+		}
+		return page, false
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		return page, false
+	}
+	return page, true
+}
+
+// SetPage caches the page for query in generation. Pages of retired
+// generations are never read again and expire with the TTL.
+func (p *Products) SetPage(ctx context.Context, generation int64, query string, page listing.Page[model.Product]) {
+	if p == nil {
+		return
+	}
+	data, err := json.Marshal(page)
+	if err != nil {
+		return
+	}
+	if err := p.client.Set(ctx, pageKey(generation, query), data, p.ttl).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to cache product page", "error", err)
+	}
+}
+
+// Ping checks that Redis is reachable
+func (p *Products) Ping(ctx context.Context) error {
+	if p == nil {
+		return fmt.Errorf("product cache disabled")
+	}
+	return p.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection
+func (p *Products) Close() error {
+	if p == nil {
+		return nil
+	}
+	return p.client.Close()
+}
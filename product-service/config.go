@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"go-microservices/pkg/config"
+)
+
+// Config holds the product service settings
+type Config struct {
+	HTTP     config.HTTP
+	Database config.Database
+	Admin    config.Admin
+	Health   config.Health
+	Shutdown config.Shutdown
+	Redis    RedisConfig
+	Cache    CacheConfig
+}
+
+// RedisConfig holds the product cache connection settings
+type RedisConfig struct {
+	// Host is empty when products are not cached
+	Host     string `env:"REDIS_HOST"`
+	Port     int    `env:"REDIS_PORT"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+}
+
+// Addr returns the Redis host:port
+func (r RedisConfig) Addr() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+// CacheConfig tunes the product cache
+type CacheConfig struct {
+	TTL     time.Duration `env:"PRODUCT_CACHE_TTL"`
+	Timeout time.Duration `env:"PRODUCT_CACHE_TIMEOUT"`
+}
+
+// Validate checks the cache durations
+func (c *CacheConfig) Validate() error {
+	var problems []error
+	for key, value := range map[string]time.Duration{
+		"PRODUCT_CACHE_TTL":     c.TTL,
+		"PRODUCT_CACHE_TIMEOUT": c.Timeout,
+	} {
+		if value <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive, got %s", key, value))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// loadConfig reads the product service settings, exiting when they are invalid
+func loadConfig() Config {
+	cfg := Config{
+		HTTP:     config.HTTP{Port: 8080},
+		Database: config.DefaultDatabase("products_db"),
+		Health:   config.DefaultHealth(),
+		Shutdown: config.DefaultShutdown(),
+		Redis:    RedisConfig{Port: 6379},
+		Cache:    CacheConfig{TTL: 10 * time.Minute, Timeout: 500 * time.Millisecond},
+	}
+	config.MustLoad("product-service", &cfg)
+	return cfg
+}
@@ -1,135 +1,274 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"net/http"
 	"strconv"
+	"time"
 
+	"go-microservices/pkg/conditional"
+	"go-microservices/pkg/listing"
+	"go-microservices/pkg/problem"
+	"go-microservices/product-service/cache"
 	"go-microservices/product-service/model"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // ProductController handles product-related requests
 type ProductController struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Cache *cache.Products
 }
 
-// NewProductController creates a new product controller
-func NewProductController(db *sql.DB) *ProductController {
-	return &ProductController{DB: db}
+// NewProductController creates a new product controller. productCache may be
+// nil, in which case every read goes to the database.
+func NewProductController(db *sql.DB, productCache *cache.Products) *ProductController {
+	return &ProductController{DB: db, Cache: productCache}
 }
 
 // CreateProduct handles creation of a new product
 func (pc *ProductController) CreateProduct(c *gin.Context) {
 	var product model.Product
-	if err := c.BindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &product) {
 		return
 	}
 
-	var id int
-	err := pc.DB.QueryRow(
-		"INSERT INTO products (name, description, price) VALUES ($1, $2, $3) RETURNING id",
-		product.Name, product.Description, product.Price).Scan(&id)
+	err := pc.DB.QueryRowContext(c.Request.Context(),
+		"INSERT INTO products (name, description, price) VALUES ($1, $2, $3) RETURNING id, version, updated_at",
+		product.Name, product.Description, product.Price).Scan(&product.ID, &product.Version, &product.UpdatedAt)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to create product", err)
 		return
 	}
+	// The new product belongs on cached list pages
+	pc.Cache.Invalidate(c.Request.Context(), product.ID, product.Version)
 
-	product.ID = id
+	conditional.SetValidators(c, conditional.ETag(product.Version), product.UpdatedAt)
 	c.JSON(http.StatusCreated, product)
 }
 
-// GetProducts returns all products
+// productListing describes how products are filtered, sorted and paged
+var productListing = &listing.Spec[model.Product]{
+	Table:   "products",
+	Columns: "id, name, description, price, version, updated_at",
+	Key:     "id",
+	KeyOf:   func(p model.Product) int64 { return int64(p.ID) },
+	Filters: []listing.Filter{
+		{Param: "ids", Column: "id", Op: listing.In, Kind: listing.Int},
+		{Param: "min_price", Column: "price", Op: listing.Gte, Kind: listing.Float},
+		{Param: "max_price", Column: "price", Op: listing.Lte, Kind: listing.Float},
+	},
+	Sorts: map[string]listing.Field[model.Product]{
+		"id":    {Column: "id", Kind: listing.Int, Value: func(p model.Product) interface{} { return p.ID }},
+		"name":  {Column: "name", Kind: listing.String, Value: func(p model.Product) interface{} { return p.Name }},
+		"price": {Column: "price", Kind: listing.Float, Value: func(p model.Product) interface{} { return p.Price }},
+	},
+	DefaultSort: "id",
+}
+
+// ProductListParams describes the query parameters GetProducts accepts
+func ProductListParams() []listing.Param {
+	return productListing.Params()
+}
+
+// scanProduct reads a product row selected with productListing's columns
+func scanProduct(rows *sql.Rows) (model.Product, error) {
+	var p model.Product
+	err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Version, &p.UpdatedAt)
+	return p, err
+}
+
+// GetProducts returns a page of products, optionally only those listed in the
+// ids query parameter. Pages are read through the cache, and the response
+// carries a weak ETag of the page, so a client whose copy is current gets 304
+// Not Modified.
 func (pc *ProductController) GetProducts(c *gin.Context) {
-	rows, err := pc.DB.Query("SELECT id, name, description, price FROM products")
+	query := c.Request.URL.Query()
+	req, p := productListing.Parse(query)
+	if p != nil {
+		problem.Write(c, p)
+		return
+	}
+
+	page, err := pc.loadPage(c.Request.Context(), req, query.Encode())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to list products", err)
 		return
 	}
-	defer rows.Close()
 
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	etag := pageETag(page)
+	conditional.SetValidators(c, etag, time.Time{})
+	if conditional.NotModified(c, etag, time.Time{}) {
+		return
+	}
+	listing.Respond(c, page)
+}
+
+// loadPage reads a page of products through the cache, caching it on a miss.
+// query is the request's normalized query string.
+func (pc *ProductController) loadPage(ctx context.Context, req *listing.Request[model.Product], query string) (listing.Page[model.Product], error) {
+	generation, cached := pc.Cache.Generation(ctx)
+	if cached {
+		if page, ok := pc.Cache.GetPage(ctx, generation, query); ok {
+			return page, nil
 		}
-		products = append(products, p)
 	}
 
-	c.JSON(http.StatusOK, products)
+	page, err := listing.List(ctx, pc.DB, req, scanProduct)
+	if err != nil {
+		return page, err
+	}
+	if cached {
+		pc.Cache.SetPage(ctx, generation, query, page)
+	}
+	return page, nil
 }
 
-// GetProduct returns a specific product by ID
-func (pc *ProductController) GetProduct(c *gin.Context) {
-	id := c.Param("id")
-	var product model.Product
+// pageETag returns a weak entity tag of a page: it changes whenever a product
+// on it is added, removed or updated, or the page's links or total change
+func pageETag(page listing.Page[model.Product]) string {
+	h := fnv.New64a()
+	for _, product := range page.Items {
+		fmt.Fprintf(h, "%d:%d,", product.ID, product.Version)
+	}
+	fmt.Fprintf(h, "|%s|", page.Next)
+	if page.Total != nil {
+		fmt.Fprintf(h, "%d", *page.Total)
+	}
+	return `W/"` + strconv.FormatUint(h.Sum64(), 36) + `"`
+}
 
-	err := pc.DB.QueryRow("SELECT id, name, description, price FROM products WHERE id = $1", id).
-		Scan(&product.ID, &product.Name, &product.Description, &product.Price)
+// GetProduct returns a specific product by ID. The response carries ETag and
+// Last-Modified validators; a request whose If-None-Match or If-Modified-Since
+// shows the client's copy is current gets 304 Not Modified.
+func (pc *ProductController) GetProduct(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	product, err := pc.loadProduct(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Abort(c, problem.CodeProductNotFound, fmt.Sprintf("Product %d does not exist", id))
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to get product", err)
 		return
 	}
 
+	etag := conditional.ETag(product.Version)
+	conditional.SetValidators(c, etag, product.UpdatedAt)
+	if conditional.NotModified(c, etag, product.UpdatedAt) {
+		return
+	}
 	c.JSON(http.StatusOK, product)
 }
 
-// UpdateProduct updates a product
+// loadProduct reads a product through the cache, caching it on a miss
+func (pc *ProductController) loadProduct(ctx context.Context, id int) (model.Product, error) {
+	if product, ok := pc.Cache.Get(ctx, id); ok {
+		return product, nil
+	}
+
+	var product model.Product
+	err := pc.DB.QueryRowContext(ctx, "SELECT id, name, description, price, version, updated_at FROM products WHERE id = $1", id).
+		Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Version, &product.UpdatedAt)
+	if err != nil {
+		return product, err
+	}
+	pc.Cache.Set(ctx, product)
+	return product, nil
+}
+
+// UpdateProduct updates a product. With an If-Match header the update only
+// applies if the product is still at one of the listed versions, so an edit
+// based on a stale copy fails with 412 instead of overwriting a newer one.
 func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
 		return
 	}
 
 	var product model.Product
-	if err := c.BindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !problem.BindJSON(c, &product) {
 		return
 	}
 
-	result, err := pc.DB.Exec("UPDATE products SET name = $1, description = $2, price = $3 WHERE id = $4",
-		product.Name, product.Description, product.Price, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ctx := c.Request.Context()
+	versions, conditionalWrite := conditional.IfMatch(c.Request)
+	err = pc.DB.QueryRowContext(ctx,
+		`UPDATE products SET name = $1, description = $2, price = $3, version = version + 1, updated_at = NOW()
+		WHERE id = $4 AND (NOT $5 OR version = ANY($6)) RETURNING version, updated_at`,
+		product.Name, product.Description, product.Price, id, conditionalWrite, pq.Array(versions)).
+		Scan(&product.Version, &product.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) && conditionalWrite {
+		pc.preconditionFailed(c, id)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Abort(c, problem.CodeProductNotFound, fmt.Sprintf("Product %d does not exist", id))
 		return
 	}
+	if err != nil {
+		problem.Internal(c, "Failed to update product", err)
+		return
+	}
+	pc.Cache.Invalidate(ctx, id, product.Version)
 
 	product.ID = id
+	conditional.SetValidators(c, conditional.ETag(product.Version), product.UpdatedAt)
 	c.JSON(http.StatusOK, product)
 }
 
+// preconditionFailed answers a conditional update that changed nothing: 404
+// if the product is gone, otherwise 412 with the current ETag
+func (pc *ProductController) preconditionFailed(c *gin.Context, id int) {
+	var version int
+	err := pc.DB.QueryRowContext(c.Request.Context(), "SELECT version FROM products WHERE id = $1", id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem.Abort(c, problem.CodeProductNotFound, fmt.Sprintf("Product %d does not exist", id))
+		return
+	}
+	if err != nil {
+		problem.Internal(c, "Failed to update product", err)
+		return
+	}
+
+	c.Header("ETag", conditional.ETag(version))
+	problem.Abort(c, problem.CodePrecondition,
+		fmt.Sprintf("Product %d has changed since it was read; fetch it again and reapply the update", id))
+}
+
 // DeleteProduct deletes a product
 func (pc *ProductController) DeleteProduct(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Abort(c, problem.CodeInvalidID, "ID must be an integer")
+		return
+	}
 
-	result, err := pc.DB.Exec("DELETE FROM products WHERE id = $1", id)
+	result, err := pc.DB.ExecContext(c.Request.Context(), "DELETE FROM products WHERE id = $1", id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(c, "Failed to delete product", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		problem.Abort(c, problem.CodeProductNotFound, fmt.Sprintf("Product %d does not exist", id))
 		return
 	}
+	pc.Cache.Invalidate(c.Request.Context(), id, cache.Deleted)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
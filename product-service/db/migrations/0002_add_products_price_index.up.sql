@@ -0,0 +1,2 @@
+-- Supports the price range filter and sorting by price
+CREATE INDEX IF NOT EXISTS idx_products_price_id ON products(price, id);
@@ -0,0 +1,5 @@
+-- version is bumped by every update and is the product's ETag;
+-- updated_at is its Last-Modified
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
@@ -1,8 +1,19 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/lifecycle"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/openapi"
+	"go-microservices/pkg/problem"
+	"go-microservices/pkg/requestid"
+	"go-microservices/pkg/tracing"
+	"go-microservices/product-service/cache"
 	"go-microservices/product-service/controller"
 	"go-microservices/product-service/db"
 	"go-microservices/product-service/routes"
@@ -11,25 +22,79 @@ import (
 )
 
 func main() {
+	logging.Init("product-service")
+	cfg := loadConfig()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), "product-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
 	// Initialize database connection
-	database := db.GetDB()
-	defer database.Close()
+	database := db.GetDB(cfg.Database)
+
+	// "migrate <command>" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := migrate.Main(context.Background(), db.NewMigrator(database), os.Args[2:])
+		database.Close()
+		if code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Apply pending schema migrations
+	if cfg.Database.AutoMigrate {
+		db.Migrate(database)
+	} else {
+		slog.Info("Skipping schema migrations", "reason", "DB_AUTO_MIGRATE=false")
+	}
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Cache products in Redis when it is configured
+	var productCache *cache.Products
+	if cfg.Redis.Host != "" {
+		productCache, err = cache.Open(cfg.Redis.Addr(), cfg.Redis.Password, cfg.Cache.TTL, cfg.Cache.Timeout)
+		if err != nil {
+			slog.Warn("Failed to initialize Redis", "error", err)
+		}
+	} else {
+		slog.Info("Product cache disabled", "reason", "REDIS_HOST is not set")
+	}
 
 	// Create product controller
-	productController := controller.NewProductController(database)
+	productController := controller.NewProductController(database, productCache)
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(problem.Recovery(), requestid.Middleware(), tracing.Middleware("product-service"), logging.Middleware(), logging.AccessLog())
+	router.NoRoute(problem.NoRoute)
+
+	// Add log level admin endpoint
+	logging.RegisterAdminRoutes(router, cfg.Admin.Token)
+
+	// Add liveness and readiness probes
+	checker := health.NewChecker("product-service", cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checker.Add(health.Check{Name: "postgres", Critical: true, Probe: health.SQL(database)})
+	if productCache != nil {
+		checker.Add(health.Check{Name: "redis", Probe: productCache.Ping})
+	}
+	checker.RegisterRoutes(router)
 
 	// Setup routes
 	routes.SetupRoutes(router, productController)
 
+	// Serve the OpenAPI document and its reference page
+	openapi.Register(router, routes.Spec())
+
 	// Start server
-	log.Println("Product Service starting on port 8080...")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	slog.Info("Product Service starting", "port", cfg.HTTP.Port)
+	server := lifecycle.NewServer(cfg.HTTP.Addr(), router, cfg.Shutdown)
+	server.OnDrain(func() { checker.SetDraining(true) })
+	server.OnShutdown("redis", lifecycle.Closer(productCache.Close))
+	server.OnShutdown("database", lifecycle.Closer(database.Close))
+	server.OnShutdown("tracing", shutdownTracing)
+	if err := server.Run(); err != nil {
+		logging.Fatal("Server shutdown failed", "error", err)
 	}
 }
@@ -1,9 +1,14 @@
 package model
 
+import "time"
+
 // Product represents a product entity
 type Product struct {
 	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string  `json:"name" binding:"required,notblank,max=200"`
+	Description string  `json:"description" binding:"max=2000"`
+	Price       float64 `json:"price" binding:"required,gt=0"`
+	// Version is bumped by every update; the product's ETag is derived from it
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
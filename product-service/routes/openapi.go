@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"net/http"
+
+	"go-microservices/pkg/health"
+	"go-microservices/pkg/logging"
+	"go-microservices/pkg/openapi"
+	"go-microservices/product-service/controller"
+	"go-microservices/product-service/model"
+)
+
+// Spec documents every route the product service serves
+func Spec() *openapi.Document {
+	validators := map[string]string{
+		"ETag":          "Version of the product, for If-None-Match and If-Match",
+		"Last-Modified": "When the product last changed, for If-Modified-Since",
+	}
+	listHeaders := map[string]string{"ETag": "Weak tag of the page, for If-None-Match"}
+	for name, description := range openapi.ListHeaders {
+		listHeaders[name] = description
+	}
+
+	return openapi.New("Product Service API", "1.0", "The product catalogue.").
+		Tag("products", "Products and their prices").
+		Tag("operations", "Probes, metrics and administration").
+		Add(
+			openapi.Route{Method: "POST", Path: "/products", ID: "createProduct", Summary: "Create a product", Tag: "products",
+				Body: model.Product{}, Status: http.StatusCreated, Response: model.Product{}, Headers: validators,
+				Errors: []int{http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/products", ID: "listProducts", Summary: "List products", Tag: "products",
+				Params: append(openapi.ListParams(controller.ProductListParams()),
+					openapi.RequestHeader("If-None-Match", "Answer 304 if the page still has this ETag")),
+				Response: []model.Product{}, Headers: listHeaders,
+				Errors: []int{http.StatusNotModified, http.StatusBadRequest}},
+			openapi.Route{Method: "GET", Path: "/products/:id", ID: "getProduct", Summary: "Get a product", Tag: "products",
+				Params: []openapi.Parameter{
+					openapi.RequestHeader("If-None-Match", "Answer 304 if the product still has one of these ETags"),
+					openapi.RequestHeader("If-Modified-Since", "Answer 304 if the product has not changed since then"),
+				},
+				Response: model.Product{}, Headers: validators,
+				Errors: []int{http.StatusNotModified, http.StatusBadRequest, http.StatusNotFound}},
+			openapi.Route{Method: "PUT", Path: "/products/:id", ID: "updateProduct", Summary: "Replace a product", Tag: "products",
+				Params: []openapi.Parameter{
+					openapi.RequestHeader("If-Match", "Only update the product if it still has one of these ETags"),
+				},
+				Body: model.Product{}, Response: model.Product{}, Headers: validators,
+				Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed}},
+			openapi.Route{Method: "DELETE", Path: "/products/:id", ID: "deleteProduct", Summary: "Delete a product", Tag: "products",
+				Response: openapi.Message{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		).
+		Add(health.Routes()...).
+		Add(logging.AdminRoutes()...)
+}